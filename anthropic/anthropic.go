@@ -0,0 +1,312 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/cache"
+	"github.com/ravilushqa/gpt-pullrequest-updater/logs"
+	"github.com/ravilushqa/gpt-pullrequest-updater/usage"
+)
+
+const (
+	// DefaultBaseURL is the Anthropic API endpoint.
+	DefaultBaseURL = "https://api.anthropic.com"
+	// DefaultModel is used when no model is given.
+	DefaultModel = "claude-3-5-sonnet-latest"
+	// DefaultMaxTokens limits the size of a single completion.
+	DefaultMaxTokens = 4096
+	// DefaultMaxPromptLength is the prompt length in characters used to chunk patches.
+	DefaultMaxPromptLength = 16384
+
+	apiVersion  = "2023-06-01"
+	temperature = 0.1
+)
+
+// Stop reasons returned by the Messages API.
+const (
+	StopReasonEndTurn      = "end_turn"
+	StopReasonMaxTokens    = "max_tokens"
+	StopReasonStopSequence = "stop_sequence"
+	StopReasonToolUse      = "tool_use"
+	StopReasonPauseTurn    = "pause_turn"
+	StopReasonRefusal      = "refusal"
+)
+
+// Config configures a Client.
+type Config struct {
+	Token   string
+	Model   string
+	BaseURL string
+	// MaxTokens is the maximum number of tokens to generate. Zero means DefaultMaxTokens.
+	MaxTokens int
+	// MaxPromptLength is the maximum prompt length in characters. Zero means DefaultMaxPromptLength.
+	MaxPromptLength int
+	HTTPClient      *http.Client
+	// Tracker collects the token usage of every call. Optional.
+	Tracker *usage.Tracker
+	// Budget caps the tokens and cost of the run. Optional.
+	Budget *usage.Budget
+	// Cache stores completions on disk. Optional.
+	Cache *cache.Cache
+}
+
+// Usage is the token usage reported by the Messages API.
+type Usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// Client is a Claude client implementing the same completion interface as the OpenAI client.
+type Client struct {
+	httpClient      *http.Client
+	token           string
+	model           string
+	baseURL         string
+	maxTokens       int
+	maxPromptLength int
+	retryDelay      time.Duration
+	tracker         *usage.Tracker
+	budget          *usage.Budget
+	cache           *cache.Cache
+}
+
+func NewClient(cfg Config) (*Client, error) {
+	if cfg.Token == "" {
+		return nil, errors.New("anthropic token is required")
+	}
+	if cfg.Model == "" {
+		cfg.Model = DefaultModel
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = DefaultBaseURL
+	}
+	if cfg.MaxTokens == 0 {
+		cfg.MaxTokens = DefaultMaxTokens
+	}
+	if cfg.MaxPromptLength == 0 {
+		cfg.MaxPromptLength = DefaultMaxPromptLength
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{}
+	}
+
+	return &Client{
+		httpClient:      cfg.HTTPClient,
+		token:           cfg.Token,
+		model:           cfg.Model,
+		baseURL:         strings.TrimSuffix(cfg.BaseURL, "/"),
+		maxTokens:       cfg.MaxTokens,
+		maxPromptLength: cfg.MaxPromptLength,
+		retryDelay:      time.Minute,
+		tracker:         cfg.Tracker,
+		budget:          cfg.Budget,
+		cache:           cfg.Cache,
+	}, nil
+}
+
+// MaxPromptLength returns the maximum prompt length in characters supported by the model.
+func (c *Client) MaxPromptLength() int {
+	return c.maxPromptLength
+}
+
+// Budget returns the budget the client spends from.
+func (c *Client) Budget() *usage.Budget {
+	return c.budget
+}
+
+func (c *Client) ChatCompletion(ctx context.Context, messages []openai.ChatCompletionMessage) (string, error) {
+	key := cache.Key(cache.Request{
+		Provider:    "anthropic",
+		Endpoint:    c.baseURL,
+		Model:       c.model,
+		Temperature: temperature,
+		MaxTokens:   c.maxTokens,
+		Messages:    messages,
+	})
+	if completion, ok := c.cache.Get(key); ok {
+		logs.Println("Using cached completion")
+		return completion, nil
+	}
+
+	if err := c.budget.Check(c.model, usage.EstimateTokens(messages)); err != nil {
+		return "", err
+	}
+
+	req, err := newMessagesRequest(c.model, c.maxTokens, messages)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.createMessage(ctx, req)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return "", err
+		}
+		logs.Println("Error completing prompt:", err)
+		logs.Printf("Retrying after %s\n", c.retryDelay)
+		// retry once after a delay
+		time.Sleep(c.retryDelay)
+		resp, err = c.createMessage(ctx, req)
+		if err != nil {
+			return "", fmt.Errorf("error completing prompt: %w", err)
+		}
+	}
+
+	c.tracker.Add(c.model, resp.Usage.InputTokens, resp.Usage.OutputTokens)
+
+	switch resp.StopReason {
+	case StopReasonEndTurn, StopReasonStopSequence, StopReasonMaxTokens:
+	case StopReasonToolUse:
+		return "", errors.New("error completing prompt: the model asked to use a tool, tools are not supported")
+	case StopReasonPauseTurn:
+		return "", errors.New("error completing prompt: the model paused its turn, long running turns are not supported")
+	case StopReasonRefusal:
+		return "", errors.New("error completing prompt: the model refused to answer")
+	default:
+		return "", fmt.Errorf("error completing prompt: unexpected stop reason %q", resp.StopReason)
+	}
+
+	var text strings.Builder
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return "", errors.New("error completing prompt: empty response")
+	}
+
+	completion := text.String()
+	if resp.StopReason == StopReasonMaxTokens {
+		// a cut off completion is used once but not reused
+		logs.Printf("Completion was truncated: max tokens (%d) reached\n", c.maxTokens)
+	} else if err := c.cache.Set(key, c.model, completion); err != nil {
+		logs.Println("Error caching completion:", err)
+	}
+
+	return completion, nil
+}
+
+type messagesRequest struct {
+	Model       string    `json:"model"`
+	System      string    `json:"system,omitempty"`
+	Messages    []message `json:"messages"`
+	MaxTokens   int       `json:"max_tokens"`
+	Temperature float32   `json:"temperature"`
+}
+
+type message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type messagesResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	StopReason string `json:"stop_reason"`
+	Usage      Usage  `json:"usage"`
+}
+
+type errorResponse struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// newMessagesRequest maps OpenAI chat messages to the Messages API, which takes the system prompt separately
+// and expects alternating user and assistant turns starting with a user turn.
+func newMessagesRequest(model string, maxTokens int, messages []openai.ChatCompletionMessage) (*messagesRequest, error) {
+	req := &messagesRequest{
+		Model:       model,
+		MaxTokens:   maxTokens,
+		Temperature: temperature,
+	}
+
+	var system []string
+	for _, m := range messages {
+		var role string
+		switch m.Role {
+		case openai.ChatMessageRoleSystem:
+			system = append(system, m.Content)
+			continue
+		case openai.ChatMessageRoleUser:
+			role = "user"
+		case openai.ChatMessageRoleAssistant:
+			role = "assistant"
+		default:
+			return nil, fmt.Errorf("unsupported message role: %s", m.Role)
+		}
+
+		if n := len(req.Messages); n > 0 && req.Messages[n-1].Role == role {
+			req.Messages[n-1].Content += "\n\n" + m.Content
+			continue
+		}
+		if len(req.Messages) == 0 && role != "user" {
+			req.Messages = append(req.Messages, message{Role: "user", Content: "Continue."})
+		}
+		req.Messages = append(req.Messages, message{Role: role, Content: m.Content})
+	}
+
+	if len(req.Messages) == 0 {
+		// a prompt made only of system messages is sent as the user turn
+		req.Messages = append(req.Messages, message{Role: "user", Content: strings.Join(system, "\n\n")})
+		return req, nil
+	}
+	req.System = strings.Join(system, "\n\n")
+
+	return req, nil
+}
+
+func (c *Client) createMessage(ctx context.Context, req *messagesRequest) (*messagesResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", c.token)
+	httpReq.Header.Set("anthropic-version", apiVersion)
+
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+
+	if httpResp.StatusCode != http.StatusOK {
+		var errResp errorResponse
+		if err := json.Unmarshal(respBody, &errResp); err == nil && errResp.Error.Message != "" {
+			return nil, fmt.Errorf("anthropic error, status code: %d, type: %s, message: %s", httpResp.StatusCode, errResp.Error.Type, errResp.Error.Message)
+		}
+		return nil, fmt.Errorf("anthropic error, status code: %d", httpResp.StatusCode)
+	}
+
+	var resp messagesResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return &resp, nil
+}
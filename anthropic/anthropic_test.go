@@ -0,0 +1,207 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/cache"
+	"github.com/ravilushqa/gpt-pullrequest-updater/logs"
+	"github.com/ravilushqa/gpt-pullrequest-updater/usage"
+)
+
+func TestNewMessagesRequest(t *testing.T) {
+	testCases := []struct {
+		name             string
+		messages         []openai.ChatCompletionMessage
+		expectedSystem   string
+		expectedMessages []message
+		expectError      bool
+	}{
+		{
+			name: "System prompt is sent separately",
+			messages: []openai.ChatCompletionMessage{
+				{Role: openai.ChatMessageRoleSystem, Content: "review"},
+				{Role: openai.ChatMessageRoleUser, Content: "patch"},
+			},
+			expectedSystem:   "review",
+			expectedMessages: []message{{Role: "user", Content: "patch"}},
+		},
+		{
+			name: "Consecutive user messages are merged",
+			messages: []openai.ChatCompletionMessage{
+				{Role: openai.ChatMessageRoleUser, Content: "describe"},
+				{Role: openai.ChatMessageRoleUser, Content: "patch1"},
+				{Role: openai.ChatMessageRoleUser, Content: "patch2"},
+			},
+			expectedMessages: []message{{Role: "user", Content: "describe\n\npatch1\n\npatch2"}},
+		},
+		{
+			name: "Conversation starting with assistant",
+			messages: []openai.ChatCompletionMessage{
+				{Role: openai.ChatMessageRoleAssistant, Content: "hello"},
+				{Role: openai.ChatMessageRoleUser, Content: "patch"},
+			},
+			expectedMessages: []message{
+				{Role: "user", Content: "Continue."},
+				{Role: "assistant", Content: "hello"},
+				{Role: "user", Content: "patch"},
+			},
+		},
+		{
+			name: "Only system messages",
+			messages: []openai.ChatCompletionMessage{
+				{Role: openai.ChatMessageRoleSystem, Content: "review"},
+			},
+			expectedMessages: []message{{Role: "user", Content: "review"}},
+		},
+		{
+			name: "Unsupported role",
+			messages: []openai.ChatCompletionMessage{
+				{Role: "function", Content: "call"},
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := newMessagesRequest("model", 100, tc.messages)
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedSystem, req.System)
+			assert.Equal(t, tc.expectedMessages, req.Messages)
+		})
+	}
+}
+
+func TestChatCompletion(t *testing.T) {
+	testCases := []struct {
+		name          string
+		stopReason    string
+		expected      string
+		expectedError string
+	}{
+		{
+			name:       "End turn",
+			stopReason: StopReasonEndTurn,
+			expected:   "completion",
+		},
+		{
+			name:       "Max tokens",
+			stopReason: StopReasonMaxTokens,
+			expected:   "completion",
+		},
+		{
+			name:          "Tool use",
+			stopReason:    StopReasonToolUse,
+			expectedError: "the model asked to use a tool",
+		},
+		{
+			name:          "Refusal",
+			stopReason:    StopReasonRefusal,
+			expectedError: "the model refused to answer",
+		},
+		{
+			name:          "Unexpected stop reason",
+			stopReason:    "unknown",
+			expectedError: `unexpected stop reason "unknown"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/v1/messages", r.URL.Path)
+				assert.Equal(t, "token", r.Header.Get("x-api-key"))
+				assert.Equal(t, apiVersion, r.Header.Get("anthropic-version"))
+
+				var req messagesRequest
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+				assert.Equal(t, "system", req.System)
+
+				_, _ = w.Write([]byte(`{
+					"content": [{"type": "text", "text": "completion"}],
+					"stop_reason": "` + tc.stopReason + `",
+					"usage": {"input_tokens": 10, "output_tokens": 5}
+				}`))
+			}))
+			defer server.Close()
+
+			tracker := usage.NewTracker()
+			client, err := NewClient(Config{Token: "token", BaseURL: server.URL, Tracker: tracker})
+			require.NoError(t, err)
+
+			completion, err := client.ChatCompletion(context.Background(), []openai.ChatCompletionMessage{
+				{Role: openai.ChatMessageRoleSystem, Content: "system"},
+				{Role: openai.ChatMessageRoleUser, Content: "patch"},
+			})
+			if tc.expectedError != "" {
+				assert.ErrorContains(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, completion)
+			report := tracker.Report(usage.DefaultPrices)
+			assert.Equal(t, 1, report.Calls)
+			assert.Equal(t, 10, report.PromptTokens)
+			assert.Equal(t, 5, report.CompletionTokens)
+		})
+	}
+}
+
+func TestChatCompletionAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"type": "error", "error": {"type": "invalid_request_error", "message": "bad request"}}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Config{Token: "token", BaseURL: server.URL})
+	require.NoError(t, err)
+	client.retryDelay = 0
+
+	_, err = client.ChatCompletion(context.Background(), []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleUser, Content: "patch"},
+	})
+	assert.ErrorContains(t, err, "bad request")
+}
+
+func TestChatCompletionTruncated(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_, _ = w.Write([]byte(`{"content": [{"type": "text", "text": "compl"}], "stop_reason": "max_tokens"}`))
+	}))
+	defer server.Close()
+
+	var logBuf bytes.Buffer
+	logs.SetOutput(&logBuf)
+	defer logs.SetOutput(os.Stdout)
+
+	completionCache, err := cache.New(t.TempDir(), 0, 0)
+	require.NoError(t, err)
+	client, err := NewClient(Config{Token: "token", BaseURL: server.URL, MaxTokens: 10, Cache: completionCache})
+	require.NoError(t, err)
+
+	messages := []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "patch"}}
+	for i := 0; i < 2; i++ {
+		completion, err := client.ChatCompletion(context.Background(), messages)
+		require.NoError(t, err)
+		assert.Equal(t, "compl", completion)
+	}
+	// the partial completion is returned but not cached
+	assert.Equal(t, 2, calls)
+	assert.Contains(t, logBuf.String(), "Completion was truncated: max tokens (10) reached")
+}
@@ -0,0 +1,197 @@
+// Package bitbucket reads and comments on pull requests of Bitbucket Cloud and Bitbucket Data Center.
+package bitbucket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/v51/github"
+)
+
+// CloudURL is the API URL of Bitbucket Cloud.
+const CloudURL = "https://api.bitbucket.org/2.0"
+
+// Config configures the clients of both Bitbucket flavours.
+type Config struct {
+	// BaseURL is the API URL of Bitbucket Cloud, which defaults to CloudURL, or the URL of the Data Center
+	// instance, e.g. https://bitbucket.example.com.
+	BaseURL string
+	// Username authenticates with basic auth together with Token, e.g. an app password of Bitbucket Cloud.
+	// When empty, Token is sent as a bearer token, e.g. an access token of a repository or a HTTP access
+	// token of Data Center.
+	Username   string
+	Token      string
+	HTTPClient *http.Client
+}
+
+// client is the HTTP client shared by the Cloud and the Data Center clients.
+type client struct {
+	httpClient *http.Client
+	baseURL    string
+	username   string
+	token      string
+
+	mu sync.Mutex
+	// diffs caches the changed files of pull requests, inline comments need their patches
+	diffs map[string][]*github.CommitFile
+}
+
+func newClient(cfg Config) (*client, error) {
+	if cfg.Token == "" {
+		return nil, errors.New("bitbucket token is required")
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{}
+	}
+
+	return &client{
+		httpClient: cfg.HTTPClient,
+		baseURL:    strings.TrimSuffix(cfg.BaseURL, "/"),
+		username:   cfg.Username,
+		token:      cfg.Token,
+		diffs:      map[string][]*github.CommitFile{},
+	}, nil
+}
+
+type errorResponse struct {
+	// Error is set by Bitbucket Cloud.
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+	// Errors are set by Bitbucket Data Center.
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// cachedFiles returns the files of the pull request, loading them with load on first use.
+func (c *client) cachedFiles(key string, load func() ([]*github.CommitFile, error)) ([]*github.CommitFile, error) {
+	c.mu.Lock()
+	files, ok := c.diffs[key]
+	c.mu.Unlock()
+	if ok {
+		return files, nil
+	}
+
+	files, err := load()
+	if err != nil {
+		return nil, err
+	}
+	c.cacheFiles(key, files)
+	return files, nil
+}
+
+func (c *client) cacheFiles(key string, files []*github.CommitFile) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.diffs[key] = files
+}
+
+func (c *client) get(ctx context.Context, path string, v interface{}) error {
+	return c.send(ctx, http.MethodGet, path, nil, v)
+}
+
+// send sends payload, when not nil, as JSON and decodes the JSON response into v, when not nil. Paths may
+// also be absolute URLs, e.g. the next page links of Bitbucket Cloud.
+func (c *client) send(ctx context.Context, method, path string, payload, v interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("error encoding request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	respBody, err := c.do(ctx, method, path, body, "application/json")
+	if err != nil {
+		return err
+	}
+	if v == nil || len(respBody) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, v); err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+	return nil
+}
+
+func (c *client) do(ctx context.Context, method, path string, body io.Reader, accept string) ([]byte, error) {
+	url := path
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		url = c.baseURL + path
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var errResp errorResponse
+		if err := json.Unmarshal(respBody, &errResp); err == nil {
+			if errResp.Error.Message != "" {
+				return nil, fmt.Errorf("bitbucket error, status code: %d, message: %s", resp.StatusCode, errResp.Error.Message)
+			}
+			if len(errResp.Errors) > 0 {
+				return nil, fmt.Errorf("bitbucket error, status code: %d, message: %s", resp.StatusCode, errResp.Errors[0].Message)
+			}
+		}
+		return nil, fmt.Errorf("bitbucket error, status code: %d", resp.StatusCode)
+	}
+
+	return respBody, nil
+}
+
+// parseAuthor splits a "Name <email>" author into its name and email.
+func parseAuthor(raw string) (name, email string) {
+	raw = strings.TrimSpace(raw)
+	i := strings.LastIndex(raw, "<")
+	if i < 0 || !strings.HasSuffix(raw, ">") {
+		return raw, ""
+	}
+	return strings.TrimSpace(raw[:i]), raw[i+1 : len(raw)-1]
+}
+
+// findFile returns the changed file at path.
+func findFile(files []*github.CommitFile, path string) (*github.CommitFile, error) {
+	for _, file := range files {
+		if file.GetFilename() == path {
+			return file, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not changed", path)
+}
+
+// reverse reverses commits listed newest first.
+func reverse(commits []*github.RepositoryCommit) {
+	for i, j := 0, len(commits)-1; i < j; i, j = i+1, j-1 {
+		commits[i], commits[j] = commits[j], commits[i]
+	}
+}
@@ -0,0 +1,264 @@
+package bitbucket
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/google/go-github/v51/github"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/codehost"
+	ghClient "github.com/ravilushqa/gpt-pullrequest-updater/github"
+)
+
+// Cloud implements codehost.Host for Bitbucket Cloud. Owner is the workspace and repo the repository slug.
+type Cloud struct {
+	*client
+}
+
+var _ codehost.Host = (*Cloud)(nil)
+
+func NewCloud(cfg Config) (*Cloud, error) {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = CloudURL
+	}
+	c, err := newClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Cloud{c}, nil
+}
+
+type cloudPullRequest struct {
+	ID          int    `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	// State is one of OPEN, MERGED, DECLINED and SUPERSEDED.
+	State       string    `json:"state"`
+	Author      cloudUser `json:"author"`
+	Source      cloudRef  `json:"source"`
+	Destination cloudRef  `json:"destination"`
+	Links       struct {
+		HTML struct {
+			Href string `json:"href"`
+		} `json:"html"`
+	} `json:"links"`
+}
+
+type cloudUser struct {
+	Nickname    string `json:"nickname"`
+	DisplayName string `json:"display_name"`
+}
+
+type cloudRef struct {
+	Branch struct {
+		Name string `json:"name"`
+	} `json:"branch"`
+	Commit struct {
+		Hash string `json:"hash"`
+	} `json:"commit"`
+}
+
+type cloudCommits struct {
+	Values []struct {
+		Hash    string `json:"hash"`
+		Message string `json:"message"`
+		Author  struct {
+			// Raw is "Name <email>".
+			Raw string `json:"raw"`
+			// User is set when Bitbucket linked the commit to an account.
+			User *cloudUser `json:"user"`
+		} `json:"author"`
+	} `json:"values"`
+	Next string `json:"next"`
+}
+
+type cloudComment struct {
+	ID      int64 `json:"id"`
+	Content struct {
+		Raw string `json:"raw"`
+	} `json:"content"`
+	Inline  *cloudInline `json:"inline"`
+	Deleted bool         `json:"deleted"`
+}
+
+type cloudComments struct {
+	Values []cloudComment `json:"values"`
+	Next   string         `json:"next"`
+}
+
+// cloudInline anchors a comment to a line of the new file with To, or of the old file with From for removed
+// lines.
+type cloudInline struct {
+	Path string `json:"path"`
+	From int    `json:"from,omitempty"`
+	To   int    `json:"to,omitempty"`
+}
+
+func (c *Cloud) GetPullRequest(ctx context.Context, owner, repo string, number int) (*github.PullRequest, error) {
+	var pr cloudPullRequest
+	if err := c.get(ctx, cloudPullRequestPath(owner, repo, number), &pr); err != nil {
+		return nil, fmt.Errorf("error getting pull request #%d: %w", number, err)
+	}
+	return pr.pullRequest(), nil
+}
+
+// GetPullRequestChanges returns the commits and the files of the raw diff of the pull request.
+func (c *Cloud) GetPullRequestChanges(ctx context.Context, owner, repo string, pr *github.PullRequest) (*github.CommitsComparison, ghClient.Coverage, error) {
+	files, err := c.files(ctx, owner, repo, pr.GetNumber())
+	if err != nil {
+		return nil, ghClient.Coverage{}, err
+	}
+
+	var commits []*github.RepositoryCommit
+	next := cloudPullRequestPath(owner, repo, pr.GetNumber()) + "/commits"
+	for next != "" {
+		var page cloudCommits
+		if err := c.get(ctx, next, &page); err != nil {
+			return nil, ghClient.Coverage{}, fmt.Errorf("error listing commits of pull request #%d: %w", pr.GetNumber(), err)
+		}
+		for _, cm := range page.Values {
+			name, email := parseAuthor(cm.Author.Raw)
+			commit := &github.RepositoryCommit{
+				SHA: github.String(cm.Hash),
+				Commit: &github.Commit{
+					Message: github.String(cm.Message),
+					Author:  &github.CommitAuthor{Name: github.String(name), Email: github.String(email)},
+				},
+			}
+			if cm.Author.User != nil {
+				commit.Author = &github.User{Login: github.String(cm.Author.User.Nickname)}
+			}
+			commits = append(commits, commit)
+		}
+		next = page.Next
+	}
+	reverse(commits)
+
+	diff := &github.CommitsComparison{Commits: commits, Files: files}
+	return diff, ghClient.Coverage{ChangedFiles: len(files), AnalyzedFiles: len(files)}, nil
+}
+
+func (c *Cloud) UpdatePullRequest(ctx context.Context, owner, repo string, number int, pr *github.PullRequest) (*github.PullRequest, error) {
+	update := map[string]string{}
+	if pr.Title != nil {
+		update["title"] = pr.GetTitle()
+	}
+	if pr.Body != nil {
+		update["description"] = pr.GetBody()
+	}
+
+	var updated cloudPullRequest
+	if err := c.send(ctx, http.MethodPut, cloudPullRequestPath(owner, repo, number), update, &updated); err != nil {
+		return nil, fmt.Errorf("error updating pull request #%d: %w", number, err)
+	}
+	return updated.pullRequest(), nil
+}
+
+// CreatePullRequestComment comments on the line of the diff at the position of the comment.
+func (c *Cloud) CreatePullRequestComment(ctx context.Context, owner, repo string, number int, comment *github.PullRequestComment) (*github.PullRequestComment, error) {
+	files, err := c.files(ctx, owner, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	file, err := findFile(files, comment.GetPath())
+	if err != nil {
+		return nil, fmt.Errorf("error creating comment: %w", err)
+	}
+	line, err := codehost.LineAt(file.GetPatch(), comment.GetPosition())
+	if err != nil {
+		return nil, fmt.Errorf("error creating comment on %s: %w", comment.GetPath(), err)
+	}
+
+	inline := cloudInline{Path: comment.GetPath(), To: line.New}
+	if line.New == 0 {
+		inline.From = line.Old
+	}
+	payload := map[string]interface{}{
+		"content": map[string]string{"raw": comment.GetBody()},
+		"inline":  inline,
+	}
+
+	var created cloudComment
+	if err := c.send(ctx, http.MethodPost, cloudPullRequestPath(owner, repo, number)+"/comments", payload, &created); err != nil {
+		return nil, fmt.Errorf("error creating comment: %w", err)
+	}
+	return &github.PullRequestComment{ID: github.Int64(created.ID), Path: comment.Path, Position: comment.Position, Body: comment.Body}, nil
+}
+
+func (c *Cloud) CreateIssueComment(ctx context.Context, owner, repo string, number int, comment *github.IssueComment) (*github.IssueComment, error) {
+	payload := map[string]interface{}{"content": map[string]string{"raw": comment.GetBody()}}
+
+	var created cloudComment
+	if err := c.send(ctx, http.MethodPost, cloudPullRequestPath(owner, repo, number)+"/comments", payload, &created); err != nil {
+		return nil, fmt.Errorf("error creating comment: %w", err)
+	}
+	return &github.IssueComment{ID: github.Int64(created.ID), Body: github.String(created.Content.Raw)}, nil
+}
+
+// ListIssueComments returns the comments on the pull request that are not anchored to a line, oldest first.
+func (c *Cloud) ListIssueComments(ctx context.Context, owner, repo string, number int) ([]*github.IssueComment, error) {
+	var comments []*github.IssueComment
+	next := cloudPullRequestPath(owner, repo, number) + "/comments?pagelen=100"
+	for next != "" {
+		var page cloudComments
+		if err := c.get(ctx, next, &page); err != nil {
+			return nil, fmt.Errorf("error listing comments of pull request #%d: %w", number, err)
+		}
+		for _, comment := range page.Values {
+			if comment.Inline != nil || comment.Deleted {
+				continue
+			}
+			comments = append(comments, &github.IssueComment{ID: github.Int64(comment.ID), Body: github.String(comment.Content.Raw)})
+		}
+		next = page.Next
+	}
+	return comments, nil
+}
+
+func (c *Cloud) EditIssueComment(ctx context.Context, owner, repo string, number int, id int64, comment *github.IssueComment) (*github.IssueComment, error) {
+	payload := map[string]interface{}{"content": map[string]string{"raw": comment.GetBody()}}
+
+	var updated cloudComment
+	path := cloudPullRequestPath(owner, repo, number) + "/comments/" + strconv.FormatInt(id, 10)
+	if err := c.send(ctx, http.MethodPut, path, payload, &updated); err != nil {
+		return nil, fmt.Errorf("error updating comment: %w", err)
+	}
+	return &github.IssueComment{ID: github.Int64(updated.ID), Body: github.String(updated.Content.Raw)}, nil
+}
+
+// files returns the changed files of the raw diff of the pull request.
+func (c *Cloud) files(ctx context.Context, owner, repo string, number int) ([]*github.CommitFile, error) {
+	return c.cachedFiles(fmt.Sprintf("%s/%s#%d", owner, repo, number), func() ([]*github.CommitFile, error) {
+		raw, err := c.do(ctx, http.MethodGet, cloudPullRequestPath(owner, repo, number)+"/diff", nil, "text/plain")
+		if err != nil {
+			return nil, fmt.Errorf("error getting diff of pull request #%d: %w", number, err)
+		}
+		return ghClient.SplitDiff(string(raw)), nil
+	})
+}
+
+func cloudPullRequestPath(owner, repo string, number int) string {
+	return "/repositories/" + url.PathEscape(owner) + "/" + url.PathEscape(repo) + "/pullrequests/" + strconv.Itoa(number)
+}
+
+func (pr *cloudPullRequest) pullRequest() *github.PullRequest {
+	state := "closed"
+	if pr.State == "OPEN" {
+		state = "open"
+	}
+
+	return &github.PullRequest{
+		Number:  github.Int(pr.ID),
+		Title:   github.String(pr.Title),
+		Body:    github.String(pr.Description),
+		State:   github.String(state),
+		Merged:  github.Bool(pr.State == "MERGED"),
+		HTMLURL: github.String(pr.Links.HTML.Href),
+		User:    &github.User{Login: github.String(pr.Author.Nickname), Name: github.String(pr.Author.DisplayName)},
+		Head:    &github.PullRequestBranch{Ref: github.String(pr.Source.Branch.Name), SHA: github.String(pr.Source.Commit.Hash)},
+		Base:    &github.PullRequestBranch{Ref: github.String(pr.Destination.Branch.Name), SHA: github.String(pr.Destination.Commit.Hash)},
+	}
+}
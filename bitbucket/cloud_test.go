@@ -0,0 +1,247 @@
+package bitbucket
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-github/v51/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ghClient "github.com/ravilushqa/gpt-pullrequest-updater/github"
+)
+
+const (
+	cloudPRPath = "/repositories/acme/payments/pullrequests/7"
+	cloudPRJS   = `{
+		"id": 7, "title": "Round totals", "description": "Body", "state": "MERGED", "author": {"nickname": "jane", "display_name": "Jane Doe"},
+		"source": {"branch": {"name": "feature/round"}, "commit": {"hash": "head"}},
+		"destination": {"branch": {"name": "main"}, "commit": {"hash": "base"}},
+		"links": {"html": {"href": "https://bitbucket.org/acme/payments/pull-requests/7"}}
+	}`
+	cloudDiff = "diff --git a/round.go b/round.go\n" +
+		"--- a/round.go\n+++ b/round.go\n" +
+		"@@ -1,3 +1,3 @@\n package pay\n-var mode = 1\n+var mode = 2\n" +
+		"diff --git a/logo.png b/logo.png\nnew file mode 100644\nBinary files /dev/null and b/logo.png differ\n"
+)
+
+// fakeCloud serves a single pull request and records the requests that change it.
+type fakeCloud struct {
+	t        *testing.T
+	requests map[string]map[string]interface{}
+}
+
+func (f *fakeCloud) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	username, password, ok := r.BasicAuth()
+	assert.True(f.t, ok)
+	assert.Equal(f.t, "jane", username)
+	assert.Equal(f.t, "secret", password)
+
+	record := func() {
+		var payload map[string]interface{}
+		require.NoError(f.t, json.NewDecoder(r.Body).Decode(&payload))
+		f.requests[r.Method+" "+r.URL.Path] = payload
+	}
+
+	switch r.Method + " " + r.URL.Path {
+	case "GET " + cloudPRPath:
+		_, _ = w.Write([]byte(cloudPRJS))
+	case "PUT " + cloudPRPath:
+		record()
+		_, _ = w.Write([]byte(cloudPRJS))
+	case "GET " + cloudPRPath + "/diff":
+		assert.Equal(f.t, "text/plain", r.Header.Get("Accept"))
+		_, _ = w.Write([]byte(cloudDiff))
+	case "GET " + cloudPRPath + "/commits":
+		if r.URL.Query().Get("page") == "2" {
+			_, _ = w.Write([]byte(`{"values": [{"hash": "c1", "message": "Add rounding", "author": {"raw": "Jane Doe <jane@example.com>", "user": {"nickname": "jane"}}}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"values": [{"hash": "c2", "message": "Fix tests", "author": {"raw": "Bob <bob@example.com>"}}], "next": "http://` + r.Host + cloudPRPath + `/commits?page=2"}`))
+	case "POST " + cloudPRPath + "/comments":
+		record()
+		_, _ = w.Write([]byte(`{"id": 11, "content": {"raw": "Summary"}}`))
+	case "GET " + cloudPRPath + "/comments":
+		if r.URL.Query().Get("page") == "2" {
+			_, _ = w.Write([]byte(`{"values": [{"id": 11, "content": {"raw": "Summary"}}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"values": [
+			{"id": 9, "content": {"raw": "Looks good"}},
+			{"id": 10, "content": {"raw": "[bug] Wrong mode"}, "inline": {"path": "round.go", "to": 2}},
+			{"id": 12, "content": {"raw": ""}, "deleted": true}
+		], "next": "http://` + r.Host + cloudPRPath + `/comments?page=2"}`))
+	case "PUT " + cloudPRPath + "/comments/11":
+		record()
+		_, _ = w.Write([]byte(`{"id": 11, "content": {"raw": "New summary"}}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"type": "error", "error": {"message": "Resource not found"}}`))
+	}
+}
+
+func newTestCloud(t *testing.T) (*Cloud, *fakeCloud) {
+	fake := &fakeCloud{t: t, requests: map[string]map[string]interface{}{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client, err := NewCloud(Config{BaseURL: server.URL + "/", Username: "jane", Token: "secret"})
+	require.NoError(t, err)
+	return client, fake
+}
+
+func TestCloudGetPullRequest(t *testing.T) {
+	client, _ := newTestCloud(t)
+
+	pr, err := client.GetPullRequest(context.Background(), "acme", "payments", 7)
+	require.NoError(t, err)
+
+	assert.Equal(t, &github.PullRequest{
+		Number:  github.Int(7),
+		Title:   github.String("Round totals"),
+		Body:    github.String("Body"),
+		State:   github.String("closed"),
+		Merged:  github.Bool(true),
+		HTMLURL: github.String("https://bitbucket.org/acme/payments/pull-requests/7"),
+		User:    &github.User{Login: github.String("jane"), Name: github.String("Jane Doe")},
+		Head:    &github.PullRequestBranch{Ref: github.String("feature/round"), SHA: github.String("head")},
+		Base:    &github.PullRequestBranch{Ref: github.String("main"), SHA: github.String("base")},
+	}, pr)
+
+	_, err = client.GetPullRequest(context.Background(), "acme", "payments", 8)
+	assert.ErrorContains(t, err, "status code: 404, message: Resource not found")
+}
+
+func TestCloudGetPullRequestChanges(t *testing.T) {
+	client, _ := newTestCloud(t)
+
+	diff, coverage, err := client.GetPullRequestChanges(context.Background(), "acme", "payments", &github.PullRequest{Number: github.Int(7)})
+	require.NoError(t, err)
+	assert.Equal(t, ghClient.Coverage{ChangedFiles: 2, AnalyzedFiles: 2}, coverage)
+
+	require.Len(t, diff.Commits, 2)
+	assert.Equal(t, "c1", diff.Commits[0].GetSHA())
+	assert.Equal(t, "jane", diff.Commits[0].GetAuthor().GetLogin())
+	assert.Equal(t, &github.CommitAuthor{Name: github.String("Jane Doe"), Email: github.String("jane@example.com")}, diff.Commits[0].GetCommit().GetAuthor())
+	assert.Equal(t, "Fix tests", diff.Commits[1].GetCommit().GetMessage())
+	assert.Nil(t, diff.Commits[1].Author)
+	assert.Equal(t, &github.CommitAuthor{Name: github.String("Bob"), Email: github.String("bob@example.com")}, diff.Commits[1].GetCommit().GetAuthor())
+
+	require.Len(t, diff.Files, 2)
+	assert.Equal(t, "round.go", diff.Files[0].GetFilename())
+	assert.Equal(t, "@@ -1,3 +1,3 @@\n package pay\n-var mode = 1\n+var mode = 2", diff.Files[0].GetPatch())
+	assert.Equal(t, "logo.png", diff.Files[1].GetFilename())
+	assert.Equal(t, "added", diff.Files[1].GetStatus())
+}
+
+func TestCloudUpdatePullRequest(t *testing.T) {
+	client, fake := newTestCloud(t)
+
+	_, err := client.UpdatePullRequest(context.Background(), "acme", "payments", 7, &github.PullRequest{Body: github.String("New body")})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{"description": "New body"}, fake.requests["PUT "+cloudPRPath])
+}
+
+func TestCloudCreatePullRequestComment(t *testing.T) {
+	testCases := []struct {
+		name      string
+		path      string
+		position  int
+		expected  map[string]interface{}
+		expectErr bool
+	}{
+		{name: "Added line", path: "round.go", position: 3, expected: map[string]interface{}{"path": "round.go", "to": float64(2)}},
+		{name: "Removed line", path: "round.go", position: 2, expected: map[string]interface{}{"path": "round.go", "from": float64(2)}},
+		{name: "Context line", path: "round.go", position: 1, expected: map[string]interface{}{"path": "round.go", "to": float64(1)}},
+		{name: "Outside of the patch", path: "round.go", position: 9, expectErr: true},
+		{name: "Unchanged file", path: "main.go", position: 1, expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, fake := newTestCloud(t)
+
+			comment, err := client.CreatePullRequestComment(context.Background(), "acme", "payments", 7, &github.PullRequestComment{
+				Path:     github.String(tc.path),
+				Position: github.Int(tc.position),
+				Body:     github.String("[bug] Wrong mode"),
+			})
+			if tc.expectErr {
+				assert.Error(t, err)
+				assert.Empty(t, fake.requests)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, int64(11), comment.GetID())
+
+			payload := fake.requests["POST "+cloudPRPath+"/comments"]
+			assert.Equal(t, map[string]interface{}{"raw": "[bug] Wrong mode"}, payload["content"])
+			assert.Equal(t, tc.expected, payload["inline"])
+		})
+	}
+}
+
+func TestCloudCreateIssueComment(t *testing.T) {
+	client, fake := newTestCloud(t)
+
+	comment, err := client.CreateIssueComment(context.Background(), "acme", "payments", 7, &github.IssueComment{Body: github.String("Summary")})
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(11), comment.GetID())
+	assert.Equal(t, map[string]interface{}{"content": map[string]interface{}{"raw": "Summary"}}, fake.requests["POST "+cloudPRPath+"/comments"])
+}
+
+func TestCloudListIssueComments(t *testing.T) {
+	client, _ := newTestCloud(t)
+
+	comments, err := client.ListIssueComments(context.Background(), "acme", "payments", 7)
+	require.NoError(t, err)
+
+	assert.Equal(t, []*github.IssueComment{
+		{ID: github.Int64(9), Body: github.String("Looks good")},
+		{ID: github.Int64(11), Body: github.String("Summary")},
+	}, comments)
+}
+
+func TestCloudEditIssueComment(t *testing.T) {
+	client, fake := newTestCloud(t)
+
+	comment, err := client.EditIssueComment(context.Background(), "acme", "payments", 7, 11, &github.IssueComment{Body: github.String("New summary")})
+	require.NoError(t, err)
+
+	assert.Equal(t, "New summary", comment.GetBody())
+	assert.Equal(t, map[string]interface{}{"content": map[string]interface{}{"raw": "New summary"}}, fake.requests["PUT "+cloudPRPath+"/comments/11"])
+}
+
+func TestParseAuthor(t *testing.T) {
+	testCases := []struct {
+		raw   string
+		name  string
+		email string
+	}{
+		{raw: "Jane Q. Doe <jane@example.com>", name: "Jane Q. Doe", email: "jane@example.com"},
+		{raw: "<jane@example.com>", email: "jane@example.com"},
+		{raw: "jane", name: "jane"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.raw, func(t *testing.T) {
+			name, email := parseAuthor(tc.raw)
+			assert.Equal(t, tc.name, name)
+			assert.Equal(t, tc.email, email)
+		})
+	}
+}
+
+func TestNewCloud(t *testing.T) {
+	_, err := NewCloud(Config{})
+	assert.Error(t, err)
+
+	client, err := NewCloud(Config{Token: "secret"})
+	require.NoError(t, err)
+	assert.Equal(t, CloudURL, client.baseURL)
+}
@@ -0,0 +1,414 @@
+package bitbucket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v51/github"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/codehost"
+	ghClient "github.com/ravilushqa/gpt-pullrequest-updater/github"
+)
+
+// DataCenter implements codehost.Host for Bitbucket Data Center and Server through the REST API 1.0. Owner is
+// the project key and repo the repository slug.
+type DataCenter struct {
+	*client
+}
+
+var _ codehost.Host = (*DataCenter)(nil)
+
+func NewDataCenter(cfg Config) (*DataCenter, error) {
+	if cfg.BaseURL == "" {
+		return nil, errors.New("bitbucket url is required")
+	}
+	cfg.BaseURL = strings.TrimSuffix(strings.TrimSuffix(cfg.BaseURL, "/"), "/rest/api/1.0") + "/rest/api/1.0"
+	c, err := newClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &DataCenter{c}, nil
+}
+
+type dcPullRequest struct {
+	ID          int    `json:"id"`
+	Version     int    `json:"version"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	// State is one of OPEN, MERGED and DECLINED.
+	State  string `json:"state"`
+	Author struct {
+		User struct {
+			Slug         string `json:"slug"`
+			DisplayName  string `json:"displayName"`
+			EmailAddress string `json:"emailAddress"`
+		} `json:"user"`
+	} `json:"author"`
+	FromRef dcRef `json:"fromRef"`
+	ToRef   dcRef `json:"toRef"`
+	Links   struct {
+		Self []struct {
+			Href string `json:"href"`
+		} `json:"self"`
+	} `json:"links"`
+}
+
+type dcRef struct {
+	DisplayID    string `json:"displayId"`
+	LatestCommit string `json:"latestCommit"`
+}
+
+type dcCommits struct {
+	Values []struct {
+		ID      string `json:"id"`
+		Message string `json:"message"`
+		Author  struct {
+			Name         string `json:"name"`
+			EmailAddress string `json:"emailAddress"`
+			// Slug is set when Bitbucket linked the commit to a user.
+			Slug string `json:"slug"`
+		} `json:"author"`
+	} `json:"values"`
+	IsLastPage    bool `json:"isLastPage"`
+	NextPageStart int  `json:"nextPageStart"`
+}
+
+// dcDiffs is the diff model of Data Center: files are split into hunks, hunks into segments of added,
+// removed or context lines, and every line knows its line number in the source and the destination file.
+type dcDiffs struct {
+	Diffs []dcDiff `json:"diffs"`
+	// Truncated is set when files were left out.
+	Truncated bool `json:"truncated"`
+}
+
+type dcDiff struct {
+	Source      *dcPath `json:"source"`
+	Destination *dcPath `json:"destination"`
+	Hunks       []struct {
+		SourceLine      int `json:"sourceLine"`
+		SourceSpan      int `json:"sourceSpan"`
+		DestinationLine int `json:"destinationLine"`
+		DestinationSpan int `json:"destinationSpan"`
+		Segments        []struct {
+			// Type is one of ADDED, REMOVED and CONTEXT.
+			Type  string `json:"type"`
+			Lines []struct {
+				Line string `json:"line"`
+			} `json:"lines"`
+		} `json:"segments"`
+	} `json:"hunks"`
+	// Truncated is set when lines of the file were left out.
+	Truncated bool `json:"truncated"`
+}
+
+type dcPath struct {
+	ToString string `json:"toString"`
+}
+
+type dcComment struct {
+	ID      int64  `json:"id"`
+	Version int    `json:"version"`
+	Text    string `json:"text"`
+	// Anchor is set for comments on a line of the diff.
+	Anchor *dcAnchor `json:"anchor"`
+}
+
+// dcActivities lists what happened on a pull request. Data Center has no endpoint listing all comments.
+type dcActivities struct {
+	Values []struct {
+		// Action is COMMENTED for comments, other actions are e.g. OPENED and APPROVED.
+		Action  string     `json:"action"`
+		Comment *dcComment `json:"comment"`
+	} `json:"values"`
+	IsLastPage    bool `json:"isLastPage"`
+	NextPageStart int  `json:"nextPageStart"`
+}
+
+// dcAnchor anchors a comment to a line of the diff.
+type dcAnchor struct {
+	Line int `json:"line"`
+	// LineType is one of ADDED, REMOVED and CONTEXT.
+	LineType string `json:"lineType"`
+	// FileType is TO for lines of the new file and FROM for removed lines.
+	FileType string `json:"fileType"`
+	Path     string `json:"path"`
+	SrcPath  string `json:"srcPath,omitempty"`
+	DiffType string `json:"diffType"`
+}
+
+func (c *DataCenter) GetPullRequest(ctx context.Context, owner, repo string, number int) (*github.PullRequest, error) {
+	pr, err := c.getPullRequest(ctx, owner, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	return pr.pullRequest(), nil
+}
+
+// GetPullRequestChanges returns the commits and the diff of the pull request. Files and lines Bitbucket leaves
+// out of large diffs are reported by the coverage.
+func (c *DataCenter) GetPullRequestChanges(ctx context.Context, owner, repo string, pr *github.PullRequest) (*github.CommitsComparison, ghClient.Coverage, error) {
+	var diffs dcDiffs
+	if err := c.get(ctx, dcPullRequestPath(owner, repo, pr.GetNumber())+"/diff?contextLines=3&withComments=false", &diffs); err != nil {
+		return nil, ghClient.Coverage{}, fmt.Errorf("error getting diff of pull request #%d: %w", pr.GetNumber(), err)
+	}
+
+	var commits []*github.RepositoryCommit
+	for start := 0; ; {
+		var page dcCommits
+		if err := c.get(ctx, dcPullRequestPath(owner, repo, pr.GetNumber())+"/commits?limit=100&start="+strconv.Itoa(start), &page); err != nil {
+			return nil, ghClient.Coverage{}, fmt.Errorf("error listing commits of pull request #%d: %w", pr.GetNumber(), err)
+		}
+		for _, cm := range page.Values {
+			commit := &github.RepositoryCommit{
+				SHA: github.String(cm.ID),
+				Commit: &github.Commit{
+					Message: github.String(cm.Message),
+					Author:  &github.CommitAuthor{Name: github.String(cm.Author.Name), Email: github.String(cm.Author.EmailAddress)},
+				},
+			}
+			if cm.Author.Slug != "" {
+				commit.Author = &github.User{Login: github.String(cm.Author.Slug)}
+			}
+			commits = append(commits, commit)
+		}
+		if page.IsLastPage || len(page.Values) == 0 {
+			break
+		}
+		start = page.NextPageStart
+	}
+	reverse(commits)
+
+	diff := &github.CommitsComparison{Commits: commits}
+	coverage := ghClient.Coverage{ChangedFiles: len(diffs.Diffs)}
+	for _, d := range diffs.Diffs {
+		file := d.commitFile()
+		diff.Files = append(diff.Files, file)
+		if d.Truncated {
+			coverage.MissingFiles = append(coverage.MissingFiles, file.GetFilename())
+		} else {
+			coverage.AnalyzedFiles++
+		}
+	}
+	if diffs.Truncated {
+		// the number of files left out is unknown
+		coverage.ChangedFiles++
+	}
+
+	c.cacheFiles(dcKey(owner, repo, pr.GetNumber()), diff.Files)
+
+	return diff, coverage, nil
+}
+
+// UpdatePullRequest updates the title and the description. Bitbucket requires the current version of the pull
+// request, so it is read first.
+func (c *DataCenter) UpdatePullRequest(ctx context.Context, owner, repo string, number int, pr *github.PullRequest) (*github.PullRequest, error) {
+	current, err := c.getPullRequest(ctx, owner, repo, number)
+	if err != nil {
+		return nil, err
+	}
+
+	update := map[string]interface{}{
+		"version":     current.Version,
+		"title":       current.Title,
+		"description": current.Description,
+	}
+	if pr.Title != nil {
+		update["title"] = pr.GetTitle()
+	}
+	if pr.Body != nil {
+		update["description"] = pr.GetBody()
+	}
+
+	var updated dcPullRequest
+	if err := c.send(ctx, http.MethodPut, dcPullRequestPath(owner, repo, number), update, &updated); err != nil {
+		return nil, fmt.Errorf("error updating pull request #%d: %w", number, err)
+	}
+	return updated.pullRequest(), nil
+}
+
+// CreatePullRequestComment comments on the line of the diff at the position of the comment.
+func (c *DataCenter) CreatePullRequestComment(ctx context.Context, owner, repo string, number int, comment *github.PullRequestComment) (*github.PullRequestComment, error) {
+	files, err := c.cachedFiles(dcKey(owner, repo, number), func() ([]*github.CommitFile, error) {
+		diff, _, err := c.GetPullRequestChanges(ctx, owner, repo, &github.PullRequest{Number: github.Int(number)})
+		if err != nil {
+			return nil, err
+		}
+		return diff.Files, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	file, err := findFile(files, comment.GetPath())
+	if err != nil {
+		return nil, fmt.Errorf("error creating comment: %w", err)
+	}
+	line, err := codehost.LineAt(file.GetPatch(), comment.GetPosition())
+	if err != nil {
+		return nil, fmt.Errorf("error creating comment on %s: %w", comment.GetPath(), err)
+	}
+
+	anchor := dcAnchor{Path: file.GetFilename(), SrcPath: file.GetPreviousFilename(), DiffType: "EFFECTIVE"}
+	switch {
+	case line.Old == 0:
+		anchor.Line, anchor.LineType, anchor.FileType = line.New, "ADDED", "TO"
+	case line.New == 0:
+		anchor.Line, anchor.LineType, anchor.FileType = line.Old, "REMOVED", "FROM"
+	default:
+		anchor.Line, anchor.LineType, anchor.FileType = line.New, "CONTEXT", "TO"
+	}
+
+	var created dcComment
+	payload := map[string]interface{}{"text": comment.GetBody(), "anchor": anchor}
+	if err := c.send(ctx, http.MethodPost, dcPullRequestPath(owner, repo, number)+"/comments", payload, &created); err != nil {
+		return nil, fmt.Errorf("error creating comment: %w", err)
+	}
+	return &github.PullRequestComment{ID: github.Int64(created.ID), Path: comment.Path, Position: comment.Position, Body: comment.Body}, nil
+}
+
+func (c *DataCenter) CreateIssueComment(ctx context.Context, owner, repo string, number int, comment *github.IssueComment) (*github.IssueComment, error) {
+	var created dcComment
+	if err := c.send(ctx, http.MethodPost, dcPullRequestPath(owner, repo, number)+"/comments", map[string]string{"text": comment.GetBody()}, &created); err != nil {
+		return nil, fmt.Errorf("error creating comment: %w", err)
+	}
+	return &github.IssueComment{ID: github.Int64(created.ID), Body: github.String(created.Text)}, nil
+}
+
+// ListIssueComments returns the comments on the pull request that are not anchored to a line, oldest first.
+func (c *DataCenter) ListIssueComments(ctx context.Context, owner, repo string, number int) ([]*github.IssueComment, error) {
+	var comments []*github.IssueComment
+	start := 0
+	for {
+		var page dcActivities
+		path := fmt.Sprintf("%s/activities?limit=100&start=%d", dcPullRequestPath(owner, repo, number), start)
+		if err := c.get(ctx, path, &page); err != nil {
+			return nil, fmt.Errorf("error listing comments of pull request #%d: %w", number, err)
+		}
+		for _, activity := range page.Values {
+			if activity.Action != "COMMENTED" || activity.Comment == nil || activity.Comment.Anchor != nil {
+				continue
+			}
+			comments = append(comments, &github.IssueComment{ID: github.Int64(activity.Comment.ID), Body: github.String(activity.Comment.Text)})
+		}
+		if page.IsLastPage || len(page.Values) == 0 {
+			break
+		}
+		start = page.NextPageStart
+	}
+
+	// activities are listed newest first
+	for i, j := 0, len(comments)-1; i < j; i, j = i+1, j-1 {
+		comments[i], comments[j] = comments[j], comments[i]
+	}
+	return comments, nil
+}
+
+// EditIssueComment replaces the text of a comment. Data Center rejects updates without the current version of
+// the comment, so it is fetched first.
+func (c *DataCenter) EditIssueComment(ctx context.Context, owner, repo string, number int, id int64, comment *github.IssueComment) (*github.IssueComment, error) {
+	path := dcPullRequestPath(owner, repo, number) + "/comments/" + strconv.FormatInt(id, 10)
+	var current dcComment
+	if err := c.get(ctx, path, &current); err != nil {
+		return nil, fmt.Errorf("error getting comment: %w", err)
+	}
+
+	var updated dcComment
+	payload := map[string]interface{}{"version": current.Version, "text": comment.GetBody()}
+	if err := c.send(ctx, http.MethodPut, path, payload, &updated); err != nil {
+		return nil, fmt.Errorf("error updating comment: %w", err)
+	}
+	return &github.IssueComment{ID: github.Int64(updated.ID), Body: github.String(updated.Text)}, nil
+}
+
+func (c *DataCenter) getPullRequest(ctx context.Context, owner, repo string, number int) (*dcPullRequest, error) {
+	var pr dcPullRequest
+	if err := c.get(ctx, dcPullRequestPath(owner, repo, number), &pr); err != nil {
+		return nil, fmt.Errorf("error getting pull request #%d: %w", number, err)
+	}
+	return &pr, nil
+}
+
+func dcPullRequestPath(owner, repo string, number int) string {
+	return "/projects/" + url.PathEscape(owner) + "/repos/" + url.PathEscape(repo) + "/pull-requests/" + strconv.Itoa(number)
+}
+
+func dcKey(owner, repo string, number int) string {
+	return fmt.Sprintf("%s/%s#%d", owner, repo, number)
+}
+
+func (pr *dcPullRequest) pullRequest() *github.PullRequest {
+	state := "closed"
+	if pr.State == "OPEN" {
+		state = "open"
+	}
+
+	p := &github.PullRequest{
+		Number: github.Int(pr.ID),
+		Title:  github.String(pr.Title),
+		Body:   github.String(pr.Description),
+		State:  github.String(state),
+		Merged: github.Bool(pr.State == "MERGED"),
+		User: &github.User{
+			Login: github.String(pr.Author.User.Slug),
+			Name:  github.String(pr.Author.User.DisplayName),
+			Email: github.String(pr.Author.User.EmailAddress),
+		},
+		Head: &github.PullRequestBranch{Ref: github.String(pr.FromRef.DisplayID), SHA: github.String(pr.FromRef.LatestCommit)},
+		Base: &github.PullRequestBranch{Ref: github.String(pr.ToRef.DisplayID), SHA: github.String(pr.ToRef.LatestCommit)},
+	}
+	if len(pr.Links.Self) > 0 {
+		p.HTMLURL = github.String(pr.Links.Self[0].Href)
+	}
+	return p
+}
+
+// commitFile converts the diff model to a file with a unified patch, so review positions map back to the lines
+// of the model.
+func (d *dcDiff) commitFile() *github.CommitFile {
+	file := &github.CommitFile{Status: github.String("modified")}
+	switch {
+	case d.Source == nil:
+		file.Status = github.String("added")
+		file.Filename = github.String(d.Destination.ToString)
+	case d.Destination == nil:
+		file.Status = github.String("removed")
+		file.Filename = github.String(d.Source.ToString)
+	default:
+		file.Filename = github.String(d.Destination.ToString)
+		if d.Source.ToString != d.Destination.ToString {
+			file.Status = github.String("renamed")
+			file.PreviousFilename = github.String(d.Source.ToString)
+		}
+	}
+
+	var patch []string
+	additions, deletions := 0, 0
+	for _, hunk := range d.Hunks {
+		patch = append(patch, fmt.Sprintf("@@ -%d,%d +%d,%d @@", hunk.SourceLine, hunk.SourceSpan, hunk.DestinationLine, hunk.DestinationSpan))
+		for _, segment := range hunk.Segments {
+			prefix := " "
+			switch segment.Type {
+			case "ADDED":
+				prefix = "+"
+				additions += len(segment.Lines)
+			case "REMOVED":
+				prefix = "-"
+				deletions += len(segment.Lines)
+			}
+			for _, line := range segment.Lines {
+				patch = append(patch, prefix+line.Line)
+			}
+		}
+	}
+	if len(patch) > 0 {
+		file.Patch = github.String(strings.Join(patch, "\n"))
+	}
+	file.Additions = github.Int(additions)
+	file.Deletions = github.Int(deletions)
+	file.Changes = github.Int(additions + deletions)
+	return file
+}
@@ -0,0 +1,265 @@
+package bitbucket
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-github/v51/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ghClient "github.com/ravilushqa/gpt-pullrequest-updater/github"
+)
+
+const (
+	dcPRPath = "/rest/api/1.0/projects/PAY/repos/api/pull-requests/7"
+	dcPRJS   = `{
+		"id": 7, "version": 3, "title": "Round totals", "description": "Body", "state": "OPEN",
+		"author": {"user": {"slug": "jane", "displayName": "Jane Doe", "emailAddress": "jane@example.com"}},
+		"fromRef": {"displayId": "feature/round", "latestCommit": "head"},
+		"toRef": {"displayId": "main", "latestCommit": "base"},
+		"links": {"self": [{"href": "https://bitbucket.example.com/projects/PAY/repos/api/pull-requests/7"}]}
+	}`
+	dcDiffJS = `{"diffs": [
+		{
+			"source": {"toString": "round.go"}, "destination": {"toString": "round.go"},
+			"hunks": [{"sourceLine": 1, "sourceSpan": 3, "destinationLine": 1, "destinationSpan": 3, "segments": [
+				{"type": "CONTEXT", "lines": [{"source": 1, "destination": 1, "line": "package pay"}]},
+				{"type": "REMOVED", "lines": [{"source": 2, "destination": 2, "line": "var mode = 1"}]},
+				{"type": "ADDED", "lines": [{"source": 3, "destination": 2, "line": "var mode = 2"}]}
+			]}]
+		},
+		{"source": {"toString": "old.go"}, "destination": {"toString": "new.go"}},
+		{"source": null, "destination": {"toString": "data.csv"}, "truncated": true}
+	]`
+)
+
+// fakeDataCenter serves a single pull request and records the requests that change it.
+type fakeDataCenter struct {
+	t         *testing.T
+	truncated bool
+	requests  map[string]map[string]interface{}
+}
+
+func (f *fakeDataCenter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	assert.Equal(f.t, "Bearer secret", r.Header.Get("Authorization"))
+
+	record := func() {
+		var payload map[string]interface{}
+		require.NoError(f.t, json.NewDecoder(r.Body).Decode(&payload))
+		f.requests[r.Method+" "+r.URL.Path] = payload
+	}
+
+	switch r.Method + " " + r.URL.Path {
+	case "GET " + dcPRPath:
+		_, _ = w.Write([]byte(dcPRJS))
+	case "PUT " + dcPRPath:
+		record()
+		_, _ = w.Write([]byte(dcPRJS))
+	case "GET " + dcPRPath + "/diff":
+		truncated := "false"
+		if f.truncated {
+			truncated = "true"
+		}
+		_, _ = w.Write([]byte(dcDiffJS + `, "truncated": ` + truncated + `}`))
+	case "GET " + dcPRPath + "/commits":
+		if r.URL.Query().Get("start") == "1" {
+			_, _ = w.Write([]byte(`{"values": [{"id": "c1", "message": "Add rounding", "author": {"name": "Jane Doe", "emailAddress": "jane@example.com", "slug": "jane"}}], "isLastPage": true}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"values": [{"id": "c2", "message": "Fix tests", "author": {"name": "Bob", "emailAddress": "bob@example.com"}}], "isLastPage": false, "nextPageStart": 1}`))
+	case "POST " + dcPRPath + "/comments":
+		record()
+		_, _ = w.Write([]byte(`{"id": 11, "text": "Summary"}`))
+	case "GET " + dcPRPath + "/activities":
+		if r.URL.Query().Get("start") == "2" {
+			_, _ = w.Write([]byte(`{"values": [{"action": "COMMENTED", "comment": {"id": 9, "version": 0, "text": "Looks good"}}], "isLastPage": true}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"values": [
+			{"action": "COMMENTED", "comment": {"id": 11, "version": 2, "text": "Summary"}},
+			{"action": "COMMENTED", "comment": {"id": 10, "version": 0, "text": "[bug] Wrong mode", "anchor": {"path": "round.go", "line": 2}}},
+			{"action": "APPROVED"}
+		], "isLastPage": false, "nextPageStart": 2}`))
+	case "GET " + dcPRPath + "/comments/11":
+		_, _ = w.Write([]byte(`{"id": 11, "version": 2, "text": "Summary"}`))
+	case "PUT " + dcPRPath + "/comments/11":
+		record()
+		_, _ = w.Write([]byte(`{"id": 11, "version": 3, "text": "New summary"}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"errors": [{"message": "Pull request 8 does not exist."}]}`))
+	}
+}
+
+func newTestDataCenter(t *testing.T, truncated bool) (*DataCenter, *fakeDataCenter) {
+	fake := &fakeDataCenter{t: t, truncated: truncated, requests: map[string]map[string]interface{}{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client, err := NewDataCenter(Config{BaseURL: server.URL + "/", Token: "secret"})
+	require.NoError(t, err)
+	return client, fake
+}
+
+func TestDataCenterGetPullRequest(t *testing.T) {
+	client, _ := newTestDataCenter(t, false)
+
+	pr, err := client.GetPullRequest(context.Background(), "PAY", "api", 7)
+	require.NoError(t, err)
+
+	assert.Equal(t, &github.PullRequest{
+		Number:  github.Int(7),
+		Title:   github.String("Round totals"),
+		Body:    github.String("Body"),
+		State:   github.String("open"),
+		Merged:  github.Bool(false),
+		HTMLURL: github.String("https://bitbucket.example.com/projects/PAY/repos/api/pull-requests/7"),
+		User:    &github.User{Login: github.String("jane"), Name: github.String("Jane Doe"), Email: github.String("jane@example.com")},
+		Head:    &github.PullRequestBranch{Ref: github.String("feature/round"), SHA: github.String("head")},
+		Base:    &github.PullRequestBranch{Ref: github.String("main"), SHA: github.String("base")},
+	}, pr)
+
+	_, err = client.GetPullRequest(context.Background(), "PAY", "api", 8)
+	assert.ErrorContains(t, err, "status code: 404, message: Pull request 8 does not exist.")
+}
+
+func TestDataCenterGetPullRequestChanges(t *testing.T) {
+	testCases := []struct {
+		name      string
+		truncated bool
+		expected  ghClient.Coverage
+	}{
+		{name: "Truncated file", expected: ghClient.Coverage{ChangedFiles: 3, AnalyzedFiles: 2, MissingFiles: []string{"data.csv"}}},
+		{name: "Truncated listing", truncated: true, expected: ghClient.Coverage{ChangedFiles: 4, AnalyzedFiles: 2, MissingFiles: []string{"data.csv"}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, _ := newTestDataCenter(t, tc.truncated)
+
+			diff, coverage, err := client.GetPullRequestChanges(context.Background(), "PAY", "api", &github.PullRequest{Number: github.Int(7)})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, coverage)
+
+			require.Len(t, diff.Commits, 2)
+			assert.Equal(t, "c1", diff.Commits[0].GetSHA())
+			assert.Equal(t, "jane", diff.Commits[0].GetAuthor().GetLogin())
+			assert.Nil(t, diff.Commits[1].Author)
+			assert.Equal(t, "Fix tests", diff.Commits[1].GetCommit().GetMessage())
+
+			assert.Equal(t, []*github.CommitFile{
+				{Filename: github.String("round.go"), Status: github.String("modified"), Patch: github.String("@@ -1,3 +1,3 @@\n package pay\n-var mode = 1\n+var mode = 2"), Additions: github.Int(1), Deletions: github.Int(1), Changes: github.Int(2)},
+				{Filename: github.String("new.go"), PreviousFilename: github.String("old.go"), Status: github.String("renamed"), Additions: github.Int(0), Deletions: github.Int(0), Changes: github.Int(0)},
+				{Filename: github.String("data.csv"), Status: github.String("added"), Additions: github.Int(0), Deletions: github.Int(0), Changes: github.Int(0)},
+			}, diff.Files)
+		})
+	}
+}
+
+func TestDataCenterUpdatePullRequest(t *testing.T) {
+	client, fake := newTestDataCenter(t, false)
+
+	_, err := client.UpdatePullRequest(context.Background(), "PAY", "api", 7, &github.PullRequest{Body: github.String("New body")})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{"version": float64(3), "title": "Round totals", "description": "New body"}, fake.requests["PUT "+dcPRPath])
+}
+
+func TestDataCenterCreatePullRequestComment(t *testing.T) {
+	testCases := []struct {
+		name      string
+		path      string
+		position  int
+		expected  map[string]interface{}
+		expectErr bool
+	}{
+		{
+			name:     "Added line",
+			path:     "round.go",
+			position: 3,
+			expected: map[string]interface{}{"path": "round.go", "line": float64(2), "lineType": "ADDED", "fileType": "TO", "diffType": "EFFECTIVE"},
+		},
+		{
+			name:     "Removed line",
+			path:     "round.go",
+			position: 2,
+			expected: map[string]interface{}{"path": "round.go", "line": float64(2), "lineType": "REMOVED", "fileType": "FROM", "diffType": "EFFECTIVE"},
+		},
+		{
+			name:     "Context line",
+			path:     "round.go",
+			position: 1,
+			expected: map[string]interface{}{"path": "round.go", "line": float64(1), "lineType": "CONTEXT", "fileType": "TO", "diffType": "EFFECTIVE"},
+		},
+		{name: "Outside of the patch", path: "round.go", position: 9, expectErr: true},
+		{name: "Unchanged file", path: "main.go", position: 1, expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, fake := newTestDataCenter(t, false)
+
+			comment, err := client.CreatePullRequestComment(context.Background(), "PAY", "api", 7, &github.PullRequestComment{
+				Path:     github.String(tc.path),
+				Position: github.Int(tc.position),
+				Body:     github.String("[bug] Wrong mode"),
+			})
+			if tc.expectErr {
+				assert.Error(t, err)
+				assert.Empty(t, fake.requests)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, int64(11), comment.GetID())
+
+			payload := fake.requests["POST "+dcPRPath+"/comments"]
+			assert.Equal(t, "[bug] Wrong mode", payload["text"])
+			assert.Equal(t, tc.expected, payload["anchor"])
+		})
+	}
+}
+
+func TestDataCenterCreateIssueComment(t *testing.T) {
+	client, fake := newTestDataCenter(t, false)
+
+	comment, err := client.CreateIssueComment(context.Background(), "PAY", "api", 7, &github.IssueComment{Body: github.String("Summary")})
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(11), comment.GetID())
+	assert.Equal(t, map[string]interface{}{"text": "Summary"}, fake.requests["POST "+dcPRPath+"/comments"])
+}
+
+func TestDataCenterListIssueComments(t *testing.T) {
+	client, _ := newTestDataCenter(t, false)
+
+	comments, err := client.ListIssueComments(context.Background(), "PAY", "api", 7)
+	require.NoError(t, err)
+
+	assert.Equal(t, []*github.IssueComment{
+		{ID: github.Int64(9), Body: github.String("Looks good")},
+		{ID: github.Int64(11), Body: github.String("Summary")},
+	}, comments)
+}
+
+func TestDataCenterEditIssueComment(t *testing.T) {
+	client, fake := newTestDataCenter(t, false)
+
+	comment, err := client.EditIssueComment(context.Background(), "PAY", "api", 7, 11, &github.IssueComment{Body: github.String("New summary")})
+	require.NoError(t, err)
+
+	assert.Equal(t, "New summary", comment.GetBody())
+	assert.Equal(t, map[string]interface{}{"version": float64(2), "text": "New summary"}, fake.requests["PUT "+dcPRPath+"/comments/11"])
+}
+
+func TestNewDataCenter(t *testing.T) {
+	_, err := NewDataCenter(Config{Token: "secret"})
+	assert.Error(t, err)
+
+	client, err := NewDataCenter(Config{BaseURL: "https://bitbucket.example.com/rest/api/1.0/", Token: "secret"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://bitbucket.example.com/rest/api/1.0", client.baseURL)
+}
@@ -0,0 +1,221 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// PromptVersion identifies the prompts and the way they are built in cache keys.
+// Bump it whenever cached completions should no longer be reused.
+const PromptVersion = "1"
+
+const (
+	// DefaultTTL is how long a completion stays valid.
+	DefaultTTL = 7 * 24 * time.Hour
+	// DefaultMaxSize is the maximum size of the cache directory in bytes.
+	DefaultMaxSize = 100 << 20
+
+	fileExt = ".json"
+)
+
+// Cache is a content-addressed disk cache of completions. A nil Cache never hits.
+// The modification time of an entry is the time it was written, it decides both expiry and eviction.
+type Cache struct {
+	dir     string
+	ttl     time.Duration
+	maxSize int64
+	now     func() time.Time
+	evict   sync.Once
+}
+
+type entry struct {
+	Model      string `json:"model"`
+	Completion string `json:"completion"`
+}
+
+// Request is what a completion depends on besides the prompt version.
+type Request struct {
+	// Provider is e.g. openai, local or anthropic.
+	Provider string
+	// Endpoint is the base URL of the API, local servers may serve different models under the same name.
+	Endpoint    string
+	Model       string
+	Temperature float32
+	// MaxTokens limits the length of the completion. Zero when the provider default is used.
+	MaxTokens int
+	Messages  []openai.ChatCompletionMessage
+}
+
+// New creates a cache in dir. Zero ttl and maxSize mean DefaultTTL and DefaultMaxSize.
+func New(dir string, ttl time.Duration, maxSize int64) (*Cache, error) {
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+	if maxSize == 0 {
+		maxSize = DefaultMaxSize
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating cache dir: %w", err)
+	}
+
+	return &Cache{
+		dir:     dir,
+		ttl:     ttl,
+		maxSize: maxSize,
+		now:     time.Now,
+	}, nil
+}
+
+// Key returns the cache key of a completion request.
+func Key(req Request) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00%g\x00%d\x00", PromptVersion, req.Provider, req.Endpoint, req.Model, req.Temperature, req.MaxTokens)
+	for _, m := range req.Messages {
+		fmt.Fprintf(h, "%s\x00%d\x00%s\x00", m.Role, len(m.Content), m.Content)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Get returns the cached completion for key if it exists and has not expired.
+func (c *Cache) Get(key string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+
+	path := c.path(key)
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", false
+	}
+	if c.expired(info) {
+		_ = os.Remove(path)
+		return "", false
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		_ = os.Remove(path)
+		return "", false
+	}
+
+	return e.Completion, true
+}
+
+// Set stores a completion. The first write of a run evicts old entries, so the directory is walked only once.
+func (c *Cache) Set(key, model, completion string) error {
+	if c == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(entry{Model: model, Completion: completion})
+	if err != nil {
+		return fmt.Errorf("error encoding cache entry: %w", err)
+	}
+
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("error creating cache dir: %w", err)
+	}
+	// write to a temporary file first so concurrent readers never see a partial entry
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".tmp*")
+	if err != nil {
+		return fmt.Errorf("error writing cache entry: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error writing cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error writing cache entry: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error writing cache entry: %w", err)
+	}
+	now := c.now()
+	_ = os.Chtimes(path, now, now)
+
+	err = nil
+	c.evict.Do(func() { err = c.Evict() })
+	return err
+}
+
+// Evict removes expired entries, then the oldest ones until the cache fits its size limit.
+func (c *Cache) Evict() error {
+	if c == nil {
+		return nil
+	}
+
+	type file struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+
+	var files []file
+	var total int64
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, fileExt) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if c.expired(info) {
+			_ = os.Remove(path)
+			return nil
+		}
+		files = append(files, file{path: path, size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error reading cache dir: %w", err)
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, f := range files {
+		if total <= c.maxSize {
+			break
+		}
+		if err := os.Remove(f.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("error evicting cache entry: %w", err)
+		}
+		total -= f.size
+	}
+
+	return nil
+}
+
+func (c *Cache) expired(info fs.FileInfo) bool {
+	return c.now().Sub(info.ModTime()) > c.ttl
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+fileExt)
+}
@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKey(t *testing.T) {
+	messages := []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: "prompt"},
+		{Role: openai.ChatMessageRoleUser, Content: "patch"},
+	}
+	req := Request{Provider: "openai", Endpoint: "https://api.openai.com/v1", Model: "gpt-4", Temperature: 0.1, Messages: messages}
+	key := Key(req)
+
+	assert.Len(t, key, 64)
+	assert.Equal(t, key, Key(req))
+
+	testCases := []struct {
+		name   string
+		change func(r *Request)
+	}{
+		{name: "Provider", change: func(r *Request) { r.Provider = "local" }},
+		{name: "Endpoint", change: func(r *Request) { r.Endpoint = "http://localhost:11434/v1" }},
+		{name: "Model", change: func(r *Request) { r.Model = "gpt-3.5-turbo" }},
+		{name: "Temperature", change: func(r *Request) { r.Temperature = 0.2 }},
+		{name: "Max tokens", change: func(r *Request) { r.MaxTokens = 1024 }},
+		{name: "Roles", change: func(r *Request) {
+			r.Messages = []openai.ChatCompletionMessage{
+				{Role: openai.ChatMessageRoleUser, Content: "prompt"},
+				{Role: openai.ChatMessageRoleUser, Content: "patch"},
+			}
+		}},
+		{name: "Message boundaries", change: func(r *Request) {
+			r.Messages = []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleSystem, Content: "promptpatch"}}
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			changed := req
+			tc.change(&changed)
+			assert.NotEqual(t, key, Key(changed))
+		})
+	}
+}
+
+func TestGetSet(t *testing.T) {
+	c, err := New(t.TempDir(), time.Hour, 0)
+	require.NoError(t, err)
+	key := Key(Request{Model: "gpt-4"})
+
+	_, ok := c.Get(key)
+	assert.False(t, ok)
+
+	require.NoError(t, c.Set(key, "gpt-4", "completion"))
+	completion, ok := c.Get(key)
+	assert.True(t, ok)
+	assert.Equal(t, "completion", completion)
+}
+
+func TestTTL(t *testing.T) {
+	c, err := New(t.TempDir(), time.Hour, 0)
+	require.NoError(t, err)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	key := Key(Request{Model: "gpt-4"})
+	require.NoError(t, c.Set(key, "gpt-4", "completion"))
+
+	c.now = func() time.Time { return now.Add(2 * time.Hour) }
+	_, ok := c.Get(key)
+	assert.False(t, ok)
+	_, err = os.Stat(c.path(key))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestGetDoesNotExtendTTL(t *testing.T) {
+	c, err := New(t.TempDir(), time.Hour, 0)
+	require.NoError(t, err)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	key := Key(Request{Model: "gpt-4"})
+	require.NoError(t, c.Set(key, "gpt-4", "completion"))
+
+	c.now = func() time.Time { return now.Add(50 * time.Minute) }
+	_, ok := c.Get(key)
+	require.True(t, ok)
+
+	c.now = func() time.Time { return now.Add(70 * time.Minute) }
+	_, ok = c.Get(key)
+	assert.False(t, ok, "the entry expires an hour after it was written")
+}
+
+func TestEvictOncePerRun(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir, time.Hour, 1)
+	require.NoError(t, err)
+
+	first := Key(Request{Model: "gpt-4"})
+	second := Key(Request{Model: "gpt-3.5-turbo"})
+	require.NoError(t, c.Set(first, "gpt-4", "completion"))
+	require.NoError(t, c.Set(second, "gpt-3.5-turbo", "completion"))
+
+	// the first write evicted itself, the directory is not walked again for the second one
+	_, ok := c.Get(first)
+	assert.False(t, ok)
+	_, ok = c.Get(second)
+	assert.True(t, ok)
+}
+
+func TestSizeEviction(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir, time.Hour, 0)
+	require.NoError(t, err)
+
+	now := time.Now()
+	var keys []string
+	for i := 0; i < 3; i++ {
+		i := i
+		c.now = func() time.Time { return now.Add(time.Duration(i) * time.Minute) }
+		key := Key(Request{Model: "gpt-4", Messages: []openai.ChatCompletionMessage{{Content: strings.Repeat("a", i+1)}}})
+		require.NoError(t, c.Set(key, "gpt-4", strings.Repeat("x", 100)))
+		keys = append(keys, key)
+	}
+
+	info, err := os.Stat(c.path(keys[0]))
+	require.NoError(t, err)
+	c.maxSize = 2 * info.Size()
+	require.NoError(t, c.Evict())
+
+	_, ok := c.Get(keys[0])
+	assert.False(t, ok, "oldest entry must be evicted")
+	for _, key := range keys[1:] {
+		_, ok := c.Get(key)
+		assert.True(t, ok)
+	}
+
+	entries, err := filepath.Glob(filepath.Join(dir, "*", "*"+fileExt))
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestNilCache(t *testing.T) {
+	var c *Cache
+	_, ok := c.Get("key")
+	assert.False(t, ok)
+	assert.NoError(t, c.Set("key", "gpt-4", "completion"))
+	assert.NoError(t, c.Evict())
+}
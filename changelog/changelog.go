@@ -0,0 +1,193 @@
+package changelog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v51/github"
+	"github.com/sashabaranov/go-openai"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/description"
+	"github.com/ravilushqa/gpt-pullrequest-updater/logs"
+	oAIClient "github.com/ravilushqa/gpt-pullrequest-updater/openai"
+)
+
+const (
+	// DefaultSkipLabel marks pull requests that need no changelog entry.
+	DefaultSkipLabel = "skip-changelog"
+	// DefaultPath is the changelog file in the repository.
+	DefaultPath = "CHANGELOG.md"
+	// SuggestionMarker is hidden in the suggested entry posted on pull requests, so re-runs update it instead of
+	// posting it again.
+	SuggestionMarker = "<!-- gpt:changelog -->"
+
+	unreleasedHeading = "## [Unreleased]"
+)
+
+// Categories are the Keep a Changelog sections an entry can be classified into.
+var Categories = []string{"Added", "Changed", "Fixed", "Removed", "Security"}
+
+type Completer interface {
+	ChatCompletion(ctx context.Context, messages []openai.ChatCompletionMessage) (string, error)
+}
+
+// Entry is a changelog entry of a pull request.
+type Entry struct {
+	Category string `json:"category"`
+	Entry    string `json:"entry"`
+	PRNumber int    `json:"pr_number"`
+	PRURL    string `json:"pr_url"`
+}
+
+// Line renders the entry as a changelog list item linking the pull request.
+func (e Entry) Line() string {
+	if e.PRURL == "" {
+		return "- " + e.Entry
+	}
+	return fmt.Sprintf("- %s ([#%d](%s))", strings.TrimSuffix(e.Entry, "."), e.PRNumber, e.PRURL)
+}
+
+// Markdown renders the entry under its category heading.
+func (e Entry) Markdown() string {
+	return fmt.Sprintf("### %s\n%s", e.Category, e.Line())
+}
+
+// HasLabel reports whether the pull request has the label.
+func HasLabel(pr *github.PullRequest, label string) bool {
+	for _, l := range pr.Labels {
+		if strings.EqualFold(l.GetName(), label) {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateEntry classifies the pull request and drafts its changelog entry.
+func GenerateEntry(ctx context.Context, client Completer, diff *github.CommitsComparison, pr *github.PullRequest, desc string) (*Entry, error) {
+	maxLength := oAIClient.PromptRoom(client, oAIClient.PromptChangelog)
+
+	files := make([]string, 0, len(diff.Files))
+	for _, file := range diff.Files {
+		files = append(files, fmt.Sprintf("- %s (%s)", file.GetFilename(), file.GetStatus()))
+	}
+
+	content := fmt.Sprintf("Pull request title: %s\n\nDescription:\n%s\n\nCommits:\n%s\n\nChanged files:\n%s",
+		pr.GetTitle(), desc, description.CommitMessages(diff.Commits, maxLength/4), strings.Join(files, "\n"))
+	if len(content) > maxLength {
+		logs.Println("Prompt is too long, truncating")
+		content = oAIClient.Truncate(content, maxLength)
+	}
+
+	logs.Println("Generating changelog entry")
+	completion, err := client.ChatCompletion(ctx, []openai.ChatCompletionMessage{
+		{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: oAIClient.PromptChangelog,
+		},
+		{
+			Role:    openai.ChatMessageRoleUser,
+			Content: content,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error completing prompt: %w", err)
+	}
+
+	entry, err := parseEntry(completion)
+	if err != nil {
+		return nil, err
+	}
+	entry.PRNumber = pr.GetNumber()
+	entry.PRURL = pr.GetHTMLURL()
+
+	return entry, nil
+}
+
+func parseEntry(completion string) (*Entry, error) {
+	start := strings.Index(completion, "{")
+	end := strings.LastIndex(completion, "}")
+	if start < 0 || end < start {
+		return nil, errors.New("invalid JSON object")
+	}
+
+	var entry Entry
+	if err := json.Unmarshal([]byte(completion[start:end+1]), &entry); err != nil {
+		return nil, errors.New("invalid JSON object")
+	}
+
+	for _, c := range Categories {
+		if strings.EqualFold(entry.Category, c) {
+			entry.Category = c
+			entry.Entry = strings.TrimSpace(entry.Entry)
+			if entry.Entry == "" {
+				return nil, errors.New("empty changelog entry")
+			}
+			return &entry, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown changelog category: %q", entry.Category)
+}
+
+// Insert adds the entry to the Unreleased section of a Keep a Changelog file, creating the section and
+// the category heading when they are missing.
+func Insert(content string, entry Entry) string {
+	lines := strings.Split(content, "\n")
+
+	unreleased := -1
+	for i, line := range lines {
+		if strings.HasPrefix(strings.ToLower(strings.TrimSpace(line)), strings.ToLower(unreleasedHeading)) {
+			unreleased = i
+			break
+		}
+	}
+	if unreleased < 0 {
+		// the Unreleased section goes before the first release
+		at := len(lines)
+		for i, line := range lines {
+			if strings.HasPrefix(line, "## ") {
+				at = i
+				break
+			}
+		}
+		lines = insertLines(lines, at, unreleasedHeading, "")
+		unreleased = at
+	}
+
+	sectionEnd := len(lines)
+	for i := unreleased + 1; i < len(lines); i++ {
+		if strings.HasPrefix(lines[i], "## ") {
+			sectionEnd = i
+			break
+		}
+	}
+
+	heading := "### " + entry.Category
+	for i := unreleased + 1; i < sectionEnd; i++ {
+		if !strings.EqualFold(strings.TrimSpace(lines[i]), heading) {
+			continue
+		}
+		// append after the last item of the category
+		at := i + 1
+		for at < sectionEnd && strings.HasPrefix(strings.TrimSpace(lines[at]), "- ") {
+			at++
+		}
+		return strings.Join(insertLines(lines, at, entry.Line()), "\n")
+	}
+
+	at := sectionEnd
+	for at > unreleased+1 && strings.TrimSpace(lines[at-1]) == "" {
+		at--
+	}
+	return strings.Join(insertLines(lines, at, "", heading, entry.Line()), "\n")
+}
+
+func insertLines(lines []string, at int, inserted ...string) []string {
+	result := make([]string, 0, len(lines)+len(inserted))
+	result = append(result, lines[:at]...)
+	result = append(result, inserted...)
+	return append(result, lines[at:]...)
+}
@@ -0,0 +1,106 @@
+package changelog
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-github/v51/github"
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockCompleter struct {
+	mock.Mock
+}
+
+func (m *MockCompleter) ChatCompletion(ctx context.Context, messages []openai.ChatCompletionMessage) (string, error) {
+	args := m.Called(ctx, messages)
+	return args.String(0), args.Error(1)
+}
+
+func TestGenerateEntry(t *testing.T) {
+	testCases := []struct {
+		name         string
+		mockResponse string
+		expected     *Entry
+		expectError  bool
+	}{
+		{
+			name:         "Valid entry",
+			mockResponse: `Entry: {"category": "fixed", "entry": "Fix crash on empty config."}`,
+			expected:     &Entry{Category: "Fixed", Entry: "Fix crash on empty config.", PRNumber: 7, PRURL: "https://github.com/o/r/pull/7"},
+		},
+		{
+			name:         "Unknown category",
+			mockResponse: `{"category": "Improved", "entry": "Faster."}`,
+			expectError:  true,
+		},
+		{
+			name:         "Invalid JSON",
+			mockResponse: `Fixed a crash`,
+			expectError:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCompleter := new(MockCompleter)
+			mockCompleter.On("ChatCompletion", mock.Anything, mock.Anything).Return(tc.mockResponse, nil)
+			pr := &github.PullRequest{Number: github.Int(7), HTMLURL: github.String("https://github.com/o/r/pull/7")}
+
+			entry, err := GenerateEntry(context.Background(), mockCompleter, &github.CommitsComparison{}, pr, "")
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, entry)
+		})
+	}
+}
+
+func TestHasLabel(t *testing.T) {
+	pr := &github.PullRequest{Labels: []*github.Label{{Name: github.String("bug")}, {Name: github.String("Skip-Changelog")}}}
+	assert.True(t, HasLabel(pr, DefaultSkipLabel))
+	assert.False(t, HasLabel(pr, "docs"))
+}
+
+func TestInsert(t *testing.T) {
+	entry := Entry{Category: "Fixed", Entry: "Fix crash.", PRNumber: 7, PRURL: "https://github.com/o/r/pull/7"}
+	line := "- Fix crash ([#7](https://github.com/o/r/pull/7))"
+
+	testCases := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{
+			name:     "Existing category",
+			content:  "# Changelog\n\n## [Unreleased]\n### Fixed\n- Old fix\n\n### Added\n- Feature\n\n## [1.0.0]\n### Fixed\n- Released fix\n",
+			expected: "# Changelog\n\n## [Unreleased]\n### Fixed\n- Old fix\n" + line + "\n\n### Added\n- Feature\n\n## [1.0.0]\n### Fixed\n- Released fix\n",
+		},
+		{
+			name:     "New category",
+			content:  "# Changelog\n\n## [Unreleased]\n### Added\n- Feature\n\n## [1.0.0]\n",
+			expected: "# Changelog\n\n## [Unreleased]\n### Added\n- Feature\n\n### Fixed\n" + line + "\n\n## [1.0.0]\n",
+		},
+		{
+			name:     "Empty unreleased section",
+			content:  "# Changelog\n\n## [Unreleased]\n\n## [1.0.0]\n",
+			expected: "# Changelog\n\n## [Unreleased]\n\n### Fixed\n" + line + "\n\n## [1.0.0]\n",
+		},
+		{
+			name:     "Missing unreleased section",
+			content:  "# Changelog\n\n## [1.0.0]\n",
+			expected: "# Changelog\n\n## [Unreleased]\n\n### Fixed\n" + line + "\n\n## [1.0.0]\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Insert(tc.content, entry))
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/anthropic"
+	"github.com/ravilushqa/gpt-pullrequest-updater/cache"
+	"github.com/ravilushqa/gpt-pullrequest-updater/changelog"
+	"github.com/ravilushqa/gpt-pullrequest-updater/codehost"
+	ghClient "github.com/ravilushqa/gpt-pullrequest-updater/github"
+	"github.com/ravilushqa/gpt-pullrequest-updater/gitlab"
+	"github.com/ravilushqa/gpt-pullrequest-updater/hosts"
+	"github.com/ravilushqa/gpt-pullrequest-updater/logs"
+	"github.com/ravilushqa/gpt-pullrequest-updater/marker"
+	oAIClient "github.com/ravilushqa/gpt-pullrequest-updater/openai"
+	"github.com/ravilushqa/gpt-pullrequest-updater/usage"
+)
+
+var opts struct {
+	hosts.Options
+	OpenAIToken     string        `long:"openai-token" env:"OPENAI_TOKEN" description:"OpenAI token. Not required for the local provider"`
+	Owner           string        `long:"owner" env:"OWNER" description:"Repository owner. The project namespace on GitLab, the workspace on Bitbucket Cloud and the project key on Bitbucket Data Center" required:"true"`
+	Repo            string        `long:"repo" env:"REPO" description:"Repository name. The project name on GitLab and the repository slug on Bitbucket" required:"true"`
+	PRNumber        int           `long:"pr-number" env:"PR_NUMBER" description:"Pull request number. The merge request IID on GitLab" required:"true"`
+	OpenAIModel     string        `long:"openai-model" env:"OPENAI_MODEL" description:"OpenAI model. Defaults to gpt-3.5-turbo for the openai provider"`
+	OpenAIBaseURL   string        `long:"openai-base-url" env:"OPENAI_BASE_URL" description:"OpenAI-compatible API base URL. Example: http://localhost:11434/v1"`
+	Provider        string        `long:"provider" env:"PROVIDER" description:"Completion provider" choice:"openai" choice:"local" choice:"anthropic" default:"openai"`
+	AnthropicToken  string        `long:"anthropic-token" env:"ANTHROPIC_API_KEY" description:"Anthropic API key. Required for the anthropic provider"`
+	AnthropicModel  string        `long:"anthropic-model" env:"ANTHROPIC_MODEL" description:"Anthropic model" default:"claude-3-5-sonnet-latest"`
+	PriceFile       string        `long:"price-file" env:"PRICE_FILE" description:"JSON file with model prices in USD per million tokens overriding the built-in table"`
+	UsageReport     string        `long:"usage-report" env:"USAGE_REPORT" description:"Write the token usage report as JSON to this file"`
+	MaxTokensPerRun int           `long:"max-tokens-per-run" env:"MAX_TOKENS_PER_RUN" description:"Stop calling the API once this many tokens are used. 0 means no limit"`
+	MaxCostPerRun   float64       `long:"max-cost-per-run" env:"MAX_COST_PER_RUN" description:"Stop calling the API once this estimated cost in USD is reached. 0 means no limit"`
+	CacheDir        string        `long:"cache-dir" env:"CACHE_DIR" description:"Directory for caching completions. Caching is disabled when empty"`
+	CacheTTL        time.Duration `long:"cache-ttl" env:"CACHE_TTL" description:"How long cached completions are reused" default:"168h"`
+	CacheMaxSizeMB  int64         `long:"cache-max-size-mb" env:"CACHE_MAX_SIZE_MB" description:"Maximum size of the cache directory in megabytes" default:"100"`
+	MaxPromptLength int           `long:"max-prompt-length" env:"MAX_PROMPT_LENGTH" description:"Maximum prompt length in characters. Defaults depend on the provider"`
+	Mode            string        `long:"mode" env:"MODE" description:"Suggest the entry in a pull request review or output it for a release bot" choice:"output" choice:"review" default:"output"`
+	Format          string        `long:"format" env:"FORMAT" description:"Output format of the entry" choice:"markdown" choice:"json" default:"markdown"`
+	Write           bool          `long:"write" env:"WRITE" description:"Add the entry to the local changelog file in output mode"`
+	ChangelogPath   string        `long:"changelog-path" env:"CHANGELOG_PATH" description:"Path of the changelog in the repository" default:"CHANGELOG.md"`
+	SkipLabel       string        `long:"skip-label" env:"SKIP_LABEL" description:"Pull requests with this label get no changelog entry" default:"skip-changelog"`
+	Test            bool          `long:"test" env:"TEST" description:"Test mode"`
+}
+
+func main() {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	// merge request pipelines of GitLab CI need no further configuration
+	gitlab.ApplyCIEnv()
+	if _, err := flags.Parse(&opts); err != nil {
+		if err.(*flags.Error).Type != flags.ErrHelp {
+			fmt.Printf("Error parsing flags: %v \n", err)
+		}
+		os.Exit(0)
+	}
+
+	if opts.Format == "json" {
+		// keep stdout for the entry only
+		logs.SetOutput(os.Stderr)
+	}
+
+	if err := run(ctx, os.Stdout); err != nil {
+		panic(err)
+	}
+}
+
+func run(ctx context.Context, stdout io.Writer) error {
+	prices, err := usage.LoadPrices(opts.PriceFile)
+	if err != nil {
+		return err
+	}
+	tracker := usage.NewTracker()
+	defer reportUsage(tracker, prices)
+	budget := usage.NewBudget(tracker, prices, opts.MaxTokensPerRun, opts.MaxCostPerRun)
+
+	var completionCache *cache.Cache
+	if opts.CacheDir != "" {
+		completionCache, err = cache.New(opts.CacheDir, opts.CacheTTL, opts.CacheMaxSizeMB<<20)
+		if err != nil {
+			return err
+		}
+	}
+
+	openAIClient, err := newCompleter(tracker, budget, completionCache)
+	if err != nil {
+		return fmt.Errorf("error creating completion client: %w", err)
+	}
+	host, err := hosts.New(ctx, opts.Options, opts.Owner, opts.Repo)
+	if err != nil {
+		return fmt.Errorf("error creating code host client: %w", err)
+	}
+	// suggestions are posted as reviews, which are only available on GitHub
+	githubClient, ok := host.(*ghClient.Client)
+	if opts.Mode == "review" && !ok {
+		return codehost.NotSupported("--mode=review", opts.Type())
+	}
+
+	return generate(ctx, stdout, openAIClient, host, githubClient)
+}
+
+// generate writes the changelog entry of the pull request to stdout, or suggests it in a review.
+func generate(ctx context.Context, stdout io.Writer, openAIClient changelog.Completer, host codehost.Host, githubClient *ghClient.Client) error {
+	pr, err := host.GetPullRequest(ctx, opts.Owner, opts.Repo, opts.PRNumber)
+	if err != nil {
+		return fmt.Errorf("error getting pull request: %w", err)
+	}
+
+	if changelog.HasLabel(pr, opts.SkipLabel) {
+		logs.Printf("Pull request is labelled %s, skipping\n", opts.SkipLabel)
+		return nil
+	}
+
+	diff, coverage, err := host.GetPullRequestChanges(ctx, opts.Owner, opts.Repo, pr)
+	if err != nil {
+		return fmt.Errorf("error getting commits: %w", err)
+	}
+	if !coverage.Complete() {
+		logs.Println("Warning:", coverage)
+	}
+
+	if opts.Mode == "review" {
+		for _, file := range diff.Files {
+			if file.GetFilename() == opts.ChangelogPath {
+				logs.Printf("%s is already updated, skipping\n", opts.ChangelogPath)
+				return nil
+			}
+		}
+	}
+
+	desc := marker.ExtractGenerated(pr.GetBody())
+	if desc == "" {
+		desc = pr.GetBody()
+	}
+
+	entry, err := changelog.GenerateEntry(ctx, openAIClient, diff, pr, desc)
+	if err != nil {
+		return fmt.Errorf("error generating changelog entry: %w", err)
+	}
+
+	if opts.Mode == "review" {
+		return suggestEntry(ctx, githubClient, entry)
+	}
+
+	if opts.Format == "json" {
+		data, err := json.MarshalIndent(entry, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error encoding changelog entry: %w", err)
+		}
+		fmt.Fprintln(stdout, string(data))
+	} else {
+		fmt.Fprintln(stdout, entry.Markdown())
+	}
+
+	if opts.Write {
+		content, err := os.ReadFile(opts.ChangelogPath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("error reading changelog: %w", err)
+		}
+		if len(content) == 0 {
+			content = []byte("# Changelog\n")
+		}
+		if err := os.WriteFile(opts.ChangelogPath, []byte(changelog.Insert(string(content), *entry)), 0o644); err != nil {
+			return fmt.Errorf("error writing changelog: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// suggestEntry posts the entry as a review so the author can add it to the changelog. The changelog is not part of
+// the diff, so the review cannot be anchored to it. Re-runs update the earlier suggestion instead of posting another one.
+func suggestEntry(ctx context.Context, githubClient *ghClient.Client, entry *changelog.Entry) error {
+	body := fmt.Sprintf("%s\n`%s` was not updated. Suggested entry for the `[Unreleased]` section:\n\n```markdown\n%s\n```\n\nAdd the `%s` label if this change needs no changelog entry.",
+		changelog.SuggestionMarker, opts.ChangelogPath, entry.Markdown(), opts.SkipLabel)
+	if opts.Test {
+		fmt.Println(body)
+		return nil
+	}
+
+	return githubClient.UpsertReview(ctx, opts.Owner, opts.Repo, opts.PRNumber, changelog.SuggestionMarker, body)
+}
+
+func newCompleter(tracker *usage.Tracker, budget *usage.Budget, completionCache *cache.Cache) (changelog.Completer, error) {
+	if opts.Provider == "anthropic" {
+		return anthropic.NewClient(anthropic.Config{
+			Token:           opts.AnthropicToken,
+			Model:           opts.AnthropicModel,
+			MaxPromptLength: opts.MaxPromptLength,
+			Tracker:         tracker,
+			Budget:          budget,
+			Cache:           completionCache,
+		})
+	}
+
+	return oAIClient.NewClientWithConfig(oAIClient.Config{
+		Provider:        oAIClient.Provider(opts.Provider),
+		Token:           opts.OpenAIToken,
+		Model:           opts.OpenAIModel,
+		BaseURL:         opts.OpenAIBaseURL,
+		MaxPromptLength: opts.MaxPromptLength,
+		Tracker:         tracker,
+		Budget:          budget,
+		Cache:           completionCache,
+	})
+}
+
+func reportUsage(tracker *usage.Tracker, prices usage.Prices) {
+	report := tracker.Report(prices)
+	logs.Print(report)
+
+	if opts.UsageReport != "" {
+		if err := report.WriteJSON(opts.UsageReport); err != nil {
+			logs.Printf("Error writing usage report: %v \n", err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/google/go-github/v51/github"
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/changelog"
+	"github.com/ravilushqa/gpt-pullrequest-updater/codehost"
+	ghClient "github.com/ravilushqa/gpt-pullrequest-updater/github"
+	"github.com/ravilushqa/gpt-pullrequest-updater/logs"
+	"github.com/ravilushqa/gpt-pullrequest-updater/usage"
+)
+
+type fakeHost struct {
+	codehost.Host
+}
+
+func (fakeHost) GetPullRequest(ctx context.Context, owner, repo string, number int) (*github.PullRequest, error) {
+	return &github.PullRequest{Number: github.Int(number), Title: github.String("Round totals"), HTMLURL: github.String("https://github.com/acme/app/pull/7")}, nil
+}
+
+func (fakeHost) GetPullRequestChanges(ctx context.Context, owner, repo string, pr *github.PullRequest) (*github.CommitsComparison, ghClient.Coverage, error) {
+	diff := &github.CommitsComparison{Files: []*github.CommitFile{{Filename: github.String("round.go")}}}
+	return diff, ghClient.Coverage{ChangedFiles: 2, AnalyzedFiles: 1, MissingFiles: []string{"huge.go"}}, nil
+}
+
+type fakeCompleter struct{}
+
+func (fakeCompleter) ChatCompletion(ctx context.Context, messages []openai.ChatCompletionMessage) (string, error) {
+	return `{"category": "fixed", "entry": "Round totals half up."}`, nil
+}
+
+func TestJSONOutputOnlyWritesTheEntry(t *testing.T) {
+	var logBuf bytes.Buffer
+	logs.SetOutput(&logBuf)
+	defer logs.SetOutput(os.Stdout)
+	opts.Owner, opts.Repo, opts.PRNumber = "acme", "app", 7
+	opts.Mode, opts.Format = "output", "json"
+
+	var stdout bytes.Buffer
+	require.NoError(t, generate(context.Background(), &stdout, fakeCompleter{}, fakeHost{}, nil))
+	reportUsage(usage.NewTracker(), usage.DefaultPrices)
+
+	var entry changelog.Entry
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &entry), "stdout must be a single JSON document: %s", stdout.String())
+	expected, err := json.MarshalIndent(changelog.Entry{Category: "Fixed", Entry: "Round totals half up.", PRNumber: 7, PRURL: "https://github.com/acme/app/pull/7"}, "", "  ")
+	require.NoError(t, err)
+	assert.Equal(t, string(expected)+"\n", stdout.String())
+
+	assert.Contains(t, logBuf.String(), "Warning:")
+	assert.Contains(t, logBuf.String(), "Generating changelog entry")
+	assert.Contains(t, logBuf.String(), "Usage:")
+}
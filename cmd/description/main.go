@@ -2,86 +2,573 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"regexp"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/google/go-github/v51/github"
 	"github.com/jessevdk/go-flags"
 
+	"github.com/ravilushqa/gpt-pullrequest-updater/anthropic"
+	"github.com/ravilushqa/gpt-pullrequest-updater/cache"
+	"github.com/ravilushqa/gpt-pullrequest-updater/codehost"
+	"github.com/ravilushqa/gpt-pullrequest-updater/criteria"
 	"github.com/ravilushqa/gpt-pullrequest-updater/description"
 	ghClient "github.com/ravilushqa/gpt-pullrequest-updater/github"
+	"github.com/ravilushqa/gpt-pullrequest-updater/gitlab"
+	"github.com/ravilushqa/gpt-pullrequest-updater/hosts"
 	"github.com/ravilushqa/gpt-pullrequest-updater/jira"
+	"github.com/ravilushqa/gpt-pullrequest-updater/logs"
+	"github.com/ravilushqa/gpt-pullrequest-updater/marker"
 	oAIClient "github.com/ravilushqa/gpt-pullrequest-updater/openai"
+	"github.com/ravilushqa/gpt-pullrequest-updater/output"
+	issueTracker "github.com/ravilushqa/gpt-pullrequest-updater/tracker"
+	"github.com/ravilushqa/gpt-pullrequest-updater/usage"
 )
 
+var errInvalidTitle = errors.New("title does not follow Conventional Commits")
+
 var opts struct {
-	GithubToken string `long:"gh-token" env:"GITHUB_TOKEN" description:"GitHub token" required:"true"`
-	OpenAIToken string `long:"openai-token" env:"OPENAI_TOKEN" description:"OpenAI token" required:"true"`
-	Owner       string `long:"owner" env:"OWNER" description:"GitHub owner" required:"true"`
-	Repo        string `long:"repo" env:"REPO" description:"GitHub repo" required:"true"`
-	PRNumber    int    `long:"pr-number" env:"PR_NUMBER" description:"Pull request number" required:"true"`
-	OpenAIModel string `long:"openai-model" env:"OPENAI_MODEL" description:"OpenAI model" default:"gpt-3.5-turbo"`
-	Test        bool   `long:"test" env:"TEST" description:"Test mode"`
-	JiraURL     string `long:"jira-url" env:"JIRA_URL" description:"Jira URL. Example: https://jira.atlassian.com"`
+	hosts.Options
+	OpenAIToken     string        `long:"openai-token" env:"OPENAI_TOKEN" description:"OpenAI token. Not required for the local provider"`
+	Owner           string        `long:"owner" env:"OWNER" description:"Repository owner. The project namespace on GitLab, the workspace on Bitbucket Cloud and the project key on Bitbucket Data Center" required:"true"`
+	Repo            string        `long:"repo" env:"REPO" description:"Repository name. The project name on GitLab and the repository slug on Bitbucket" required:"true"`
+	PRNumber        int           `long:"pr-number" env:"PR_NUMBER" description:"Pull request number. The merge request IID on GitLab" required:"true"`
+	OpenAIModel     string        `long:"openai-model" env:"OPENAI_MODEL" description:"OpenAI model. Defaults to gpt-3.5-turbo for the openai provider"`
+	OpenAIBaseURL   string        `long:"openai-base-url" env:"OPENAI_BASE_URL" description:"OpenAI-compatible API base URL. Example: http://localhost:11434/v1"`
+	Provider        string        `long:"provider" env:"PROVIDER" description:"Completion provider" choice:"openai" choice:"local" choice:"anthropic" default:"openai"`
+	AnthropicToken  string        `long:"anthropic-token" env:"ANTHROPIC_API_KEY" description:"Anthropic API key. Required for the anthropic provider"`
+	AnthropicModel  string        `long:"anthropic-model" env:"ANTHROPIC_MODEL" description:"Anthropic model" default:"claude-3-5-sonnet-latest"`
+	PriceFile       string        `long:"price-file" env:"PRICE_FILE" description:"JSON file with model prices in USD per million tokens overriding the built-in table"`
+	UsageReport     string        `long:"usage-report" env:"USAGE_REPORT" description:"Write the token usage report as JSON to this file"`
+	UsageFooter     bool          `long:"usage-footer" env:"USAGE_FOOTER" description:"Add the token usage report as a footer to the pull request description"`
+	MaxTokensPerRun int           `long:"max-tokens-per-run" env:"MAX_TOKENS_PER_RUN" description:"Stop calling the API once this many tokens are used. 0 means no limit"`
+	MaxCostPerRun   float64       `long:"max-cost-per-run" env:"MAX_COST_PER_RUN" description:"Stop calling the API once this estimated cost in USD is reached. 0 means no limit"`
+	CacheDir        string        `long:"cache-dir" env:"CACHE_DIR" description:"Directory for caching completions. Caching is disabled when empty"`
+	CacheTTL        time.Duration `long:"cache-ttl" env:"CACHE_TTL" description:"How long cached completions are reused" default:"168h"`
+	CacheMaxSizeMB  int64         `long:"cache-max-size-mb" env:"CACHE_MAX_SIZE_MB" description:"Maximum size of the cache directory in megabytes" default:"100"`
+	MaxPromptLength int           `long:"max-prompt-length" env:"MAX_PROMPT_LENGTH" description:"Maximum prompt length in characters. Defaults depend on the provider"`
+	Test            bool          `long:"test" env:"TEST" description:"Test mode"`
+	Output          string        `long:"output" env:"OUTPUT" description:"Output format of the result. With json the logs go to stderr" choice:"text" choice:"json" default:"text"`
+	OutputFile      string        `long:"output-file" env:"OUTPUT_FILE" description:"Write the json result to this file instead of stdout"`
+	BodyMode        string        `long:"body-mode" env:"BODY_MODE" description:"Where to put the generated section when the body has no gpt markers yet" choice:"append" choice:"prepend" default:"append"`
+	PRTemplate      bool          `long:"pr-template" env:"PR_TEMPLATE" description:"Fill in the pull request template of the repository instead of the default structure"`
+	Force           bool          `long:"force" env:"FORCE" description:"Regenerate the description even if the diff has not changed"`
+	TitleMode       string        `long:"title-mode" env:"TITLE_MODE" description:"Conventional Commits title handling: generate always replaces the title, fix replaces only a non-conforming title, validate only reports it" choice:"generate" choice:"fix" choice:"validate"`
+	JiraURL         string        `long:"jira-url" env:"JIRA_URL" description:"Jira URL. Example: https://jira.atlassian.com"`
+	JiraEmail       string        `long:"jira-email" env:"JIRA_EMAIL" description:"Email of the Jira Cloud account the API token belongs to. Leave empty to use the token as a Jira Server personal access token"`
+	JiraToken       string        `long:"jira-token" env:"JIRA_TOKEN" description:"Jira API token or personal access token. When set, the linked tickets are passed to the model"`
+	JiraCriteria    string        `long:"jira-acceptance-criteria-field" env:"JIRA_ACCEPTANCE_CRITERIA_FIELD" description:"Jira custom field with the acceptance criteria. Example: customfield_10100. Defaults to the Acceptance criteria section of the ticket description"`
+	CriteriaCheck   string        `long:"criteria-check" env:"CRITERIA_CHECK" description:"Check the acceptance criteria of the linked issues against the diff and add the checklist to the description or post it as a comment. Requires a tracker token" choice:"description" choice:"comment"`
+	JiraComment     bool          `long:"jira-comment" env:"JIRA_COMMENT" description:"Comment on the linked Jira tickets with the pull request link and summary. Requires --jira-token"`
+	JiraOpenStatus  string        `long:"jira-transition-open" env:"JIRA_TRANSITION_OPEN" description:"Jira transition or status to move the linked tickets to while the pull request is open. Example: In Review"`
+	JiraMergeStatus string        `long:"jira-transition-merge" env:"JIRA_TRANSITION_MERGE" description:"Jira transition or status to move the linked tickets to once the pull request is merged. Example: Done"`
+	JiraProjects    []string      `long:"jira-projects" env:"JIRA_PROJECTS" env-delim:"," description:"Jira project keys to link. All keys are linked when empty. Example: PAY,OPS"`
+	Tracker         string        `long:"tracker" env:"TRACKER" description:"Issue tracker to link. Defaults to jira when --jira-url is set" choice:"jira" choice:"linear" choice:"youtrack" choice:"github"`
+	TrackerURL      string        `long:"tracker-url" env:"TRACKER_URL" description:"Tracker URL: the Jira or YouTrack instance, or the Linear workspace. Example: https://linear.app/acme"`
+	TrackerToken    string        `long:"tracker-token" env:"TRACKER_TOKEN" description:"Tracker API token. When set, the linked issues are passed to the model"`
+	TrackerProjects []string      `long:"tracker-projects" env:"TRACKER_PROJECTS" env-delim:"," description:"Project or team keys to link. All keys are linked when empty. Example: ENG,OPS"`
+	TrackerCABundle string        `long:"tracker-ca-bundle" env:"TRACKER_CA_BUNDLE" description:"PEM file with additional CA certificates trusted for the tracker"`
+	TrackerProxy    string        `long:"tracker-proxy" env:"TRACKER_PROXY" description:"HTTP proxy URL for the tracker. Defaults to the proxy environment variables"`
+	TrackerConfig   string        `long:"tracker-config" env:"TRACKER_CONFIG" description:"JSON file selecting the tracker per repository. Takes precedence over the other tracker flags"`
 }
 
 func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
+	// merge request pipelines of GitLab CI need no further configuration
+	gitlab.ApplyCIEnv()
 	if _, err := flags.Parse(&opts); err != nil {
 		if err.(*flags.Error).Type != flags.ErrHelp {
-			fmt.Printf("Error parsing flags: %v \n", err)
+			logs.Printf("Error parsing flags: %v \n", err)
 		}
 		os.Exit(0)
 	}
 
-	if err := run(ctx); err != nil {
+	if opts.Output == output.FormatJSON && opts.OutputFile == "" {
+		// keep stdout for the result only
+		logs.SetOutput(os.Stderr)
+	}
+
+	if err := run(ctx, os.Stdout); err != nil {
+		if errors.Is(err, errInvalidTitle) {
+			logs.Println(err)
+			os.Exit(1)
+		}
 		panic(err)
 	}
 }
 
-func run(ctx context.Context) error {
-	openAIClient := oAIClient.NewClient(opts.OpenAIToken, opts.OpenAIModel)
-	githubClient := ghClient.NewClient(ctx, opts.GithubToken)
+func run(ctx context.Context, stdout io.Writer) (err error) {
+	prices, err := usage.LoadPrices(opts.PriceFile)
+	if err != nil {
+		return err
+	}
+	tracker := usage.NewTracker()
+	defer reportUsage(tracker, prices)
+	result := output.New("description", opts.Owner, opts.Repo, opts.PRNumber)
+	defer func() {
+		result.SetError(err)
+		writeResult(stdout, result, tracker, prices)
+	}()
+	budget := usage.NewBudget(tracker, prices, opts.MaxTokensPerRun, opts.MaxCostPerRun)
+
+	var completionCache *cache.Cache
+	if opts.CacheDir != "" {
+		completionCache, err = cache.New(opts.CacheDir, opts.CacheTTL, opts.CacheMaxSizeMB<<20)
+		if err != nil {
+			return err
+		}
+	}
+
+	openAIClient, err := newCompleter(tracker, budget, completionCache)
+	if err != nil {
+		return fmt.Errorf("error creating completion client: %w", err)
+	}
+	host, err := hosts.New(ctx, opts.Options, opts.Owner, opts.Repo)
+	if err != nil {
+		return fmt.Errorf("error creating code host client: %w", err)
+	}
+	if _, ok := host.(codehost.TemplateGetter); opts.PRTemplate && !ok {
+		return codehost.NotSupported("--pr-template", opts.Type())
+	}
 
-	pr, err := githubClient.GetPullRequest(ctx, opts.Owner, opts.Repo, opts.PRNumber)
+	pr, err := host.GetPullRequest(ctx, opts.Owner, opts.Repo, opts.PRNumber)
 	if err != nil {
 		return fmt.Errorf("error getting pull request: %w", err)
 	}
 
-	diff, err := githubClient.CompareCommits(ctx, opts.Owner, opts.Repo, pr.GetBase().GetSHA(), pr.GetHead().GetSHA())
+	diff, coverage, err := host.GetPullRequestChanges(ctx, opts.Owner, opts.Repo, pr)
 	if err != nil {
 		return fmt.Errorf("error getting commits: %w", err)
 	}
+	if !coverage.Complete() {
+		logs.Println("Warning:", coverage)
+	}
+
+	result.PullRequest.URL = pr.GetHTMLURL()
+	result.AddCoverage(coverage)
 
-	completion, err := description.GenerateCompletion(ctx, openAIClient, diff, pr)
+	trackerCfg, err := trackerConfig()
 	if err != nil {
-		return fmt.Errorf("error generating completion: %w", err)
+		return err
+	}
+	var issues issueTracker.Tracker
+	if trackerCfg != nil {
+		// GitHub Issues are read through the GitHub client
+		var issueGetter issueTracker.IssueGetter
+		if trackerCfg.Type == issueTracker.TypeGitHub {
+			githubClient, ok := host.(*ghClient.Client)
+			if !ok {
+				return codehost.NotSupported("the github tracker", opts.Type())
+			}
+			issueGetter = githubClient
+		}
+		issues, err = issueTracker.New(*trackerCfg, issueGetter, opts.Owner, opts.Repo)
+		if err != nil {
+			return fmt.Errorf("error creating issue tracker: %w", err)
+		}
+	}
+	if _, ok := issues.(issueTracker.Fetcher); opts.CriteriaCheck != "" && !ok {
+		return errors.New("--criteria-check reads the acceptance criteria from the issue tracker and needs a tracker token, see --tracker-token")
+	}
+	jiraSync := trackerCfg != nil && trackerCfg.Type == issueTracker.TypeJira && trackerCfg.Token != ""
+	if (opts.JiraComment || opts.JiraOpenStatus != "" || opts.JiraMergeStatus != "") && !jiraSync {
+		return errors.New("--jira-comment and --jira-transition-* update the linked Jira tickets and need the jira tracker with a token, see --jira-url and --jira-token")
 	}
 
-	if opts.JiraURL != "" {
-		fmt.Println("Adding Jira ticket")
-		id, err := jira.ExtractJiraTicketID(*pr.Title)
+	body := pr.GetBody()
+	diffHash := description.DiffHash(diff)
+	if !opts.Force && marker.ExtractDiffHash(body) == diffHash {
+		logs.Println("Diff has not changed since the last description, skipping")
+		result.Skipped = true
+	} else {
+		body, err = updateDescription(ctx, openAIClient, host, issues, pr, diff, coverage, diffHash, tracker, budget, prices, result)
 		if err != nil {
-			fmt.Printf("Error extracting Jira ticket ID: %v \n", err)
+			return err
+		}
+	}
+
+	if jiraSync {
+		if err := syncJira(ctx, *trackerCfg, issues, pr, diff, body); err != nil {
+			return err
+		}
+	}
+
+	if opts.TitleMode != "" {
+		return updateTitle(ctx, openAIClient, host, pr, diff, body, result)
+	}
+
+	return nil
+}
+
+// updateDescription generates the description and updates the generated section of the pull request body.
+// It returns the new body.
+func updateDescription(ctx context.Context, openAIClient description.Completer, host codehost.Host, issues issueTracker.Tracker, pr *github.PullRequest, diff *github.CommitsComparison, coverage ghClient.Coverage, diffHash string, tracker *usage.Tracker, budget *usage.Budget, prices usage.Prices, result *output.Result) (string, error) {
+	var err error
+	var descOpts description.Options
+	prefilled := false
+	if opts.PRTemplate {
+		templates := host.(codehost.TemplateGetter)
+		descOpts.Template, err = templates.GetPullRequestTemplate(ctx, opts.Owner, opts.Repo, pr.GetBase().GetRef())
+		if err != nil {
+			return "", fmt.Errorf("error getting pull request template: %w", err)
+		}
+		if descOpts.Template == "" {
+			logs.Println("No pull request template found, using the default structure")
+		} else if author, ok := description.PrefilledTemplate(pr.GetBody(), descOpts.Template); ok {
+			// GitHub pre-fills new pull requests with the template, the author may have started filling it in
+			logs.Println("Pull request body already contains the template, filling it in")
+			descOpts.Template = author
+			prefilled = true
+		}
+	}
+
+	var keys []string
+	var linked []*issueTracker.Issue
+	if issues != nil {
+		keys = issues.Keys(pr, diff.Commits)
+		linked = issueTracker.Fetch(ctx, issues, keys)
+	}
+	tickets := make([]string, 0, len(linked))
+	for _, issue := range linked {
+		tickets = append(tickets, issue.String())
+	}
+	descOpts.Tickets = strings.Join(tickets, "\n")
+
+	generated, err := description.Generate(ctx, openAIClient, diff, pr, descOpts)
+	if errors.Is(err, usage.ErrBudgetExceeded) {
+		budget.Skip("description", err.Error())
+		return pr.GetBody(), nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error generating completion: %w", err)
+	}
+	result.AddDescription(generated)
+	completion := generated.Description
+	if !coverage.Complete() {
+		completion += "\n\n> **Note:** " + coverage.String()
+	}
+
+	if issues != nil {
+		logs.Println("Adding linked issues")
+		if len(keys) == 0 {
+			logs.Println("No linked issues found")
 		} else {
-			completion = fmt.Sprintf("### JIRA ticket: [%s](%s) \n\n%s", id, jira.GenerateJiraTicketURL(opts.JiraURL, id), completion)
+			completion = issuesHeader(issues, keys) + completion
 		}
 	}
 
+	if opts.CriteriaCheck != "" {
+		checklists, err := checkCriteria(ctx, openAIClient, diff, issues, linked, budget)
+		if err != nil {
+			return "", err
+		}
+		if checklists != "" && opts.CriteriaCheck == "description" {
+			completion += "\n\n" + checklists
+		} else if checklists != "" {
+			if err := postCriteriaComment(ctx, host, checklists); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	if opts.UsageFooter {
+		completion += tracker.Report(prices).Footer()
+	}
+	completion += "\n" + marker.DiffHash(diffHash)
+
+	updateBody := func(body string) string {
+		if prefilled {
+			return description.ReplaceBody(body, completion)
+		}
+		return description.UpdateBody(body, completion, description.InsertMode(opts.BodyMode))
+	}
 	if opts.Test {
+		return updateBody(pr.GetBody()), nil
+	}
+
+	// re-read the body so edits made by the author while generating are kept
+	latestPR, err := host.GetPullRequest(ctx, opts.Owner, opts.Repo, opts.PRNumber)
+	if err != nil {
+		return "", fmt.Errorf("error getting pull request: %w", err)
+	}
+
+	// Update only the generated section of the pull request description
+	logs.Println("Updating pull request")
+	body := updateBody(latestPR.GetBody())
+	updatePr := &github.PullRequest{Body: github.String(body)}
+	if _, err = host.UpdatePullRequest(ctx, opts.Owner, opts.Repo, opts.PRNumber, updatePr); err != nil {
+		return "", fmt.Errorf("error updating pull request: %w", err)
+	}
+
+	return body, nil
+}
+
+// trackerConfig returns the issue tracker of the repository, or nil when none is configured.
+func trackerConfig() (*issueTracker.Config, error) {
+	var cfg *issueTracker.Config
+	switch {
+	case opts.TrackerConfig != "":
+		var err error
+		if cfg, err = issueTracker.LoadConfig(opts.TrackerConfig, opts.Owner, opts.Repo); err != nil || cfg == nil {
+			return nil, err
+		}
+	case opts.Tracker != "":
+		cfg = &issueTracker.Config{Type: issueTracker.Type(opts.Tracker), URL: opts.TrackerURL, Projects: opts.TrackerProjects}
+	case opts.JiraURL != "":
+		cfg = &issueTracker.Config{Type: issueTracker.TypeJira}
+	default:
+		return nil, nil
+	}
+
+	cfg.Token = opts.TrackerToken
+	// the connection flags fill in what the config file leaves out
+	if cfg.CABundle == "" {
+		cfg.CABundle = opts.TrackerCABundle
+	}
+	if cfg.Proxy == "" {
+		cfg.Proxy = opts.TrackerProxy
+	}
+	if cfg.Type == issueTracker.TypeJira {
+		// the jira flags fill in what the tracker flags and the config file leave out
+		if cfg.URL == "" {
+			cfg.URL = opts.JiraURL
+		}
+		if len(cfg.Projects) == 0 {
+			cfg.Projects = opts.JiraProjects
+		}
+		if cfg.Token == "" {
+			cfg.Token = opts.JiraToken
+		}
+		if cfg.Email == "" {
+			cfg.Email = opts.JiraEmail
+		}
+		if cfg.AcceptanceCriteriaField == "" {
+			cfg.AcceptanceCriteriaField = opts.JiraCriteria
+		}
+	}
+
+	// shared by the tracker and the Jira sync
+	httpClient, err := issueTracker.NewHTTPClient(*cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error creating tracker http client: %w", err)
+	}
+	cfg.HTTPClient = httpClient
+	return cfg, nil
+}
+
+// checkCriteria returns the acceptance criteria checklists of the linked issues.
+func checkCriteria(ctx context.Context, openAIClient description.Completer, diff *github.CommitsComparison, issues issueTracker.Tracker, linked []*issueTracker.Issue, budget *usage.Budget) (string, error) {
+	var checklists []string
+	for _, issue := range linked {
+		if len(issue.AcceptanceCriteria) == 0 {
+			logs.Println("No acceptance criteria in", issue.Key)
+			continue
+		}
+		results, err := criteria.Check(ctx, openAIClient, diff, issue.AcceptanceCriteria)
+		if errors.Is(err, usage.ErrBudgetExceeded) {
+			budget.Skip("acceptance criteria of "+issue.Key, err.Error())
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("error checking acceptance criteria of %s: %w", issue.Key, err)
+		}
+		checklists = append(checklists, criteria.Checklist(issue.Key, results))
+	}
+	return strings.Join(checklists, "\n"), nil
+}
+
+// postCriteriaComment posts the checklists as a comment, re-runs update the earlier comment.
+func postCriteriaComment(ctx context.Context, host codehost.Host, checklists string) error {
+	if opts.Test {
+		logs.Println(checklists)
+		return nil
+	}
+
+	logs.Println("Posting acceptance criteria")
+	body := criteria.CommentMarker + "\n" + checklists
+	if err := codehost.UpsertIssueComment(ctx, host, opts.Owner, opts.Repo, opts.PRNumber, criteria.CommentMarker, body); err != nil {
+		return fmt.Errorf("error posting acceptance criteria comment: %w", err)
+	}
+	return nil
+}
+
+var htmlComment = regexp.MustCompile(`(?s)<!--.*?-->`)
+
+// syncJira comments on the linked tickets and moves them through the workflow. Comments are updated in
+// place on later runs, and tickets already in the target status are left alone.
+func syncJira(ctx context.Context, cfg issueTracker.Config, issues issueTracker.Tracker, pr *github.PullRequest, diff *github.CommitsComparison, body string) error {
+	transition := opts.JiraOpenStatus
+	state := "opened"
+	if pr.GetMerged() {
+		transition = opts.JiraMergeStatus
+		state = "merged"
+	} else if pr.GetState() != "open" {
+		transition = ""
+		state = "closed"
+	}
+	if !opts.JiraComment && transition == "" {
+		return nil
+	}
+
+	keys := issues.Keys(pr, diff.Commits)
+	if len(keys) == 0 {
+		return nil
+	}
+	client, err := jira.NewClient(jira.Config{BaseURL: cfg.URL, Email: cfg.Email, Token: cfg.Token, HTTPClient: cfg.HTTPClient})
+	if err != nil {
+		return fmt.Errorf("error creating Jira client: %w", err)
+	}
+
+	var summary []string
+	for _, line := range strings.Split(htmlComment.ReplaceAllString(marker.ExtractGenerated(body), ""), "\n") {
+		if !strings.HasPrefix(line, "### "+issues.Name()) {
+			summary = append(summary, line)
+		}
+	}
+	commentMarker := jira.CommentMarker(opts.Owner, opts.Repo, pr.GetNumber())
+	comment := fmt.Sprintf("%sPull request [#%d %s|%s] was %s.\n\n%s", commentMarker, pr.GetNumber(), pr.GetTitle(), pr.GetHTMLURL(), state, strings.TrimSpace(strings.Join(summary, "\n")))
+
+	for _, key := range keys {
+		if opts.Test {
+			logs.Printf("Jira %s: comment %t, transition %q\n", key, opts.JiraComment, transition)
+			continue
+		}
+		if opts.JiraComment {
+			logs.Println("Commenting on Jira ticket", key)
+			if err := client.UpsertComment(ctx, key, commentMarker, comment); err != nil {
+				logs.Printf("Error commenting on Jira ticket: %v \n", err)
+			}
+		}
+		if transition != "" {
+			ok, err := client.Transition(ctx, key, transition)
+			if err != nil {
+				logs.Printf("Error transitioning Jira ticket: %v \n", err)
+			} else if !ok {
+				logs.Printf("Jira ticket %s has no transition %q, skipping\n", key, transition)
+			}
+		}
+	}
+
+	return nil
+}
+
+// issuesHeader links the issues at the top of the description.
+func issuesHeader(issues issueTracker.Tracker, keys []string) string {
+	links := make([]string, 0, len(keys))
+	for _, key := range keys {
+		links = append(links, fmt.Sprintf("[%s](%s)", key, issues.URL(key)))
+	}
+	if len(links) == 1 {
+		return fmt.Sprintf("### %s: %s \n\n", issues.Name(), links[0])
+	}
+	return fmt.Sprintf("### %ss: %s \n\n", issues.Name(), strings.Join(links, ", "))
+}
+
+// updateTitle validates, fixes or generates the pull request title depending on the title mode.
+func updateTitle(ctx context.Context, openAIClient description.Completer, host codehost.Host, pr *github.PullRequest, diff *github.CommitsComparison, body string, result *output.Result) error {
+	validationErr := description.ValidateTitle(pr.GetTitle())
+	if validationErr == nil && opts.TitleMode != "generate" {
+		logs.Println("Title follows Conventional Commits")
 		return nil
 	}
 
-	// Update the pull request description
-	fmt.Println("Updating pull request")
-	updatePr := &github.PullRequest{Body: github.String(completion)}
-	if _, err = githubClient.UpdatePullRequest(ctx, opts.Owner, opts.Repo, opts.PRNumber, updatePr); err != nil {
-		return fmt.Errorf("error updating pull request: %w", err)
+	title, err := description.GenerateTitle(ctx, openAIClient, diff, pr, body)
+	if errors.Is(err, usage.ErrBudgetExceeded) {
+		logs.Println("Skipping title generation:", err)
+		title = ""
+	} else if err != nil {
+		return fmt.Errorf("error generating title: %w", err)
+	}
+
+	result.Title = title
+
+	if opts.TitleMode == "validate" {
+		if title != "" {
+			return fmt.Errorf("%w %q: %v. Suggestion: %s", errInvalidTitle, pr.GetTitle(), validationErr, title)
+		}
+		return fmt.Errorf("%w %q: %v", errInvalidTitle, pr.GetTitle(), validationErr)
+	}
+
+	if title == "" || title == pr.GetTitle() {
+		return nil
+	}
+
+	logs.Printf("Updating title: %q -> %q\n", pr.GetTitle(), title)
+	if opts.Test {
+		return nil
+	}
+	if _, err = host.UpdatePullRequest(ctx, opts.Owner, opts.Repo, opts.PRNumber, &github.PullRequest{Title: github.String(title)}); err != nil {
+		return fmt.Errorf("error updating pull request title: %w", err)
 	}
 
 	return nil
 }
+
+func newCompleter(tracker *usage.Tracker, budget *usage.Budget, completionCache *cache.Cache) (description.Completer, error) {
+	if opts.Provider == "anthropic" {
+		return anthropic.NewClient(anthropic.Config{
+			Token:           opts.AnthropicToken,
+			Model:           opts.AnthropicModel,
+			MaxPromptLength: opts.MaxPromptLength,
+			Tracker:         tracker,
+			Budget:          budget,
+			Cache:           completionCache,
+		})
+	}
+
+	return oAIClient.NewClientWithConfig(oAIClient.Config{
+		Provider:        oAIClient.Provider(opts.Provider),
+		Token:           opts.OpenAIToken,
+		Model:           opts.OpenAIModel,
+		BaseURL:         opts.OpenAIBaseURL,
+		MaxPromptLength: opts.MaxPromptLength,
+		Tracker:         tracker,
+		Budget:          budget,
+		Cache:           completionCache,
+	})
+}
+
+func reportUsage(tracker *usage.Tracker, prices usage.Prices) {
+	report := tracker.Report(prices)
+	logs.Print(report)
+
+	if opts.UsageReport != "" {
+		if err := report.WriteJSON(opts.UsageReport); err != nil {
+			logs.Printf("Error writing usage report: %v \n", err)
+		}
+	}
+}
+
+func writeResult(w io.Writer, result *output.Result, tracker *usage.Tracker, prices usage.Prices) {
+	if opts.Output != output.FormatJSON {
+		return
+	}
+
+	result.Metadata = output.Metadata{
+		Provider: opts.Provider,
+		Model:    modelName(),
+		Usage:    tracker.Report(prices),
+	}
+	if err := result.Write(w, opts.OutputFile); err != nil {
+		logs.Printf("Error writing result: %v \n", err)
+	}
+}
+
+func modelName() string {
+	if opts.Provider == "anthropic" {
+		return opts.AnthropicModel
+	}
+	if opts.OpenAIModel == "" && opts.Provider == string(oAIClient.ProviderOpenAI) {
+		return oAIClient.DefaultModel
+	}
+	return opts.OpenAIModel
+}
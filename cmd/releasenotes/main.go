@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/google/go-github/v51/github"
+	"github.com/jessevdk/go-flags"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/anthropic"
+	"github.com/ravilushqa/gpt-pullrequest-updater/cache"
+	"github.com/ravilushqa/gpt-pullrequest-updater/hosts"
+	"github.com/ravilushqa/gpt-pullrequest-updater/logs"
+	oAIClient "github.com/ravilushqa/gpt-pullrequest-updater/openai"
+	"github.com/ravilushqa/gpt-pullrequest-updater/releasenotes"
+	"github.com/ravilushqa/gpt-pullrequest-updater/usage"
+)
+
+var opts struct {
+	hosts.GitHubOptions
+	OpenAIToken     string        `long:"openai-token" env:"OPENAI_TOKEN" description:"OpenAI token. Not required for the local provider"`
+	Owner           string        `long:"owner" env:"OWNER" description:"GitHub owner" required:"true"`
+	Repo            string        `long:"repo" env:"REPO" description:"GitHub repo" required:"true"`
+	From            string        `long:"from" env:"FROM" description:"Tag or SHA of the previous release" required:"true"`
+	To              string        `long:"to" env:"TO" description:"Tag or SHA of the new release" required:"true"`
+	OpenAIModel     string        `long:"openai-model" env:"OPENAI_MODEL" description:"OpenAI model. Defaults to gpt-3.5-turbo for the openai provider"`
+	OpenAIBaseURL   string        `long:"openai-base-url" env:"OPENAI_BASE_URL" description:"OpenAI-compatible API base URL. Example: http://localhost:11434/v1"`
+	Provider        string        `long:"provider" env:"PROVIDER" description:"Completion provider" choice:"openai" choice:"local" choice:"anthropic" default:"openai"`
+	AnthropicToken  string        `long:"anthropic-token" env:"ANTHROPIC_API_KEY" description:"Anthropic API key. Required for the anthropic provider"`
+	AnthropicModel  string        `long:"anthropic-model" env:"ANTHROPIC_MODEL" description:"Anthropic model" default:"claude-3-5-sonnet-latest"`
+	PriceFile       string        `long:"price-file" env:"PRICE_FILE" description:"JSON file with model prices in USD per million tokens overriding the built-in table"`
+	UsageReport     string        `long:"usage-report" env:"USAGE_REPORT" description:"Write the token usage report as JSON to this file"`
+	MaxTokensPerRun int           `long:"max-tokens-per-run" env:"MAX_TOKENS_PER_RUN" description:"Stop calling the API once this many tokens are used. 0 means no limit"`
+	MaxCostPerRun   float64       `long:"max-cost-per-run" env:"MAX_COST_PER_RUN" description:"Stop calling the API once this estimated cost in USD is reached. 0 means no limit"`
+	CacheDir        string        `long:"cache-dir" env:"CACHE_DIR" description:"Directory for caching completions. Caching is disabled when empty"`
+	CacheTTL        time.Duration `long:"cache-ttl" env:"CACHE_TTL" description:"How long cached completions are reused" default:"168h"`
+	CacheMaxSizeMB  int64         `long:"cache-max-size-mb" env:"CACHE_MAX_SIZE_MB" description:"Maximum size of the cache directory in megabytes" default:"100"`
+	MaxPromptLength int           `long:"max-prompt-length" env:"MAX_PROMPT_LENGTH" description:"Maximum prompt length in characters. Defaults depend on the provider"`
+	OutputFile      string        `long:"output-file" env:"OUTPUT_FILE" description:"Write the release notes to this file instead of stdout"`
+	DraftRelease    bool          `long:"draft-release" env:"DRAFT_RELEASE" description:"Create a draft GitHub release for the --to tag with the release notes"`
+	ReleaseName     string        `long:"release-name" env:"RELEASE_NAME" description:"Name of the draft release. Defaults to the --to tag"`
+	Test            bool          `long:"test" env:"TEST" description:"Test mode"`
+}
+
+func main() {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	if _, err := flags.Parse(&opts); err != nil {
+		if err.(*flags.Error).Type != flags.ErrHelp {
+			logs.Printf("Error parsing flags: %v \n", err)
+		}
+		os.Exit(0)
+	}
+
+	if opts.OutputFile == "" {
+		// keep stdout for the release notes only
+		logs.SetOutput(os.Stderr)
+	}
+
+	if err := run(ctx); err != nil {
+		panic(err)
+	}
+}
+
+func run(ctx context.Context) error {
+	prices, err := usage.LoadPrices(opts.PriceFile)
+	if err != nil {
+		return err
+	}
+	tracker := usage.NewTracker()
+	defer reportUsage(tracker, prices)
+	budget := usage.NewBudget(tracker, prices, opts.MaxTokensPerRun, opts.MaxCostPerRun)
+
+	var completionCache *cache.Cache
+	if opts.CacheDir != "" {
+		completionCache, err = cache.New(opts.CacheDir, opts.CacheTTL, opts.CacheMaxSizeMB<<20)
+		if err != nil {
+			return err
+		}
+	}
+
+	openAIClient, err := newCompleter(tracker, budget, completionCache)
+	if err != nil {
+		return fmt.Errorf("error creating completion client: %w", err)
+	}
+	githubClient, err := hosts.NewGitHub(ctx, opts.GitHubOptions, opts.Owner, opts.Repo)
+	if err != nil {
+		return fmt.Errorf("error creating github client: %w", err)
+	}
+
+	prs, err := releasenotes.FindMergedPullRequests(ctx, githubClient, opts.Owner, opts.Repo, opts.From, opts.To)
+	if err != nil {
+		return fmt.Errorf("error finding merged pull requests: %w", err)
+	}
+	logs.Printf("Found %d merged pull requests between %s and %s\n", len(prs), opts.From, opts.To)
+
+	notes, err := releasenotes.Generate(ctx, openAIClient, prs)
+	if err != nil {
+		return fmt.Errorf("error generating release notes: %w", err)
+	}
+
+	if opts.OutputFile != "" {
+		if err := os.WriteFile(opts.OutputFile, []byte(notes+"\n"), 0o644); err != nil {
+			return fmt.Errorf("error writing release notes: %w", err)
+		}
+	} else {
+		fmt.Println(notes)
+	}
+
+	if !opts.DraftRelease || opts.Test {
+		return nil
+	}
+
+	name := opts.ReleaseName
+	if name == "" {
+		name = opts.To
+	}
+	logs.Println("Creating draft release")
+	release := &github.RepositoryRelease{
+		TagName: github.String(opts.To),
+		Name:    github.String(name),
+		Body:    github.String(notes),
+		Draft:   github.Bool(true),
+	}
+	if _, err := githubClient.CreateRelease(ctx, opts.Owner, opts.Repo, release); err != nil {
+		return fmt.Errorf("error creating release: %w", err)
+	}
+
+	return nil
+}
+
+func newCompleter(tracker *usage.Tracker, budget *usage.Budget, completionCache *cache.Cache) (releasenotes.Completer, error) {
+	if opts.Provider == "anthropic" {
+		return anthropic.NewClient(anthropic.Config{
+			Token:           opts.AnthropicToken,
+			Model:           opts.AnthropicModel,
+			MaxPromptLength: opts.MaxPromptLength,
+			Tracker:         tracker,
+			Budget:          budget,
+			Cache:           completionCache,
+		})
+	}
+
+	return oAIClient.NewClientWithConfig(oAIClient.Config{
+		Provider:        oAIClient.Provider(opts.Provider),
+		Token:           opts.OpenAIToken,
+		Model:           opts.OpenAIModel,
+		BaseURL:         opts.OpenAIBaseURL,
+		MaxPromptLength: opts.MaxPromptLength,
+		Tracker:         tracker,
+		Budget:          budget,
+		Cache:           completionCache,
+	})
+}
+
+func reportUsage(tracker *usage.Tracker, prices usage.Prices) {
+	report := tracker.Report(prices)
+	logs.Print(report)
+
+	if opts.UsageReport != "" {
+		if err := report.WriteJSON(opts.UsageReport); err != nil {
+			logs.Printf("Error writing usage report: %v \n", err)
+		}
+	}
+}
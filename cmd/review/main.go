@@ -3,71 +3,211 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/jessevdk/go-flags"
 
-	ghClient "github.com/ravilushqa/gpt-pullrequest-updater/github"
+	"github.com/ravilushqa/gpt-pullrequest-updater/anthropic"
+	"github.com/ravilushqa/gpt-pullrequest-updater/cache"
+	"github.com/ravilushqa/gpt-pullrequest-updater/codehost"
+	"github.com/ravilushqa/gpt-pullrequest-updater/gitlab"
+	"github.com/ravilushqa/gpt-pullrequest-updater/hosts"
+	"github.com/ravilushqa/gpt-pullrequest-updater/logs"
 	oAIClient "github.com/ravilushqa/gpt-pullrequest-updater/openai"
+	"github.com/ravilushqa/gpt-pullrequest-updater/output"
 	"github.com/ravilushqa/gpt-pullrequest-updater/review"
+	"github.com/ravilushqa/gpt-pullrequest-updater/usage"
 )
 
 var opts struct {
-	GithubToken string `long:"gh-token" env:"GITHUB_TOKEN" description:"GitHub token" required:"true"`
-	OpenAIToken string `long:"openai-token" env:"OPENAI_TOKEN" description:"OpenAI token" required:"true"`
-	Owner       string `long:"owner" env:"OWNER" description:"GitHub owner" required:"true"`
-	Repo        string `long:"repo" env:"REPO" description:"GitHub repo" required:"true"`
-	PRNumber    int    `long:"pr-number" env:"PR_NUMBER" description:"Pull request number" required:"true"`
-	OpenAIModel string `long:"openai-model" env:"OPENAI_MODEL" description:"OpenAI model" default:"gpt-3.5-turbo"`
-	Test        bool   `long:"test" env:"TEST" description:"Test mode"`
+	hosts.Options
+	OpenAIToken     string        `long:"openai-token" env:"OPENAI_TOKEN" description:"OpenAI token. Not required for the local provider"`
+	Owner           string        `long:"owner" env:"OWNER" description:"Repository owner. The project namespace on GitLab, the workspace on Bitbucket Cloud and the project key on Bitbucket Data Center" required:"true"`
+	Repo            string        `long:"repo" env:"REPO" description:"Repository name. The project name on GitLab and the repository slug on Bitbucket" required:"true"`
+	PRNumber        int           `long:"pr-number" env:"PR_NUMBER" description:"Pull request number. The merge request IID on GitLab" required:"true"`
+	OpenAIModel     string        `long:"openai-model" env:"OPENAI_MODEL" description:"OpenAI model. Defaults to gpt-3.5-turbo for the openai provider"`
+	OpenAIBaseURL   string        `long:"openai-base-url" env:"OPENAI_BASE_URL" description:"OpenAI-compatible API base URL. Example: http://localhost:11434/v1"`
+	Provider        string        `long:"provider" env:"PROVIDER" description:"Completion provider" choice:"openai" choice:"local" choice:"anthropic" default:"openai"`
+	AnthropicToken  string        `long:"anthropic-token" env:"ANTHROPIC_API_KEY" description:"Anthropic API key. Required for the anthropic provider"`
+	AnthropicModel  string        `long:"anthropic-model" env:"ANTHROPIC_MODEL" description:"Anthropic model" default:"claude-3-5-sonnet-latest"`
+	PriceFile       string        `long:"price-file" env:"PRICE_FILE" description:"JSON file with model prices in USD per million tokens overriding the built-in table"`
+	UsageReport     string        `long:"usage-report" env:"USAGE_REPORT" description:"Write the token usage report as JSON to this file"`
+	UsageFooter     bool          `long:"usage-footer" env:"USAGE_FOOTER" description:"Post the token usage report as a pull request comment"`
+	MaxTokensPerRun int           `long:"max-tokens-per-run" env:"MAX_TOKENS_PER_RUN" description:"Stop calling the API once this many tokens are used. 0 means no limit"`
+	MaxCostPerRun   float64       `long:"max-cost-per-run" env:"MAX_COST_PER_RUN" description:"Stop calling the API once this estimated cost in USD is reached. 0 means no limit"`
+	CacheDir        string        `long:"cache-dir" env:"CACHE_DIR" description:"Directory for caching completions. Caching is disabled when empty"`
+	CacheTTL        time.Duration `long:"cache-ttl" env:"CACHE_TTL" description:"How long cached completions are reused" default:"168h"`
+	CacheMaxSizeMB  int64         `long:"cache-max-size-mb" env:"CACHE_MAX_SIZE_MB" description:"Maximum size of the cache directory in megabytes" default:"100"`
+	MaxPromptLength int           `long:"max-prompt-length" env:"MAX_PROMPT_LENGTH" description:"Maximum prompt length in characters. Defaults depend on the provider"`
+	Test            bool          `long:"test" env:"TEST" description:"Test mode"`
+	Output          string        `long:"output" env:"OUTPUT" description:"Output format of the result. With json the logs go to stderr" choice:"text" choice:"json" default:"text"`
+	OutputFile      string        `long:"output-file" env:"OUTPUT_FILE" description:"Write the json result to this file instead of stdout"`
 }
 
 func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
+	// merge request pipelines of GitLab CI need no further configuration
+	gitlab.ApplyCIEnv()
 	if _, err := flags.Parse(&opts); err != nil {
 		if err.(*flags.Error).Type != flags.ErrHelp {
-			fmt.Printf("Error parsing flags: %v \n", err)
+			logs.Printf("Error parsing flags: %v \n", err)
 		}
 		os.Exit(0)
 	}
 
-	if err := run(ctx); err != nil {
+	if opts.Output == output.FormatJSON && opts.OutputFile == "" {
+		// keep stdout for the result only
+		logs.SetOutput(os.Stderr)
+	}
+
+	if err := run(ctx, os.Stdout); err != nil {
 		panic(err)
 	}
 }
 
-func run(ctx context.Context) error {
-	openAIClient := oAIClient.NewClient(opts.OpenAIToken, opts.OpenAIModel)
-	githubClient := ghClient.NewClient(ctx, opts.GithubToken)
+func run(ctx context.Context, stdout io.Writer) (err error) {
+	prices, err := usage.LoadPrices(opts.PriceFile)
+	if err != nil {
+		return err
+	}
+	tracker := usage.NewTracker()
+	defer reportUsage(tracker, prices)
+	result := output.New("review", opts.Owner, opts.Repo, opts.PRNumber)
+	defer func() {
+		result.SetError(err)
+		writeResult(stdout, result, tracker, prices)
+	}()
+	budget := usage.NewBudget(tracker, prices, opts.MaxTokensPerRun, opts.MaxCostPerRun)
+
+	var completionCache *cache.Cache
+	if opts.CacheDir != "" {
+		completionCache, err = cache.New(opts.CacheDir, opts.CacheTTL, opts.CacheMaxSizeMB<<20)
+		if err != nil {
+			return err
+		}
+	}
+
+	openAIClient, err := newCompleter(tracker, budget, completionCache)
+	if err != nil {
+		return fmt.Errorf("error creating completion client: %w", err)
+	}
+	host, err := hosts.New(ctx, opts.Options, opts.Owner, opts.Repo)
+	if err != nil {
+		return fmt.Errorf("error creating code host client: %w", err)
+	}
 
-	pr, err := githubClient.GetPullRequest(ctx, opts.Owner, opts.Repo, opts.PRNumber)
+	pr, err := host.GetPullRequest(ctx, opts.Owner, opts.Repo, opts.PRNumber)
 	if err != nil {
 		return fmt.Errorf("error getting pull request: %w", err)
 	}
 
-	diff, err := githubClient.CompareCommits(ctx, opts.Owner, opts.Repo, pr.GetBase().GetSHA(), pr.GetHead().GetSHA())
+	diff, coverage, err := host.GetPullRequestChanges(ctx, opts.Owner, opts.Repo, pr)
 	if err != nil {
 		return fmt.Errorf("error getting commits: %w", err)
 	}
+	if !coverage.Complete() {
+		logs.Println("Warning:", coverage)
+	}
+
+	result.PullRequest.URL = pr.GetHTMLURL()
+	result.AddCoverage(coverage)
 
-	comments, err := review.GenerateCommentsFromDiff(ctx, openAIClient, diff)
+	reviews, err := review.ReviewDiff(ctx, openAIClient, diff)
 	if err != nil {
 		return err
 	}
+	result.AddReviews(reviews)
+	comments := review.Comments(diff, reviews)
 
 	if opts.Test {
-		fmt.Printf("Comments: %v \n", comments)
+		logs.Printf("Comments: %v \n", comments)
 		return nil
 	}
 
-	err = review.PushComments(ctx, githubClient, opts.Owner, opts.Repo, opts.PRNumber, comments)
+	err = review.PushComments(ctx, host, opts.Owner, opts.Repo, opts.PRNumber, comments)
 	if err != nil {
 		return fmt.Errorf("error creating comments: %w", err)
 	}
 
+	if !coverage.Complete() {
+		body := review.CoverageMarker + "\nReview is incomplete. " + coverage.String()
+		if err := codehost.UpsertIssueComment(ctx, host, opts.Owner, opts.Repo, opts.PRNumber, review.CoverageMarker, body); err != nil {
+			return fmt.Errorf("error posting coverage comment: %w", err)
+		}
+	}
+
+	if opts.UsageFooter {
+		body := fmt.Sprintf("%s\nReview finished with %d comments.%s", review.UsageMarker, len(comments), tracker.Report(prices).Footer())
+		if err := codehost.UpsertIssueComment(ctx, host, opts.Owner, opts.Repo, opts.PRNumber, review.UsageMarker, body); err != nil {
+			return fmt.Errorf("error posting usage comment: %w", err)
+		}
+	}
+
 	return nil
 }
+
+func newCompleter(tracker *usage.Tracker, budget *usage.Budget, completionCache *cache.Cache) (review.Completer, error) {
+	if opts.Provider == "anthropic" {
+		return anthropic.NewClient(anthropic.Config{
+			Token:           opts.AnthropicToken,
+			Model:           opts.AnthropicModel,
+			MaxPromptLength: opts.MaxPromptLength,
+			Tracker:         tracker,
+			Budget:          budget,
+			Cache:           completionCache,
+		})
+	}
+
+	return oAIClient.NewClientWithConfig(oAIClient.Config{
+		Provider:        oAIClient.Provider(opts.Provider),
+		Token:           opts.OpenAIToken,
+		Model:           opts.OpenAIModel,
+		BaseURL:         opts.OpenAIBaseURL,
+		MaxPromptLength: opts.MaxPromptLength,
+		Tracker:         tracker,
+		Budget:          budget,
+		Cache:           completionCache,
+	})
+}
+
+func reportUsage(tracker *usage.Tracker, prices usage.Prices) {
+	report := tracker.Report(prices)
+	logs.Print(report)
+
+	if opts.UsageReport != "" {
+		if err := report.WriteJSON(opts.UsageReport); err != nil {
+			logs.Printf("Error writing usage report: %v \n", err)
+		}
+	}
+}
+
+func writeResult(w io.Writer, result *output.Result, tracker *usage.Tracker, prices usage.Prices) {
+	if opts.Output != output.FormatJSON {
+		return
+	}
+
+	result.Metadata = output.Metadata{
+		Provider: opts.Provider,
+		Model:    modelName(),
+		Usage:    tracker.Report(prices),
+	}
+	if err := result.Write(w, opts.OutputFile); err != nil {
+		logs.Printf("Error writing result: %v \n", err)
+	}
+}
+
+func modelName() string {
+	if opts.Provider == "anthropic" {
+		return opts.AnthropicModel
+	}
+	if opts.OpenAIModel == "" && opts.Provider == string(oAIClient.ProviderOpenAI) {
+		return oAIClient.DefaultModel
+	}
+	return opts.OpenAIModel
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/anthropic"
+	"github.com/ravilushqa/gpt-pullrequest-updater/cache"
+	"github.com/ravilushqa/gpt-pullrequest-updater/codehost"
+	"github.com/ravilushqa/gpt-pullrequest-updater/gitlab"
+	"github.com/ravilushqa/gpt-pullrequest-updater/hosts"
+	"github.com/ravilushqa/gpt-pullrequest-updater/logs"
+	"github.com/ravilushqa/gpt-pullrequest-updater/marker"
+	oAIClient "github.com/ravilushqa/gpt-pullrequest-updater/openai"
+	"github.com/ravilushqa/gpt-pullrequest-updater/squash"
+	"github.com/ravilushqa/gpt-pullrequest-updater/usage"
+)
+
+var opts struct {
+	hosts.Options
+	OpenAIToken     string        `long:"openai-token" env:"OPENAI_TOKEN" description:"OpenAI token. Not required for the local provider"`
+	Owner           string        `long:"owner" env:"OWNER" description:"Repository owner. The project namespace on GitLab, the workspace on Bitbucket Cloud and the project key on Bitbucket Data Center" required:"true"`
+	Repo            string        `long:"repo" env:"REPO" description:"Repository name. The project name on GitLab and the repository slug on Bitbucket" required:"true"`
+	PRNumber        int           `long:"pr-number" env:"PR_NUMBER" description:"Pull request number. The merge request IID on GitLab" required:"true"`
+	OpenAIModel     string        `long:"openai-model" env:"OPENAI_MODEL" description:"OpenAI model. Defaults to gpt-3.5-turbo for the openai provider"`
+	OpenAIBaseURL   string        `long:"openai-base-url" env:"OPENAI_BASE_URL" description:"OpenAI-compatible API base URL. Example: http://localhost:11434/v1"`
+	Provider        string        `long:"provider" env:"PROVIDER" description:"Completion provider" choice:"openai" choice:"local" choice:"anthropic" default:"openai"`
+	AnthropicToken  string        `long:"anthropic-token" env:"ANTHROPIC_API_KEY" description:"Anthropic API key. Required for the anthropic provider"`
+	AnthropicModel  string        `long:"anthropic-model" env:"ANTHROPIC_MODEL" description:"Anthropic model" default:"claude-3-5-sonnet-latest"`
+	PriceFile       string        `long:"price-file" env:"PRICE_FILE" description:"JSON file with model prices in USD per million tokens overriding the built-in table"`
+	UsageReport     string        `long:"usage-report" env:"USAGE_REPORT" description:"Write the token usage report as JSON to this file"`
+	MaxTokensPerRun int           `long:"max-tokens-per-run" env:"MAX_TOKENS_PER_RUN" description:"Stop calling the API once this many tokens are used. 0 means no limit"`
+	MaxCostPerRun   float64       `long:"max-cost-per-run" env:"MAX_COST_PER_RUN" description:"Stop calling the API once this estimated cost in USD is reached. 0 means no limit"`
+	CacheDir        string        `long:"cache-dir" env:"CACHE_DIR" description:"Directory for caching completions. Caching is disabled when empty"`
+	CacheTTL        time.Duration `long:"cache-ttl" env:"CACHE_TTL" description:"How long cached completions are reused" default:"168h"`
+	CacheMaxSizeMB  int64         `long:"cache-max-size-mb" env:"CACHE_MAX_SIZE_MB" description:"Maximum size of the cache directory in megabytes" default:"100"`
+	MaxPromptLength int           `long:"max-prompt-length" env:"MAX_PROMPT_LENGTH" description:"Maximum prompt length in characters. Defaults depend on the provider"`
+	PostComment     bool          `long:"post-comment" env:"POST_COMMENT" description:"Post the commit message as a pull request comment instead of printing it"`
+	Test            bool          `long:"test" env:"TEST" description:"Test mode"`
+}
+
+func main() {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	// merge request pipelines of GitLab CI need no further configuration
+	gitlab.ApplyCIEnv()
+	if _, err := flags.Parse(&opts); err != nil {
+		if err.(*flags.Error).Type != flags.ErrHelp {
+			logs.Printf("Error parsing flags: %v \n", err)
+		}
+		os.Exit(0)
+	}
+
+	// keep stdout for the message only, so it can be piped into git commit -F -
+	logs.SetOutput(os.Stderr)
+
+	if err := run(ctx); err != nil {
+		panic(err)
+	}
+}
+
+func run(ctx context.Context) error {
+	prices, err := usage.LoadPrices(opts.PriceFile)
+	if err != nil {
+		return err
+	}
+	tracker := usage.NewTracker()
+	defer reportUsage(tracker, prices)
+	budget := usage.NewBudget(tracker, prices, opts.MaxTokensPerRun, opts.MaxCostPerRun)
+
+	var completionCache *cache.Cache
+	if opts.CacheDir != "" {
+		completionCache, err = cache.New(opts.CacheDir, opts.CacheTTL, opts.CacheMaxSizeMB<<20)
+		if err != nil {
+			return err
+		}
+	}
+
+	openAIClient, err := newCompleter(tracker, budget, completionCache)
+	if err != nil {
+		return fmt.Errorf("error creating completion client: %w", err)
+	}
+	host, err := hosts.New(ctx, opts.Options, opts.Owner, opts.Repo)
+	if err != nil {
+		return fmt.Errorf("error creating code host client: %w", err)
+	}
+
+	pr, err := host.GetPullRequest(ctx, opts.Owner, opts.Repo, opts.PRNumber)
+	if err != nil {
+		return fmt.Errorf("error getting pull request: %w", err)
+	}
+
+	diff, coverage, err := host.GetPullRequestChanges(ctx, opts.Owner, opts.Repo, pr)
+	if err != nil {
+		return fmt.Errorf("error getting commits: %w", err)
+	}
+	if !coverage.Complete() {
+		logs.Println("Warning:", coverage)
+	}
+
+	// prefer the generated description, the rest of the body is usually checklists and notes
+	desc := marker.ExtractGenerated(pr.GetBody())
+	if desc == "" {
+		desc = pr.GetBody()
+	}
+
+	msg, err := squash.GenerateMessage(ctx, openAIClient, diff, pr, desc)
+	if err != nil {
+		return fmt.Errorf("error generating commit message: %w", err)
+	}
+
+	if !opts.PostComment || opts.Test {
+		fmt.Println(msg)
+		return nil
+	}
+
+	logs.Println("Posting commit message")
+	body := fmt.Sprintf("%s\nSuggested squash commit message:\n\n```\n%s\n```", squash.CommentMarker, msg)
+	if err := codehost.UpsertIssueComment(ctx, host, opts.Owner, opts.Repo, opts.PRNumber, squash.CommentMarker, body); err != nil {
+		return fmt.Errorf("error posting comment: %w", err)
+	}
+
+	return nil
+}
+
+func newCompleter(tracker *usage.Tracker, budget *usage.Budget, completionCache *cache.Cache) (squash.Completer, error) {
+	if opts.Provider == "anthropic" {
+		return anthropic.NewClient(anthropic.Config{
+			Token:           opts.AnthropicToken,
+			Model:           opts.AnthropicModel,
+			MaxPromptLength: opts.MaxPromptLength,
+			Tracker:         tracker,
+			Budget:          budget,
+			Cache:           completionCache,
+		})
+	}
+
+	return oAIClient.NewClientWithConfig(oAIClient.Config{
+		Provider:        oAIClient.Provider(opts.Provider),
+		Token:           opts.OpenAIToken,
+		Model:           opts.OpenAIModel,
+		BaseURL:         opts.OpenAIBaseURL,
+		MaxPromptLength: opts.MaxPromptLength,
+		Tracker:         tracker,
+		Budget:          budget,
+		Cache:           completionCache,
+	})
+}
+
+func reportUsage(tracker *usage.Tracker, prices usage.Prices) {
+	report := tracker.Report(prices)
+	logs.Print(report)
+
+	if opts.UsageReport != "" {
+		if err := report.WriteJSON(opts.UsageReport); err != nil {
+			logs.Printf("Error writing usage report: %v \n", err)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Package codehost abstracts the services hosting pull requests, e.g. GitHub and GitLab.
+package codehost
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v51/github"
+
+	ghClient "github.com/ravilushqa/gpt-pullrequest-updater/github"
+	"github.com/ravilushqa/gpt-pullrequest-updater/logs"
+)
+
+// Type is a kind of code host.
+type Type string
+
+const (
+	TypeGitHub Type = "github"
+	TypeGitLab Type = "gitlab"
+	// TypeBitbucket is Bitbucket Cloud.
+	TypeBitbucket Type = "bitbucket"
+	// TypeBitbucketDataCenter is Bitbucket Data Center and Bitbucket Server.
+	TypeBitbucketDataCenter Type = "bitbucket-datacenter"
+)
+
+// ErrNotSupported is returned for features the code host does not support.
+var ErrNotSupported = errors.New("not supported")
+
+// NotSupported returns the error for a feature that is not supported on the code host t.
+func NotSupported(feature string, t Type) error {
+	return fmt.Errorf("%s is %w on %s", feature, ErrNotSupported, t)
+}
+
+// Host reads and comments on pull requests. Pull requests of other hosts are mapped to the GitHub types
+// the commands work with: on GitLab owner is the namespace of the project, repo its name and number the
+// IID of the merge request.
+type Host interface {
+	GetPullRequest(ctx context.Context, owner, repo string, number int) (*github.PullRequest, error)
+	// GetPullRequestChanges returns the commits, oldest first, and the changed files of the pull request.
+	GetPullRequestChanges(ctx context.Context, owner, repo string, pr *github.PullRequest) (*github.CommitsComparison, ghClient.Coverage, error)
+	// UpdatePullRequest updates the title and the body when they are set.
+	UpdatePullRequest(ctx context.Context, owner, repo string, number int, pr *github.PullRequest) (*github.PullRequest, error)
+	// CreatePullRequestComment posts an inline comment. Position is the position in the patch of the file
+	// like on GitHub: the line below the first hunk header is 1.
+	CreatePullRequestComment(ctx context.Context, owner, repo string, number int, comment *github.PullRequestComment) (*github.PullRequestComment, error)
+	// CreateIssueComment posts a comment on the pull request itself.
+	CreateIssueComment(ctx context.Context, owner, repo string, number int, comment *github.IssueComment) (*github.IssueComment, error)
+	// ListIssueComments returns the comments on the pull request itself, oldest first.
+	ListIssueComments(ctx context.Context, owner, repo string, number int) ([]*github.IssueComment, error)
+	// EditIssueComment replaces the body of a comment on the pull request itself.
+	EditIssueComment(ctx context.Context, owner, repo string, number int, id int64, comment *github.IssueComment) (*github.IssueComment, error)
+}
+
+// UpsertIssueComment posts the comment on the pull request, or updates the earlier comment containing marker so
+// that re-runs do not post the same comment again. Marker is usually a hidden HTML comment included in body.
+func UpsertIssueComment(ctx context.Context, host Host, owner, repo string, number int, marker, body string) error {
+	comments, err := host.ListIssueComments(ctx, owner, repo, number)
+	if err != nil {
+		return fmt.Errorf("error listing comments: %w", err)
+	}
+
+	comment := &github.IssueComment{Body: github.String(body)}
+	for _, existing := range comments {
+		if !strings.Contains(existing.GetBody(), marker) {
+			continue
+		}
+		if existing.GetBody() == body {
+			logs.Println("Comment is up to date")
+			return nil
+		}
+		logs.Println("Updating comment")
+		if _, err := host.EditIssueComment(ctx, owner, repo, number, existing.GetID(), comment); err != nil {
+			return fmt.Errorf("error updating comment: %w", err)
+		}
+		return nil
+	}
+
+	logs.Println("Creating comment")
+	if _, err := host.CreateIssueComment(ctx, owner, repo, number, comment); err != nil {
+		return fmt.Errorf("error creating comment: %w", err)
+	}
+	return nil
+}
+
+// TemplateGetter is implemented by hosts that support pull request templates.
+type TemplateGetter interface {
+	GetPullRequestTemplate(ctx context.Context, owner, repo, ref string) (string, error)
+}
+
+var _ Host = (*ghClient.Client)(nil)
+
+// Line is a line of a patch. Old is zero for added lines and New is zero for removed lines.
+type Line struct {
+	Old, New int
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -([0-9]+)(?:,[0-9]+)? \+([0-9]+)(?:,[0-9]+)? @@`)
+
+// LineAt maps a position in the patch to the line numbers of the old and new file. It fails for hunk
+// headers and positions outside of the patch.
+func LineAt(patch string, position int) (Line, error) {
+	var oldLine, newLine int
+	lines := strings.Split(patch, "\n")
+	for i, line := range lines {
+		if m := hunkHeader.FindStringSubmatch(line); m != nil {
+			if i == position {
+				return Line{}, fmt.Errorf("position %d is a hunk header", position)
+			}
+			oldLine, _ = strconv.Atoi(m[1])
+			newLine, _ = strconv.Atoi(m[2])
+			continue
+		}
+		if i == 0 {
+			return Line{}, errors.New("patch does not start with a hunk header")
+		}
+
+		var l Line
+		switch {
+		case strings.HasPrefix(line, "+"):
+			l = Line{New: newLine}
+			newLine++
+		case strings.HasPrefix(line, "-"):
+			l = Line{Old: oldLine}
+			oldLine++
+		case strings.HasPrefix(line, `\`):
+			// "\ No newline at end of file" belongs to the previous line
+			l = Line{}
+		default:
+			l = Line{Old: oldLine, New: newLine}
+			oldLine++
+			newLine++
+		}
+		if i == position {
+			if l == (Line{}) {
+				return Line{}, fmt.Errorf("position %d is not a line", position)
+			}
+			return l, nil
+		}
+	}
+
+	return Line{}, fmt.Errorf("position %d is outside of the patch", position)
+}
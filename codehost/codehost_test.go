@@ -0,0 +1,120 @@
+package codehost
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-github/v51/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHost keeps the comments of a single pull request in memory.
+type fakeHost struct {
+	Host
+	comments []*github.IssueComment
+	created  int
+	edited   []int64
+}
+
+func (f *fakeHost) ListIssueComments(ctx context.Context, owner, repo string, number int) ([]*github.IssueComment, error) {
+	return f.comments, nil
+}
+
+func (f *fakeHost) CreateIssueComment(ctx context.Context, owner, repo string, number int, comment *github.IssueComment) (*github.IssueComment, error) {
+	f.created++
+	return comment, nil
+}
+
+func (f *fakeHost) EditIssueComment(ctx context.Context, owner, repo string, number int, id int64, comment *github.IssueComment) (*github.IssueComment, error) {
+	f.edited = append(f.edited, id)
+	return comment, nil
+}
+
+func TestLineAt(t *testing.T) {
+	patch := "@@ -10,4 +10,4 @@ func main() {\n" +
+		" \ta := 1\n" +
+		"-\tb := 2\n" +
+		"+\tb := 3\n" +
+		" \tc := 4\n" +
+		"@@ -40,2 +40,3 @@\n" +
+		" }\n" +
+		"+// end\n" +
+		"\\ No newline at end of file"
+
+	testCases := []struct {
+		name      string
+		position  int
+		expected  Line
+		expectErr bool
+	}{
+		{name: "Context", position: 1, expected: Line{Old: 10, New: 10}},
+		{name: "Removed", position: 2, expected: Line{Old: 11}},
+		{name: "Added", position: 3, expected: Line{New: 11}},
+		{name: "Context after change", position: 4, expected: Line{Old: 12, New: 12}},
+		{name: "Second hunk header", position: 5, expectErr: true},
+		{name: "Second hunk", position: 7, expected: Line{New: 41}},
+		{name: "No newline marker", position: 8, expectErr: true},
+		{name: "First hunk header", position: 0, expectErr: true},
+		{name: "Outside", position: 9, expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			line, err := LineAt(patch, tc.position)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, line)
+		})
+	}
+}
+
+func TestUpsertIssueComment(t *testing.T) {
+	const marker = "<!-- gpt:test -->"
+	body := marker + "\nNew"
+
+	testCases := []struct {
+		name     string
+		comments []*github.IssueComment
+		created  int
+		edited   []int64
+	}{
+		{
+			name:     "No earlier comment",
+			comments: []*github.IssueComment{{ID: github.Int64(1), Body: github.String("Looks good")}},
+			created:  1,
+		},
+		{
+			name: "Earlier comment",
+			comments: []*github.IssueComment{
+				{ID: github.Int64(1), Body: github.String("Looks good")},
+				{ID: github.Int64(2), Body: github.String(marker + "\nOld")},
+			},
+			edited: []int64{2},
+		},
+		{
+			name:     "Up to date",
+			comments: []*github.IssueComment{{ID: github.Int64(2), Body: github.String(body)}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			host := &fakeHost{comments: tc.comments}
+
+			require.NoError(t, UpsertIssueComment(context.Background(), host, "owner", "repo", 1, marker, body))
+			assert.Equal(t, tc.created, host.created)
+			assert.Equal(t, tc.edited, host.edited)
+		})
+	}
+}
+
+func TestNotSupported(t *testing.T) {
+	err := NotSupported("--pr-template", TypeBitbucket)
+	assert.EqualError(t, err, "--pr-template is not supported on bitbucket")
+	assert.True(t, errors.Is(err, ErrNotSupported))
+}
@@ -0,0 +1,166 @@
+package criteria
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v51/github"
+	"github.com/sashabaranov/go-openai"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/logs"
+	oAIClient "github.com/ravilushqa/gpt-pullrequest-updater/openai"
+)
+
+// CommentMarker is hidden in the checklist comment, so re-runs update it instead of posting it again.
+const CommentMarker = "<!-- gpt:acceptance-criteria -->"
+
+type Completer interface {
+	ChatCompletion(ctx context.Context, messages []openai.ChatCompletionMessage) (string, error)
+}
+
+// Status is how far the pull request satisfies an acceptance criterion.
+type Status string
+
+const (
+	Covered      Status = "covered"
+	Partial      Status = "partial"
+	NotAddressed Status = "not_addressed"
+)
+
+// Result is the coverage of a single acceptance criterion.
+type Result struct {
+	Criterion string
+	Status    Status
+	Files     []string
+	Note      string
+}
+
+type coverage struct {
+	Criteria []struct {
+		Index  int      `json:"index"`
+		Status Status   `json:"status"`
+		Files  []string `json:"files"`
+		Note   string   `json:"note"`
+	} `json:"criteria"`
+}
+
+// Check compares the acceptance criteria with the diff. Criteria the model does not report on are
+// marked as not addressed.
+func Check(ctx context.Context, client Completer, diff *github.CommitsComparison, criteria []string) ([]Result, error) {
+	if len(criteria) == 0 {
+		return nil, nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Acceptance criteria:\n")
+	for i, c := range criteria {
+		fmt.Fprintf(&sb, "%d. %s\n", i+1, c)
+	}
+	sb.WriteString("\nChanges:\n")
+
+	maxLength := oAIClient.PromptRoom(client, oAIClient.PromptCriteriaCoverage)
+	// patches share the prompt equally, every file keeps at least its name so it can be referenced
+	patchLength := 0
+	if len(diff.Files) > 0 && maxLength > sb.Len() {
+		patchLength = (maxLength - sb.Len()) / len(diff.Files)
+	}
+	for _, file := range diff.Files {
+		fmt.Fprintf(&sb, "File: %s\n%s\n\n", file.GetFilename(), oAIClient.Truncate(file.GetPatch(), patchLength))
+	}
+
+	logs.Println("Checking acceptance criteria")
+	completion, err := client.ChatCompletion(ctx, []openai.ChatCompletionMessage{
+		{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: oAIClient.PromptCriteriaCoverage,
+		},
+		{
+			Role:    openai.ChatMessageRoleUser,
+			Content: sb.String(),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error completing prompt: %w", err)
+	}
+
+	return parseCoverage(completion, criteria, diff)
+}
+
+func parseCoverage(completion string, criteria []string, diff *github.CommitsComparison) ([]Result, error) {
+	start := strings.Index(completion, "{")
+	end := strings.LastIndex(completion, "}")
+	if start < 0 || end < start {
+		return nil, errors.New("invalid JSON object")
+	}
+
+	var cov coverage
+	if err := json.Unmarshal([]byte(completion[start:end+1]), &cov); err != nil {
+		return nil, errors.New("invalid JSON object")
+	}
+
+	changed := map[string]bool{}
+	for _, file := range diff.Files {
+		changed[file.GetFilename()] = true
+	}
+
+	results := make([]Result, len(criteria))
+	for i, c := range criteria {
+		results[i] = Result{Criterion: c, Status: NotAddressed}
+	}
+	for _, c := range cov.Criteria {
+		if c.Index < 1 || c.Index > len(criteria) {
+			continue
+		}
+		r := &results[c.Index-1]
+		switch c.Status {
+		case Covered, Partial, NotAddressed:
+			r.Status = c.Status
+		default:
+			continue
+		}
+		r.Note = strings.TrimSpace(c.Note)
+		// drop made-up file names
+		for _, f := range c.Files {
+			if changed[f] {
+				r.Files = append(r.Files, f)
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// Checklist renders the results of a ticket as a markdown checklist. Only covered criteria are checked.
+func Checklist(ticket string, results []Result) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "### Acceptance criteria: %s\n", ticket)
+	for _, r := range results {
+		check := " "
+		if r.Status == Covered {
+			check = "x"
+		}
+		fmt.Fprintf(&sb, "- [%s] %s — %s", check, r.Criterion, r.Status.String())
+		if len(r.Files) > 0 {
+			fmt.Fprintf(&sb, " (`%s`)", strings.Join(r.Files, "`, `"))
+		}
+		if r.Note != "" {
+			fmt.Fprintf(&sb, ": %s", r.Note)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func (s Status) String() string {
+	switch s {
+	case Covered:
+		return "covered"
+	case Partial:
+		return "partially covered"
+	default:
+		return "not addressed"
+	}
+}
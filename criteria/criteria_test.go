@@ -0,0 +1,112 @@
+package criteria
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v51/github"
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockCompleter struct {
+	mock.Mock
+}
+
+func (m *MockCompleter) ChatCompletion(ctx context.Context, messages []openai.ChatCompletionMessage) (string, error) {
+	args := m.Called(ctx, messages)
+	return args.String(0), args.Error(1)
+}
+
+func TestCheck(t *testing.T) {
+	diff := &github.CommitsComparison{
+		Files: []*github.CommitFile{
+			{Filename: github.String("payments/rounding.go"), Patch: github.String("+func Round()")},
+			{Filename: github.String("payments/rounding_test.go"), Patch: github.String(strings.Repeat("+test\n", 2000))},
+		},
+	}
+	criteria := []string{"Totals are rounded half up", "Rounding is logged", "Refunds are rounded"}
+
+	testCases := []struct {
+		name       string
+		completion string
+		expected   []Result
+		expectErr  bool
+	}{
+		{
+			name: "All reported",
+			completion: `{"criteria": [
+				{"index": 1, "status": "covered", "files": ["payments/rounding.go", "payments/rounding_test.go"], "note": ""},
+				{"index": 2, "status": "partial", "files": ["payments/rounding.go", "made/up.go"], "note": "Only errors are logged."},
+				{"index": 3, "status": "not_addressed", "files": [], "note": "Refunds are unchanged."}
+			]}`,
+			expected: []Result{
+				{Criterion: "Totals are rounded half up", Status: Covered, Files: []string{"payments/rounding.go", "payments/rounding_test.go"}},
+				{Criterion: "Rounding is logged", Status: Partial, Files: []string{"payments/rounding.go"}, Note: "Only errors are logged."},
+				{Criterion: "Refunds are rounded", Status: NotAddressed, Note: "Refunds are unchanged."},
+			},
+		},
+		{
+			name:       "Missing and unknown entries",
+			completion: `Here you go: {"criteria": [{"index": 1, "status": "covered"}, {"index": 2, "status": "done"}, {"index": 7, "status": "covered"}]}`,
+			expected: []Result{
+				{Criterion: "Totals are rounded half up", Status: Covered},
+				{Criterion: "Rounding is logged", Status: NotAddressed},
+				{Criterion: "Refunds are rounded", Status: NotAddressed},
+			},
+		},
+		{
+			name:       "Invalid response",
+			completion: "Everything is covered",
+			expectErr:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCompleter := new(MockCompleter)
+			mockCompleter.On("ChatCompletion", mock.Anything, mock.MatchedBy(func(messages []openai.ChatCompletionMessage) bool {
+				content := messages[1].Content
+				return strings.Contains(content, "3. Refunds are rounded") &&
+					strings.Contains(content, "File: payments/rounding_test.go") &&
+					len(content) < 4096
+			})).Return(tc.completion, nil)
+
+			results, err := Check(context.Background(), mockCompleter, diff, criteria)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, results)
+			mockCompleter.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCheckWithoutCriteria(t *testing.T) {
+	mockCompleter := new(MockCompleter)
+
+	results, err := Check(context.Background(), mockCompleter, &github.CommitsComparison{}, nil)
+
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+	mockCompleter.AssertNotCalled(t, "ChatCompletion", mock.Anything, mock.Anything)
+}
+
+func TestChecklist(t *testing.T) {
+	results := []Result{
+		{Criterion: "Totals are rounded half up", Status: Covered, Files: []string{"a.go", "a_test.go"}},
+		{Criterion: "Rounding is logged", Status: Partial, Files: []string{"a.go"}, Note: "Only errors are logged."},
+		{Criterion: "Refunds are rounded", Status: NotAddressed},
+	}
+
+	expected := "### Acceptance criteria: PAY-1\n" +
+		"- [x] Totals are rounded half up — covered (`a.go`, `a_test.go`)\n" +
+		"- [ ] Rounding is logged — partially covered (`a.go`): Only errors are logged.\n" +
+		"- [ ] Refunds are rounded — not addressed\n"
+	assert.Equal(t, expected, Checklist("PAY-1", results))
+}
@@ -0,0 +1,51 @@
+package criteria
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	criteriaHeading = regexp.MustCompile(`(?im)^\s*(?:h[1-6]\.\s*|#+\s*|\*)?acceptance criteria[*:]*\s*$`)
+	sectionHeading  = regexp.MustCompile(`(?m)^\s*(?:h[1-6]\.|#+\s)`)
+	bullet          = regexp.MustCompile(`^\s*(?:[*#-]+|\d+[.)])\s+`)
+)
+
+// Section returns the "Acceptance criteria" section of a ticket description, up to the next heading.
+// Headings of both Jira wiki markup and markdown are recognized.
+func Section(description string) string {
+	loc := criteriaHeading.FindStringIndex(description)
+	if loc == nil {
+		return ""
+	}
+	section := description[loc[1]:]
+	if next := sectionHeading.FindStringIndex(section); next != nil {
+		section = section[:next[0]]
+	}
+	return strings.TrimSpace(section)
+}
+
+// Items splits acceptance criteria into items. List items of both Jira wiki markup and markdown are
+// recognized; without a list every non-empty line is an item.
+func Items(text string) []string {
+	var items, lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		lines = append(lines, line)
+		if bullet.MatchString(line) {
+			items = append(items, strings.TrimSpace(bullet.ReplaceAllString(line, "")))
+		}
+	}
+	if len(items) == 0 {
+		return lines
+	}
+	return items
+}
+
+// FromDescription returns the items of the "Acceptance criteria" section of a ticket description.
+func FromDescription(description string) []string {
+	return Items(Section(description))
+}
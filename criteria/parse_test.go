@@ -0,0 +1,32 @@
+package criteria
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestItems(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{name: "Wiki list", input: "* one\n** nested\n# numbered", expected: []string{"one", "nested", "numbered"}},
+		{name: "Markdown list", input: "Intro\n- one\n1. two\n2) three", expected: []string{"one", "two", "three"}},
+		{name: "Lines", input: "one\n\ntwo\n", expected: []string{"one", "two"}},
+		{name: "Empty", input: "", expected: nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Items(tc.input))
+		})
+	}
+}
+
+func TestSection(t *testing.T) {
+	assert.Equal(t, "- a\n- b", Section("Intro\n## Acceptance criteria\n- a\n- b\n## Notes\n- c"))
+	assert.Equal(t, "* a", Section("*Acceptance criteria:*\n* a"))
+	assert.Empty(t, Section("No criteria here"))
+}
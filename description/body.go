@@ -0,0 +1,68 @@
+package description
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v51/github"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/marker"
+)
+
+// InsertMode defines where the generated section is placed when the body has no markers yet.
+type InsertMode string
+
+const (
+	InsertAppend  InsertMode = "append"
+	InsertPrepend InsertMode = "prepend"
+)
+
+// UpdateBody places generated between the markers in body, replacing the previous generated section if there is one.
+// Everything outside the markers is kept as is, and so are the checkboxes the author checked or unchecked in the
+// previous generated section.
+func UpdateBody(body, generated string, mode InsertMode) string {
+	section := marker.Start + "\n" + strings.TrimSpace(generated) + "\n" + marker.End
+
+	if start, end, ok := marker.Section(body); ok {
+		section = setCheckboxes(section, checkboxStates(body[start:end]), false)
+		return body[:start] + section + body[end:]
+	}
+
+	if strings.TrimSpace(body) == "" {
+		return section
+	}
+	if mode == InsertPrepend {
+		return section + "\n\n" + body
+	}
+	return strings.TrimRight(body, "\n") + "\n\n" + section
+}
+
+// ReplaceBody is UpdateBody for a body whose author's part was filled in as the template: generated takes the place of
+// that part, so the template does not appear twice.
+func ReplaceBody(body, generated string) string {
+	if start, end, ok := marker.Section(body); ok {
+		return UpdateBody(body[start:end], generated, InsertAppend)
+	}
+	return UpdateBody("", generated, InsertAppend)
+}
+
+// DiffHash returns a hash of the file patches the description is generated from.
+func DiffHash(diff *github.CommitsComparison) string {
+	files := make([]*github.CommitFile, 0, len(diff.Files))
+	for _, file := range diff.Files {
+		if file.GetPatch() == "" {
+			continue
+		}
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].GetFilename() < files[j].GetFilename() })
+
+	h := sha256.New()
+	for _, file := range files {
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00", file.GetFilename(), file.GetStatus(), file.GetPatch())
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
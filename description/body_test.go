@@ -0,0 +1,101 @@
+package description
+
+import (
+	"testing"
+
+	"github.com/google/go-github/v51/github"
+)
+
+func TestUpdateBody(t *testing.T) {
+	testCases := []struct {
+		name     string
+		body     string
+		mode     InsertMode
+		expected string
+	}{
+		{
+			name:     "Empty body",
+			body:     "",
+			mode:     InsertAppend,
+			expected: "<!-- gpt:start -->\nnew\n<!-- gpt:end -->",
+		},
+		{
+			name:     "Append without markers",
+			body:     "- [x] tested\n",
+			mode:     InsertAppend,
+			expected: "- [x] tested\n\n<!-- gpt:start -->\nnew\n<!-- gpt:end -->",
+		},
+		{
+			name:     "Prepend without markers",
+			body:     "- [x] tested",
+			mode:     InsertPrepend,
+			expected: "<!-- gpt:start -->\nnew\n<!-- gpt:end -->\n\n- [x] tested",
+		},
+		{
+			name:     "Replace existing section",
+			body:     "before\n<!-- gpt:start -->\nold\n<!-- gpt:end -->\nafter",
+			mode:     InsertPrepend,
+			expected: "before\n<!-- gpt:start -->\nnew\n<!-- gpt:end -->\nafter",
+		},
+		{
+			name:     "Stray start marker is not treated as a section",
+			body:     "<!-- gpt:start --> notes\n<!-- gpt:start -->\nold\n<!-- gpt:end -->",
+			mode:     InsertAppend,
+			expected: "<!-- gpt:start --> notes\n<!-- gpt:start -->\nnew\n<!-- gpt:end -->",
+		},
+		{
+			name:     "Stray end marker is ignored",
+			body:     "notes <!-- gpt:end -->\n<!-- gpt:start -->\nold\n<!-- gpt:end -->",
+			mode:     InsertAppend,
+			expected: "notes <!-- gpt:end -->\n<!-- gpt:start -->\nnew\n<!-- gpt:end -->",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := UpdateBody(tc.body, "new\n", tc.mode)
+			if result != tc.expected {
+				t.Errorf("expected result %q, but got %q", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestDiffHash(t *testing.T) {
+	diff := &github.CommitsComparison{
+		Files: []*github.CommitFile{
+			{Filename: github.String("b.go"), Status: github.String("modified"), Patch: github.String("patch b")},
+			{Filename: github.String("a.go"), Status: github.String("added"), Patch: github.String("patch a")},
+			{Filename: github.String("image.png"), Status: github.String("added")},
+		},
+	}
+	reordered := &github.CommitsComparison{
+		Files: []*github.CommitFile{diff.Files[1], diff.Files[0]},
+	}
+	changed := &github.CommitsComparison{
+		Files: []*github.CommitFile{
+			diff.Files[0],
+			{Filename: github.String("a.go"), Status: github.String("added"), Patch: github.String("patch a2")},
+		},
+	}
+
+	hash := DiffHash(diff)
+	if hash != DiffHash(reordered) {
+		t.Errorf("expected the same hash for reordered files")
+	}
+	if hash == DiffHash(changed) {
+		t.Errorf("expected a different hash for changed patches")
+	}
+}
+
+func TestUpdateBodyKeepsCheckboxes(t *testing.T) {
+	body := "notes\n<!-- gpt:start -->\n## Checklist\n- [x] Tests added\n- [ ] Read the guidelines\n<!-- gpt:end -->"
+	generated := "## Checklist\n- [ ] Tests added\n- [x] Read the guidelines\n- [ ] Docs updated"
+
+	result := UpdateBody(body, generated, InsertAppend)
+
+	expected := "notes\n<!-- gpt:start -->\n## Checklist\n- [x] Tests added\n- [ ] Read the guidelines\n- [ ] Docs updated\n<!-- gpt:end -->"
+	if result != expected {
+		t.Errorf("expected result %q, but got %q", expected, result)
+	}
+}
@@ -0,0 +1,76 @@
+package description
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/v51/github"
+)
+
+var (
+	// commitNoiseRe matches commits that say nothing about the intent of the change.
+	commitNoiseRe = regexp.MustCompile(`(?i)^(fixup!|squash!|amend!|merge branch|merge pull request|merge remote-tracking branch|wip$|fix typos?$|address(ed)? (review )?comments?$|apply suggestions? from code review)`)
+	// trailerRe matches the trailers git and code hosts add, other "Key: value" lines such as notes are kept.
+	trailerRe = regexp.MustCompile(`(?i)^(signed-off-by|co-authored-by|reviewed-by|reviewed-on|acked-by|tested-by|reported-by|suggested-by|helped-by|cc|change-id): .+$`)
+)
+
+const maxCommitBodyLength = 200
+
+// CommitMessages returns a condensed list of the commit messages: one line per commit with its subject and the first
+// paragraph of its body, without duplicates and noise such as fixup or merge commits, limited to maxLength characters.
+func CommitMessages(commits []*github.RepositoryCommit, maxLength int) string {
+	var sb strings.Builder
+	seen := make(map[string]bool)
+	for _, c := range commits {
+		subject, body := splitCommitMessage(c.GetCommit().GetMessage())
+		key := strings.ToLower(subject)
+		if subject == "" || seen[key] || commitNoiseRe.MatchString(subject) {
+			continue
+		}
+		seen[key] = true
+
+		line := "- " + subject
+		if body != "" {
+			line += ": " + body
+		}
+		if sb.Len()+len(line)+1 > maxLength {
+			break
+		}
+		sb.WriteString(line)
+		sb.WriteString("\n")
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// splitCommitMessage returns the subject and the first paragraph of the body on a single line, without trailers.
+func splitCommitMessage(message string) (string, string) {
+	parts := strings.SplitN(strings.TrimSpace(message), "\n", 2)
+	subject := strings.TrimSpace(parts[0])
+	if len(parts) == 1 {
+		return subject, ""
+	}
+
+	paragraph := strings.SplitN(stripTrailers(parts[1]), "\n\n", 2)[0]
+	var lines []string
+	for _, line := range strings.Split(paragraph, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	body := strings.Join(lines, " ")
+	if len(body) > maxCommitBodyLength {
+		body = body[:maxCommitBodyLength] + "..."
+	}
+
+	return subject, body
+}
+
+// stripTrailers removes the trailers at the end of the final paragraph of body.
+func stripTrailers(body string) string {
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	end := len(lines)
+	for end > 0 && trailerRe.MatchString(strings.TrimSpace(lines[end-1])) {
+		end--
+	}
+	return strings.TrimSpace(strings.Join(lines[:end], "\n"))
+}
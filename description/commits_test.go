@@ -0,0 +1,106 @@
+package description
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v51/github"
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func commitWithMessage(message string) *github.RepositoryCommit {
+	return &github.RepositoryCommit{Commit: &github.Commit{Message: github.String(message)}}
+}
+
+func TestCommitMessages(t *testing.T) {
+	commits := []*github.RepositoryCommit{
+		commitWithMessage("Add disk cache\n\nRe-runs paid for identical\ncompletions.\n\nSecond paragraph.\n\nSigned-off-by: Alice <alice@example.com>"),
+		commitWithMessage("fixup! Add disk cache"),
+		commitWithMessage("Merge branch 'main' into cache"),
+		commitWithMessage("fix typo"),
+		commitWithMessage("add disk cache"),
+		commitWithMessage("Evict old entries\n\nCo-authored-by: Bob <bob@example.com>"),
+	}
+
+	assert.Equal(t, "- Add disk cache: Re-runs paid for identical completions.\n- Evict old entries", CommitMessages(commits, 1000))
+	assert.Equal(t, "- Add disk cache: Re-runs paid for identical completions.", CommitMessages(commits, 70))
+	assert.Empty(t, CommitMessages(nil, 1000))
+}
+
+func TestSplitCommitMessage(t *testing.T) {
+	testCases := []struct {
+		name     string
+		message  string
+		expected string
+	}{
+		{
+			name:     "Note is kept",
+			message:  "Round totals\n\nNote: amounts are rounded half up.",
+			expected: "Note: amounts are rounded half up.",
+		},
+		{
+			name:     "Trailers after the body",
+			message:  "Round totals\n\nAmounts were truncated.\nSigned-off-by: Alice <alice@example.com>\nChange-Id: I1234",
+			expected: "Amounts were truncated.",
+		},
+		{
+			name:     "Only trailers",
+			message:  "Round totals\n\nReviewed-by: Bob <bob@example.com>",
+			expected: "",
+		},
+		{
+			name:     "Trailer key outside of the final paragraph",
+			message:  "Round totals\n\nCc: the payments team owns the rounding.\n\nSigned-off-by: Alice <alice@example.com>",
+			expected: "Cc: the payments team owns the rounding.",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			subject, body := splitCommitMessage(tc.message)
+			assert.Equal(t, "Round totals", subject)
+			assert.Equal(t, tc.expected, body)
+		})
+	}
+}
+
+func TestGenerateCompletionIncludesBackground(t *testing.T) {
+	testCases := []struct {
+		name  string
+		patch string
+		calls int
+	}{
+		{name: "Single shot", patch: "small patch", calls: 1},
+		{name: "Per file", patch: strings.Repeat("large patch ", 500), calls: 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var prompts []string
+			mockCompleter := new(MockCompleter)
+			mockCompleter.On("ChatCompletion", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				var sb strings.Builder
+				for _, m := range args.Get(1).([]openai.ChatCompletionMessage) {
+					sb.WriteString(m.Content)
+				}
+				prompts = append(prompts, sb.String())
+			}).Return("completion", nil)
+
+			diff := &github.CommitsComparison{
+				Files:   []*github.CommitFile{{Filename: github.String("main.go"), Patch: github.String(tc.patch)}},
+				Commits: []*github.RepositoryCommit{commitWithMessage("Explain the intent")},
+			}
+
+			_, err := GenerateCompletion(context.Background(), mockCompleter, diff, &github.PullRequest{}, Options{Tickets: "PAY-1: Round totals"})
+			require.NoError(t, err)
+
+			require.Len(t, prompts, tc.calls)
+			assert.Contains(t, prompts[len(prompts)-1], "- Explain the intent")
+			assert.Contains(t, prompts[len(prompts)-1], ticketsHeader+"PAY-1: Round totals")
+		})
+	}
+}
@@ -2,26 +2,101 @@ package description
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/google/go-github/v51/github"
 	"github.com/sashabaranov/go-openai"
 
+	"github.com/ravilushqa/gpt-pullrequest-updater/logs"
+	"github.com/ravilushqa/gpt-pullrequest-updater/marker"
 	oAIClient "github.com/ravilushqa/gpt-pullrequest-updater/openai"
+	"github.com/ravilushqa/gpt-pullrequest-updater/usage"
 )
 
-func GenerateCompletion(ctx context.Context, client *oAIClient.Client, diff *github.CommitsComparison, pr *github.PullRequest) (string, error) {
+const (
+	commitsHeader = "Commit messages, use them to explain the intent of the changes:\n"
+	ticketsHeader = "Linked tickets, explain how the changes address them:\n"
+)
+
+type Completer interface {
+	ChatCompletion(ctx context.Context, messages []openai.ChatCompletionMessage) (string, error)
+}
+
+// Options are optional inputs of GenerateCompletion.
+type Options struct {
+	// Template is the pull request template of the repository. When set, it is filled in instead of
+	// the default Summary/Changes/Impact structure.
+	Template string
+	// Tickets describes the tickets linked to the pull request, e.g. their summary, description and
+	// acceptance criteria.
+	Tickets string
+}
+
+// FileSummary is the description of the changes to a single file.
+type FileSummary struct {
+	Filename string
+	Summary  string
+}
+
+// Result is the generated description together with the per-file summaries it was built from.
+// Files is empty when the description was generated in a single call.
+type Result struct {
+	Description string
+	Files       []FileSummary
+}
+
+func GenerateCompletion(ctx context.Context, client Completer, diff *github.CommitsComparison, pr *github.PullRequest, opts Options) (string, error) {
+	result, err := Generate(ctx, client, diff, pr, opts)
+	if err != nil {
+		return "", err
+	}
+	return result.Description, nil
+}
+
+// Generate generates the description like GenerateCompletion and also returns the per-file summaries.
+func Generate(ctx context.Context, client Completer, diff *github.CommitsComparison, pr *github.PullRequest, opts Options) (*Result, error) {
 	sumDiffs := calculateSumDiffs(diff)
 
-	var completion string
+	prompt := oAIClient.PromptDescribeChanges
+	if opts.Template != "" {
+		prompt = templatePrompt(opts.Template)
+	}
+
+	background := changeBackground(diff, opts, oAIClient.MaxPromptLength(client))
+
+	result := &Result{}
 	var err error
-	if sumDiffs < 4000 {
-		completion, err = genCompletionOnce(ctx, client, diff)
+	// the whole diff goes into a single prompt when it fits, otherwise every file is summarized first
+	if sumDiffs+len(background) < oAIClient.PromptRoom(client, prompt) {
+		result.Description, err = genCompletionOnce(ctx, client, diff, prompt, background)
 	} else {
-		completion, err = genCompletionPerFile(ctx, client, diff, pr)
+		result.Description, result.Files, err = genCompletionPerFile(ctx, client, diff, pr, opts, background)
 	}
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Template != "" {
+		result.Description = keepCheckboxes(opts.Template, result.Description)
+	}
+
+	return result, nil
+}
 
-	return completion, err
+// changeBackground returns what explains the changes beyond the diff: the commit messages and the linked
+// tickets, each within a quarter of the prompt.
+func changeBackground(diff *github.CommitsComparison, opts Options, maxLength int) string {
+	var sections []string
+	if commits := CommitMessages(diff.Commits, maxLength/4); commits != "" {
+		sections = append(sections, commitsHeader+commits)
+	}
+	if tickets := strings.TrimSpace(opts.Tickets); tickets != "" {
+		tickets = oAIClient.Truncate(tickets, maxLength/4)
+		sections = append(sections, ticketsHeader+tickets)
+	}
+	return strings.Join(sections, "\n\n")
 }
 
 func calculateSumDiffs(diff *github.CommitsComparison) int {
@@ -35,13 +110,19 @@ func calculateSumDiffs(diff *github.CommitsComparison) int {
 	return sumDiffs
 }
 
-func genCompletionOnce(ctx context.Context, client *oAIClient.Client, diff *github.CommitsComparison) (string, error) {
-	fmt.Println("Generating completion once")
-	messages := make([]openai.ChatCompletionMessage, 0, len(diff.Files))
+func genCompletionOnce(ctx context.Context, client Completer, diff *github.CommitsComparison, prompt, background string) (string, error) {
+	logs.Println("Generating completion once")
+	messages := make([]openai.ChatCompletionMessage, 0, len(diff.Files)+2)
 	messages = append(messages, openai.ChatCompletionMessage{
 		Role:    openai.ChatMessageRoleUser,
-		Content: oAIClient.PromptDescribeChanges,
+		Content: prompt,
 	})
+	if background != "" {
+		messages = append(messages, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleUser,
+			Content: background,
+		})
+	}
 	for _, file := range diff.Files {
 		if file.Patch == nil {
 			continue
@@ -53,33 +134,57 @@ func genCompletionOnce(ctx context.Context, client *oAIClient.Client, diff *gith
 		})
 	}
 
-	fmt.Println("Sending prompt to OpenAI")
+	logs.Println("Sending prompt to OpenAI")
 	completion, err := client.ChatCompletion(ctx, messages)
 	if err != nil {
 		return "", fmt.Errorf("error completing prompt: %w", err)
 	}
 
-	fmt.Println("Completion:", completion)
+	logs.Println("Completion:", completion)
 
 	return completion, nil
 }
 
-func genCompletionPerFile(ctx context.Context, client *oAIClient.Client, diff *github.CommitsComparison, pr *github.PullRequest) (string, error) {
-	fmt.Println("Generating completion per file")
-	OverallDescribeCompletion := fmt.Sprintf("Pull request title: %s, body: %s\n\n", pr.GetTitle(), pr.GetBody())
+func genCompletionPerFile(ctx context.Context, client Completer, diff *github.CommitsComparison, pr *github.PullRequest, opts Options, background string) (string, []FileSummary, error) {
+	logs.Println("Generating completion per file")
+	overallPrompt := oAIClient.PromptDescribeOverall
+	if opts.Template != "" {
+		overallPrompt = templatePrompt(opts.Template)
+	}
+	room := oAIClient.PromptRoom(client, overallPrompt)
 
+	// the body is written by the author and may be of any length, it gets a quarter of the final prompt
+	body := oAIClient.Truncate(marker.StripGenerated(pr.GetBody()), room/4)
+	OverallDescribeCompletion := fmt.Sprintf("Pull request title: %s, body: %s\n\n", pr.GetTitle(), body)
+	if background != "" {
+		OverallDescribeCompletion += background + "\n\n"
+	}
+
+	maxLength := oAIClient.PromptRoom(client, oAIClient.PromptDescribeChanges)
+	budget := usage.BudgetOf(client)
+	var fileSummaries []summary
+	var summaries []string
+	var files []FileSummary
 	for i, file := range diff.Files {
 		patch := file.GetPatch()
 		if patch == "" {
 			continue
 		}
-		maxLength := 4096 - len(oAIClient.PromptDescribeChanges)
-		if len(*file.Patch) > maxLength {
-			fmt.Println("Patch is too long, truncating")
-			patch = fmt.Sprintf("%s...", patch[:maxLength])
+
+		if budget.NearlyExhausted() && usage.IsLowPriority(file.GetFilename()) {
+			budget.Skip(file.GetFilename(), "low priority file, budget nearly used up")
+			fileSummaries = append(fileSummaries, summary{
+				path: file.GetFilename(),
+				text: fmt.Sprintf("not summarized (%d additions, %d deletions)", file.GetAdditions(), file.GetDeletions()),
+			})
+			continue
+		}
+		if len(patch) > maxLength {
+			logs.Println("Patch is too long, truncating")
+			patch = oAIClient.Truncate(patch, maxLength)
 		}
 
-		fmt.Printf("processing file: %s %d/%d\n", file.GetFilename(), i+1, len(diff.Files))
+		logs.Printf("processing file: %s %d/%d\n", file.GetFilename(), i+1, len(diff.Files))
 		completion, err := client.ChatCompletion(ctx, []openai.ChatCompletionMessage{
 			{
 				Role:    openai.ChatMessageRoleSystem,
@@ -90,30 +195,62 @@ func genCompletionPerFile(ctx context.Context, client *oAIClient.Client, diff *g
 				Content: patch,
 			},
 		})
+		if errors.Is(err, usage.ErrBudgetExceeded) {
+			budget.Skip(file.GetFilename(), err.Error())
+			continue
+		}
 		if err != nil {
-			return "", fmt.Errorf("error getting review: %w", err)
+			return "", nil, fmt.Errorf("error getting review: %w", err)
 		}
-		fmt.Println("Completion:", completion)
+		logs.Println("Completion:", completion)
 
-		OverallDescribeCompletion += fmt.Sprintf("File: %s \nDescription: %s \n\n", file.GetFilename(), completion)
+		fileSummaries = append(fileSummaries, summary{path: file.GetFilename(), text: completion})
+		files = append(files, FileSummary{Filename: file.GetFilename(), Summary: completion})
+		summaries = append(summaries, fmt.Sprintf("- `%s`: %s", file.GetFilename(), completion))
 	}
 
-	fmt.Println("Summarizing overall completion")
+	// merge the file summaries by directory when they do not fit in the final prompt. Group summaries need
+	// some room to be useful, fitSummaries enforces the actual limit afterwards.
+	limit := room - len(OverallDescribeCompletion)
+	reduceLimit := limit
+	if reduceLimit < 2*groupSummaryLength {
+		reduceLimit = 2 * groupSummaryLength
+	}
+	reduced, err := reduceSummaries(ctx, client, fileSummaries, reduceLimit)
+	if errors.Is(err, usage.ErrBudgetExceeded) && len(summaries) > 0 {
+		budget.Skip("directory summaries", err.Error())
+		return "## Changes\n" + strings.Join(summaries, "\n"), files, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	OverallDescribeCompletion += fitSummaries(reduced, limit)
+	if len(OverallDescribeCompletion) > room {
+		logs.Println("Prompt is too long, truncating")
+		OverallDescribeCompletion = oAIClient.Truncate(OverallDescribeCompletion, room)
+	}
+
+	logs.Println("Summarizing overall completion")
 	overallCompletion, err := client.ChatCompletion(ctx, []openai.ChatCompletionMessage{
 		{
 			Role:    openai.ChatMessageRoleSystem,
-			Content: oAIClient.PromptDescribeOverall,
+			Content: overallPrompt,
 		},
 		{
 			Role:    openai.ChatMessageRoleUser,
 			Content: OverallDescribeCompletion,
 		},
 	})
+	if errors.Is(err, usage.ErrBudgetExceeded) && len(summaries) > 0 {
+		// keep the per-file work instead of failing the whole run
+		budget.Skip("overall summary", err.Error())
+		return "## Changes\n" + strings.Join(summaries, "\n"), files, nil
+	}
 	if err != nil {
-		return "", fmt.Errorf("error completing final prompt: %w", err)
+		return "", nil, fmt.Errorf("error completing final prompt: %w", err)
 	}
 
-	fmt.Println("Overall completion:", overallCompletion)
+	logs.Println("Overall completion:", overallCompletion)
 
-	return overallCompletion, nil
+	return overallCompletion, files, nil
 }
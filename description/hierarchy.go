@@ -0,0 +1,208 @@
+package description
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/logs"
+	oAIClient "github.com/ravilushqa/gpt-pullrequest-updater/openai"
+)
+
+const (
+	// groupSummaryLength is the expected length of a group summary in characters. It is used to choose
+	// how deep files are grouped so that the group summaries fit in the final prompt.
+	groupSummaryLength = 500
+	// maxReduceRounds stops the reduction when the summaries do not shrink, e.g. with a tiny prompt limit.
+	// fitSummaries then drops what still does not fit.
+	maxReduceRounds = 5
+)
+
+// summary describes the changes to a file or, after reduction, to a directory.
+type summary struct {
+	path  string
+	isDir bool
+	text  string
+}
+
+func (s summary) String() string {
+	if s.isDir {
+		return fmt.Sprintf("Directory: %s \nDescription: %s \n\n", s.path, s.text)
+	}
+	return fmt.Sprintf("File: %s \nDescription: %s \n\n", s.path, s.text)
+}
+
+type summaryGroup struct {
+	dir   string
+	items []summary
+}
+
+// reduceSummaries merges the summaries until they fit in limit characters. Files are grouped by
+// directory, each group is summarized, and the group summaries are merged again at a shallower level
+// until they fit. The grouping depth is chosen from limit, so larger budgets keep more detail.
+func reduceSummaries(ctx context.Context, client Completer, summaries []summary, limit int) ([]summary, error) {
+	level := maxDirLevel(summaries)
+	for round := 0; round < maxReduceRounds && totalLength(summaries) > limit && len(summaries) > 1; round++ {
+		level = groupingLevel(summaries, limit, level)
+		logs.Printf("Summaries do not fit in the prompt, merging them by directory at level %d\n", level)
+
+		var reduced []summary
+		for _, group := range groupByDir(summaries, level) {
+			for _, batch := range batches(group.items, limit) {
+				// a lone summary only needs merging when nothing else can shrink it
+				if len(batch) == 1 && level > 0 && len(batch[0].String()) <= limit {
+					reduced = append(reduced, batch[0])
+					continue
+				}
+				text, err := summarizeGroup(ctx, client, group.dir, batch, limit)
+				if err != nil {
+					return nil, err
+				}
+				reduced = append(reduced, summary{path: group.dir, isDir: true, text: text})
+			}
+		}
+		summaries = reduced
+		if level > 0 {
+			level--
+		}
+	}
+
+	return summaries, nil
+}
+
+// fitSummaries writes the summaries that fit in limit characters, in order. When some do not fit, they are
+// replaced by a note saying how many were left out, so the final prompt stays within the limit even when the
+// reduction could not shrink the summaries enough.
+func fitSummaries(summaries []summary, limit int) string {
+	var sb strings.Builder
+	for i, s := range summaries {
+		text := s.String()
+		if i == len(summaries)-1 && sb.Len()+len(text) <= limit {
+			sb.WriteString(text)
+			break
+		}
+		note := fmt.Sprintf("%d more changed files and directories are not described.\n", len(summaries)-i)
+		if sb.Len()+len(text)+len(note) > limit {
+			if sb.Len()+len(note) <= limit {
+				sb.WriteString(note)
+			}
+			break
+		}
+		sb.WriteString(text)
+	}
+	return sb.String()
+}
+
+func summarizeGroup(ctx context.Context, client Completer, dir string, items []summary, limit int) (string, error) {
+	var sb strings.Builder
+	for _, item := range items {
+		sb.WriteString(item.String())
+	}
+	content := oAIClient.Truncate(sb.String(), limit)
+
+	logs.Printf("summarizing directory: %s (%d summaries)\n", dir, len(items))
+	completion, err := client.ChatCompletion(ctx, []openai.ChatCompletionMessage{
+		{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: oAIClient.PromptDescribeGroup,
+		},
+		{
+			Role:    openai.ChatMessageRoleUser,
+			Content: content,
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("error summarizing directory %s: %w", dir, err)
+	}
+
+	return completion, nil
+}
+
+// groupingLevel returns the deepest directory level, not deeper than maxLevel, whose group summaries
+// are expected to fit in limit.
+func groupingLevel(summaries []summary, limit, maxLevel int) int {
+	for level := maxLevel; level > 0; level-- {
+		if len(groupByDir(summaries, level))*groupSummaryLength <= limit {
+			return level
+		}
+	}
+	return 0
+}
+
+// groupByDir groups summaries by the first level components of their directory, keeping the order
+// in which directories first appear.
+func groupByDir(summaries []summary, level int) []summaryGroup {
+	var groups []summaryGroup
+	index := map[string]int{}
+	for _, s := range summaries {
+		dir := dirPrefix(s, level)
+		i, ok := index[dir]
+		if !ok {
+			i = len(groups)
+			index[dir] = i
+			groups = append(groups, summaryGroup{dir: dir})
+		}
+		groups[i].items = append(groups[i].items, s)
+	}
+	return groups
+}
+
+// batches splits summaries into consecutive batches that fit in limit characters.
+func batches(summaries []summary, limit int) [][]summary {
+	var result [][]summary
+	var current []summary
+	length := 0
+	for _, s := range summaries {
+		l := len(s.String())
+		if len(current) > 0 && length+l > limit {
+			result = append(result, current)
+			current, length = nil, 0
+		}
+		current = append(current, s)
+		length += l
+	}
+	if len(current) > 0 {
+		result = append(result, current)
+	}
+	return result
+}
+
+func dirPrefix(s summary, level int) string {
+	dir := s.path
+	if !s.isDir {
+		dir = path.Dir(s.path)
+	}
+	if level == 0 || dir == "." {
+		return "."
+	}
+	parts := strings.Split(dir, "/")
+	if len(parts) > level {
+		parts = parts[:level]
+	}
+	return strings.Join(parts, "/")
+}
+
+func maxDirLevel(summaries []summary) int {
+	level := 0
+	for _, s := range summaries {
+		dir := dirPrefix(s, 1<<10)
+		if dir == "." {
+			continue
+		}
+		if n := strings.Count(dir, "/") + 1; n > level {
+			level = n
+		}
+	}
+	return level
+}
+
+func totalLength(summaries []summary) int {
+	length := 0
+	for _, s := range summaries {
+		length += len(s.String())
+	}
+	return length
+}
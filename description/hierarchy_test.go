@@ -0,0 +1,158 @@
+package description
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v51/github"
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	oAIClient "github.com/ravilushqa/gpt-pullrequest-updater/openai"
+)
+
+func TestGroupByDir(t *testing.T) {
+	summaries := []summary{
+		{path: "cmd/review/main.go"},
+		{path: "README.md"},
+		{path: "cmd/description/main.go"},
+		{path: "usage/budget.go"},
+		{path: "cmd", isDir: true},
+	}
+
+	testCases := []struct {
+		name  string
+		level int
+		want  map[string]int
+	}{
+		{name: "Root", level: 0, want: map[string]int{".": 5}},
+		{name: "Top level", level: 1, want: map[string]int{"cmd": 3, ".": 1, "usage": 1}},
+		{name: "Packages", level: 2, want: map[string]int{"cmd/review": 1, ".": 1, "cmd/description": 1, "usage": 1, "cmd": 1}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := map[string]int{}
+			for _, g := range groupByDir(summaries, tc.level) {
+				got[g.dir] = len(g.items)
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	assert.Equal(t, 2, maxDirLevel(summaries))
+}
+
+func TestGroupingLevel(t *testing.T) {
+	var summaries []summary
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			summaries = append(summaries, summary{path: fmt.Sprintf("pkg%d/sub%d/file.go", i, j)})
+		}
+	}
+
+	assert.Equal(t, 2, groupingLevel(summaries, 16*groupSummaryLength, 2))
+	assert.Equal(t, 1, groupingLevel(summaries, 4*groupSummaryLength, 2))
+	assert.Equal(t, 0, groupingLevel(summaries, groupSummaryLength, 2))
+	assert.Equal(t, 1, groupingLevel(summaries, 16*groupSummaryLength, 1))
+}
+
+func TestReduceSummaries(t *testing.T) {
+	var summaries []summary
+	for i := 0; i < 50; i++ {
+		summaries = append(summaries, summary{
+			path: fmt.Sprintf("pkg%d/sub%d/file%d.go", i%5, i%10, i),
+			text: strings.Repeat("change ", 20),
+		})
+	}
+
+	testCases := []struct {
+		name  string
+		limit int
+	}{
+		{name: "Fits", limit: 100000},
+		{name: "One level", limit: 4000},
+		{name: "Several levels", limit: 1000},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCompleter := new(MockCompleter)
+			mockCompleter.On("ChatCompletion", mock.Anything, mock.Anything).Return("merged changes", nil)
+
+			reduced, err := reduceSummaries(context.Background(), mockCompleter, summaries, tc.limit)
+			require.NoError(t, err)
+			assert.LessOrEqual(t, totalLength(reduced), tc.limit)
+			if tc.limit >= totalLength(summaries) {
+				assert.Equal(t, summaries, reduced)
+				mockCompleter.AssertNotCalled(t, "ChatCompletion", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestReduceSummariesError(t *testing.T) {
+	summaries := []summary{
+		{path: "a/file.go", text: strings.Repeat("change ", 100)},
+		{path: "b/file.go", text: strings.Repeat("change ", 100)},
+	}
+
+	mockCompleter := new(MockCompleter)
+	mockCompleter.On("ChatCompletion", mock.Anything, mock.Anything).Return("", assert.AnError)
+
+	_, err := reduceSummaries(context.Background(), mockCompleter, summaries, 1000)
+	assert.ErrorIs(t, err, assert.AnError)
+}
+
+func TestFitSummaries(t *testing.T) {
+	summaries := []summary{
+		{path: "a", isDir: true, text: strings.Repeat("a", 100)},
+		{path: "b", isDir: true, text: strings.Repeat("b", 100)},
+		{path: "c", isDir: true, text: strings.Repeat("c", 100)},
+	}
+
+	assert.Equal(t, summaries[0].String()+summaries[1].String()+summaries[2].String(), fitSummaries(summaries, totalLength(summaries)))
+
+	fitted := fitSummaries(summaries, 250)
+	assert.LessOrEqual(t, len(fitted), 250)
+	assert.Equal(t, summaries[0].String()+"2 more changed files and directories are not described.\n", fitted)
+
+	assert.Empty(t, fitSummaries(summaries, 10))
+}
+
+type MockLimitedCompleter struct {
+	MockCompleter
+	maxPromptLength int
+}
+
+func (m *MockLimitedCompleter) MaxPromptLength() int {
+	return m.maxPromptLength
+}
+
+func TestGeneratePromptWithinLimitWhenSummariesDoNotShrink(t *testing.T) {
+	var files []*github.CommitFile
+	for i := 0; i < 60; i++ {
+		files = append(files, &github.CommitFile{
+			Filename: github.String(fmt.Sprintf("pkg%d/sub%d/file%d.go", i%6, i%12, i)),
+			Patch:    github.String(strings.Repeat("+line\n", 200)),
+		})
+	}
+	pr := &github.PullRequest{Title: github.String("Large change"), Body: github.String(strings.Repeat("Written by the author. ", 1000))}
+
+	// every summary, including the directory summaries, is longer than the whole prompt
+	mockCompleter := &MockLimitedCompleter{maxPromptLength: 4096}
+	mockCompleter.On("ChatCompletion", mock.Anything, mock.Anything).Return(strings.Repeat("changes ", 1000), nil)
+
+	_, err := GenerateCompletion(context.Background(), mockCompleter, &github.CommitsComparison{Files: files}, pr, Options{})
+	require.NoError(t, err)
+
+	calls := mockCompleter.Calls
+	final := calls[len(calls)-1].Arguments.Get(1).([]openai.ChatCompletionMessage)
+	require.Equal(t, oAIClient.PromptDescribeOverall, final[0].Content)
+	assert.LessOrEqual(t, len(final[0].Content)+len(final[1].Content), 4096)
+	assert.Contains(t, final[1].Content, "Large change")
+}
@@ -0,0 +1,98 @@
+package description
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/marker"
+	oAIClient "github.com/ravilushqa/gpt-pullrequest-updater/openai"
+)
+
+var (
+	checkboxRe = regexp.MustCompile(`^(\s*[-*]\s+)\[([ xX])\](.*)$`)
+	headingRe  = regexp.MustCompile(`^#{1,6}\s+\S`)
+)
+
+func templatePrompt(template string) string {
+	return fmt.Sprintf("%s\n\nTemplate:\n%s", oAIClient.PromptDescribeTemplate, template)
+}
+
+// keepCheckboxes restores the state of every checkbox from the template, so only the author checks them.
+func keepCheckboxes(template, completion string) string {
+	return setCheckboxes(completion, checkboxStates(template), true)
+}
+
+// HasTemplate reports whether body already contains the template, e.g. because GitHub pre-filled the body with
+// it. The author may have filled in the template, so the headings of the template are compared, or its whole
+// text when it has none.
+func HasTemplate(body, template string) bool {
+	if strings.TrimSpace(template) == "" {
+		return false
+	}
+
+	var headings []string
+	for _, line := range strings.Split(template, "\n") {
+		if line = strings.TrimSpace(line); headingRe.MatchString(line) {
+			headings = append(headings, line)
+		}
+	}
+	if len(headings) == 0 {
+		return strings.Contains(strings.Join(strings.Fields(body), " "), strings.Join(strings.Fields(template), " "))
+	}
+
+	lines := make(map[string]bool)
+	for _, line := range strings.Split(body, "\n") {
+		lines[strings.TrimSpace(line)] = true
+	}
+	for _, heading := range headings {
+		if !lines[heading] {
+			return false
+		}
+	}
+	return true
+}
+
+// PrefilledTemplate returns the author's part of body when it already contains the template, e.g. because GitHub
+// pre-filled the body with it. That part is filled in instead of the template, so the sections and checkboxes the author
+// already filled in are kept.
+func PrefilledTemplate(body, template string) (string, bool) {
+	author := strings.TrimSpace(marker.StripGenerated(body))
+	if !HasTemplate(author, template) {
+		return "", false
+	}
+	return author, true
+}
+
+// checkboxStates returns the state of every checkbox of text by its label.
+func checkboxStates(text string) map[string]string {
+	states := make(map[string]string)
+	for _, line := range strings.Split(text, "\n") {
+		if m := checkboxRe.FindStringSubmatch(line); m != nil {
+			states[strings.TrimSpace(m[3])] = m[2]
+		}
+	}
+	return states
+}
+
+// setCheckboxes sets the checkboxes of text to their state in states. Checkboxes missing from states are
+// unchecked when uncheckOthers is set and left as they are otherwise.
+func setCheckboxes(text string, states map[string]string, uncheckOthers bool) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		m := checkboxRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		state, ok := states[strings.TrimSpace(m[3])]
+		if !ok {
+			if !uncheckOthers {
+				continue
+			}
+			state = " "
+		}
+		lines[i] = fmt.Sprintf("%s[%s]%s", m[1], state, m[3])
+	}
+
+	return strings.Join(lines, "\n")
+}
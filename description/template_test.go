@@ -0,0 +1,100 @@
+package description
+
+import "testing"
+
+func TestKeepCheckboxes(t *testing.T) {
+	template := "## Checklist\n- [ ] Tests added\n- [x] Read the guidelines\n"
+	testCases := []struct {
+		name       string
+		completion string
+		expected   string
+	}{
+		{
+			name:       "Checked by the model",
+			completion: "## Checklist\n- [x] Tests added\n- [x] Read the guidelines\n",
+			expected:   "## Checklist\n- [ ] Tests added\n- [x] Read the guidelines\n",
+		},
+		{
+			name:       "Unchecked by the model",
+			completion: "## Checklist\n- [ ] Tests added\n- [ ] Read the guidelines\n",
+			expected:   "## Checklist\n- [ ] Tests added\n- [x] Read the guidelines\n",
+		},
+		{
+			name:       "Checkbox added by the model",
+			completion: "## Checklist\n  * [X] Docs updated",
+			expected:   "## Checklist\n  * [ ] Docs updated",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := keepCheckboxes(template, tc.completion)
+			if result != tc.expected {
+				t.Errorf("expected result %q, but got %q", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestHasTemplate(t *testing.T) {
+	template := "## What\n\n## Why\n\n- [ ] Tests added\n"
+	testCases := []struct {
+		name     string
+		body     string
+		template string
+		expected bool
+	}{
+		{name: "Pre-filled", body: template, template: template, expected: true},
+		{name: "Filled in by the author", body: "## What\nRounding\n\n## Why\nTotals were off\n\n- [x] Tests added", template: template, expected: true},
+		{name: "Written without the template", body: "Fixes rounding", template: template},
+		{name: "Only some headings", body: "## What\nRounding", template: template},
+		{name: "Template without headings", body: "Intro\n\nPlease   describe the change.\nThanks", template: "Please describe\nthe change.", expected: true},
+		{name: "Empty template", body: "Fixes rounding"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := HasTemplate(tc.body, tc.template); result != tc.expected {
+				t.Errorf("expected %v, but got %v", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestPrefilledTemplate(t *testing.T) {
+	template := "## What\n\n## Why\n\n- [ ] Tests added\n"
+	testCases := []struct {
+		name     string
+		body     string
+		expected string
+		ok       bool
+	}{
+		{name: "Untouched pre-filled template", body: template, expected: "## What\n\n## Why\n\n- [ ] Tests added", ok: true},
+		{name: "Filled in by the author", body: "## What\nRounding\n\n## Why\n\n- [x] Tests added\n", expected: "## What\nRounding\n\n## Why\n\n- [x] Tests added", ok: true},
+		{name: "Filled in before", body: template + "\n<!-- gpt:start -->\n## What\nRounding\n<!-- gpt:end -->", expected: "## What\n\n## Why\n\n- [ ] Tests added", ok: true},
+		{name: "Written without the template", body: "Fixes rounding"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, ok := PrefilledTemplate(tc.body, template)
+			if result != tc.expected || ok != tc.ok {
+				t.Errorf("expected %q, %v, but got %q, %v", tc.expected, tc.ok, result, ok)
+			}
+		})
+	}
+}
+
+func TestFillPrefilledTemplate(t *testing.T) {
+	template := "## What\n\n## Why\n\n- [ ] Tests added\n- [x] Read the guidelines\n"
+	author, ok := PrefilledTemplate(template, template)
+	if !ok {
+		t.Fatal("expected the untouched template to be recognized")
+	}
+	completion := keepCheckboxes(author, "## What\nRound totals half up.\n\n## Why\nTotals were off.\n\n- [x] Tests added\n- [ ] Read the guidelines")
+
+	expected := "<!-- gpt:start -->\n## What\nRound totals half up.\n\n## Why\nTotals were off.\n\n- [ ] Tests added\n- [x] Read the guidelines\n<!-- gpt:end -->"
+	if result := ReplaceBody(template, completion); result != expected {
+		t.Errorf("expected %q, but got %q", expected, result)
+	}
+}
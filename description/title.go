@@ -0,0 +1,134 @@
+package description
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/v51/github"
+	"github.com/sashabaranov/go-openai"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/logs"
+	oAIClient "github.com/ravilushqa/gpt-pullrequest-updater/openai"
+)
+
+// ConventionalTypes are the commit types allowed in titles.
+var ConventionalTypes = []string{"feat", "fix", "docs", "style", "refactor", "perf", "test", "build", "ci", "chore", "revert"}
+
+const maxTitleLength = 72
+
+var (
+	titleRe = regexp.MustCompile(`^([a-z]+)(\(([\w\-./]+)\))?(!)?: (.+)$`)
+
+	// scopeContainers are directories whose children name the component better than the directory itself.
+	scopeContainers = map[string]bool{"cmd": true, "pkg": true, "internal": true, "src": true, "lib": true, "apps": true, "packages": true}
+)
+
+// ValidateTitle checks that title follows the Conventional Commits format.
+func ValidateTitle(title string) error {
+	m := titleRe.FindStringSubmatch(title)
+	if m == nil {
+		return errors.New(`title must have the format "type(scope): summary"`)
+	}
+
+	var problems []string
+	if !isConventionalType(m[1]) {
+		problems = append(problems, fmt.Sprintf("unknown type %q, allowed types: %s", m[1], strings.Join(ConventionalTypes, ", ")))
+	}
+	summary := m[5]
+	if strings.HasSuffix(summary, ".") {
+		problems = append(problems, "summary must not end with a period")
+	}
+	if first := summary[:1]; first != strings.ToLower(first) {
+		problems = append(problems, "summary must start with a lowercase letter")
+	}
+	if len(title) > maxTitleLength {
+		problems = append(problems, fmt.Sprintf("title must be at most %d characters", maxTitleLength))
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// InferScope returns the directory all changed files have in common, or an empty string if they have none.
+func InferScope(diff *github.CommitsComparison) string {
+	scope := ""
+	for _, file := range diff.Files {
+		parts := strings.Split(path.Dir(file.GetFilename()), "/")
+		if parts[0] == "." {
+			return ""
+		}
+		s := parts[0]
+		if scopeContainers[s] && len(parts) > 1 {
+			s = parts[1]
+		}
+		if scope != "" && scope != s {
+			return ""
+		}
+		scope = s
+	}
+	return scope
+}
+
+// GenerateTitle generates a Conventional Commits title from the changed files and the description.
+func GenerateTitle(ctx context.Context, client Completer, diff *github.CommitsComparison, pr *github.PullRequest, description string) (string, error) {
+	scope := InferScope(diff)
+	if scope == "" {
+		scope = "none"
+	}
+
+	files := make([]string, 0, len(diff.Files))
+	for _, file := range diff.Files {
+		files = append(files, fmt.Sprintf("- %s (%s)", file.GetFilename(), file.GetStatus()))
+	}
+
+	content := fmt.Sprintf("Scope: %s\nCurrent title: %s\nChanged files:\n%s\n\nDescription:\n%s",
+		scope, pr.GetTitle(), strings.Join(files, "\n"), description)
+	content = oAIClient.Truncate(content, oAIClient.PromptRoom(client, oAIClient.PromptTitle))
+
+	logs.Println("Generating title")
+	completion, err := client.ChatCompletion(ctx, []openai.ChatCompletionMessage{
+		{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: oAIClient.PromptTitle,
+		},
+		{
+			Role:    openai.ChatMessageRoleUser,
+			Content: content,
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("error completing title prompt: %w", err)
+	}
+
+	title := cleanTitle(completion)
+	if err := ValidateTitle(title); err != nil {
+		return "", fmt.Errorf("generated title %q is invalid: %w", title, err)
+	}
+
+	return title, nil
+}
+
+// cleanTitle keeps the first line of a completion without quotes and a trailing period.
+func cleanTitle(completion string) string {
+	title := strings.TrimSpace(completion)
+	if i := strings.IndexByte(title, '\n'); i >= 0 {
+		title = title[:i]
+	}
+	title = strings.Trim(title, "\"'` ")
+	return strings.TrimSuffix(title, ".")
+}
+
+func isConventionalType(t string) bool {
+	for _, allowed := range ConventionalTypes {
+		if t == allowed {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package description
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-github/v51/github"
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockCompleter struct {
+	mock.Mock
+}
+
+func (m *MockCompleter) ChatCompletion(ctx context.Context, messages []openai.ChatCompletionMessage) (string, error) {
+	args := m.Called(ctx, messages)
+	return args.String(0), args.Error(1)
+}
+
+func TestValidateTitle(t *testing.T) {
+	testCases := []struct {
+		title       string
+		expectError bool
+	}{
+		{title: "feat: add title generation"},
+		{title: "fix(review): skip removed files"},
+		{title: "refactor(api)!: drop v1 endpoints"},
+		{title: "Add title generation", expectError: true},
+		{title: "feature: add title generation", expectError: true},
+		{title: "feat: Add title generation", expectError: true},
+		{title: "feat: add title generation.", expectError: true},
+		{title: "feat: add a title that is far too long to be accepted as a pull request title", expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			err := ValidateTitle(tc.title)
+			if tc.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestInferScope(t *testing.T) {
+	testCases := []struct {
+		name     string
+		files    []string
+		expected string
+	}{
+		{name: "Single package", files: []string{"review/review.go", "review/utils.go"}, expected: "review"},
+		{name: "Command", files: []string{"cmd/description/main.go"}, expected: "description"},
+		{name: "Several packages", files: []string{"review/review.go", "jira/jira.go"}, expected: ""},
+		{name: "Root file", files: []string{"review/review.go", "README.md"}, expected: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			diff := &github.CommitsComparison{}
+			for _, f := range tc.files {
+				diff.Files = append(diff.Files, &github.CommitFile{Filename: github.String(f)})
+			}
+			assert.Equal(t, tc.expected, InferScope(diff))
+		})
+	}
+}
+
+func TestGenerateTitle(t *testing.T) {
+	testCases := []struct {
+		name         string
+		mockResponse string
+		expected     string
+		expectError  bool
+	}{
+		{name: "Valid title", mockResponse: "feat(review): add budget caps", expected: "feat(review): add budget caps"},
+		{name: "Quoted title", mockResponse: "\"fix(review): handle empty patches.\"\n", expected: "fix(review): handle empty patches"},
+		{name: "Invalid title", mockResponse: "Added budget caps", expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCompleter := new(MockCompleter)
+			mockCompleter.On("ChatCompletion", mock.Anything, mock.MatchedBy(func(messages []openai.ChatCompletionMessage) bool {
+				return assert.Contains(t, messages[1].Content, "Scope: review")
+			})).Return(tc.mockResponse, nil)
+			diff := &github.CommitsComparison{
+				Files: []*github.CommitFile{{Filename: github.String("review/review.go"), Status: github.String("modified")}},
+			}
+
+			title, err := GenerateTitle(context.Background(), mockCompleter, diff, &github.PullRequest{Title: github.String("wip")}, "description")
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, title)
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v51/github"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// jwtLifetime is below the 10 minutes GitHub accepts, leaving room for clock drift.
+	jwtLifetime = 9 * time.Minute
+	// tokenRefreshMargin renews installation tokens this long before they expire.
+	tokenRefreshMargin = 5 * time.Minute
+)
+
+// newAppClient creates a client authenticated as an installation of the GitHub App. Requests are sent
+// through transport.
+func newAppClient(ctx context.Context, cfg Config, transport http.RoundTripper, owner, repo string) (*Client, error) {
+	key, err := loadPrivateKey(cfg.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// app endpoints are called with a JWT, everything else with an installation token
+	appClient, err := newClient(&http.Client{Transport: &appTransport{appID: cfg.AppID, key: key, base: transport}}, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	installationID := cfg.InstallationID
+	if installationID == 0 {
+		installation, _, err := appClient.client.Apps.FindRepositoryInstallation(ctx, owner, repo)
+		if err != nil {
+			return nil, fmt.Errorf("error finding app installation for %s/%s: %w", owner, repo, err)
+		}
+		installationID = installation.GetID()
+	}
+
+	ts := oauth2.ReuseTokenSource(nil, &installationTokenSource{ctx: ctx, client: appClient.client, installationID: installationID})
+	if _, err := ts.Token(); err != nil {
+		return nil, err
+	}
+
+	return newClient(oauth2.NewClient(ctx, ts), cfg)
+}
+
+// appTransport authenticates requests as the GitHub App with a short-lived JWT.
+type appTransport struct {
+	appID int64
+	key   *rsa.PrivateKey
+	base  http.RoundTripper
+
+	mu     sync.Mutex
+	jwt    string
+	expiry time.Time
+}
+
+func (t *appTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.token()
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return t.base.RoundTrip(req)
+}
+
+func (t *appTransport) token() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if t.jwt != "" && now.Before(t.expiry.Add(-time.Minute)) {
+		return t.jwt, nil
+	}
+
+	jwt, err := signJWT(t.appID, t.key, now)
+	if err != nil {
+		return "", err
+	}
+	t.jwt, t.expiry = jwt, now.Add(jwtLifetime)
+	return t.jwt, nil
+}
+
+// signJWT creates the RS256 JWT GitHub expects from apps. It is issued a minute in the past to allow for
+// clock drift.
+func signJWT(appID int64, key *rsa.PrivateKey, now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(jwtLifetime).Unix(),
+		"iss": strconv.FormatInt(appID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", fmt.Errorf("error signing jwt: %w", err)
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// installationTokenSource exchanges the app JWT for installation tokens.
+type installationTokenSource struct {
+	ctx            context.Context
+	client         *github.Client
+	installationID int64
+}
+
+func (s *installationTokenSource) Token() (*oauth2.Token, error) {
+	token, _, err := s.client.Apps.CreateInstallationToken(s.ctx, s.installationID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating installation token: %w", err)
+	}
+
+	return &oauth2.Token{
+		AccessToken: token.GetToken(),
+		// refresh early so long runs never use a token that is about to expire
+		Expiry: token.GetExpiresAt().Add(-tokenRefreshMargin),
+	}, nil
+}
+
+// loadPrivateKey parses a PKCS #1 or PKCS #8 PEM encoded RSA key, or reads it from the file at key.
+func loadPrivateKey(key string) (*rsa.PrivateKey, error) {
+	data := []byte(key)
+	if !strings.Contains(key, "-----BEGIN") {
+		var err error
+		if data, err = os.ReadFile(key); err != nil {
+			return nil, fmt.Errorf("error reading app private key: %w", err)
+		}
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("error decoding app private key: no PEM data found")
+	}
+	if k, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return k, nil
+	}
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing app private key: %w", err)
+	}
+	rsaKey, ok := k.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("error parsing app private key: not an RSA key")
+	}
+	return rsaKey, nil
+}
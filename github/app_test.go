@@ -0,0 +1,124 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func verifyJWT(t *testing.T, key *rsa.PublicKey, header string) map[string]interface{} {
+	token := strings.TrimPrefix(header, "Bearer ")
+	parts := strings.Split(token, ".")
+	require.Len(t, parts, 3)
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(t, err)
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	require.NoError(t, rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature))
+
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(t, err)
+	var claims map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &claims))
+	return claims
+}
+
+func TestNewClientWithConfigApp(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	privateKey := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+
+	testCases := []struct {
+		name           string
+		installationID int64
+		expiresIn      time.Duration
+		tokenRequests  int
+	}{
+		{name: "Installation lookup", expiresIn: time.Hour, tokenRequests: 1},
+		{name: "Installation ID", installationID: 7, expiresIn: time.Hour, tokenRequests: 1},
+		{name: "Refresh before expiry", installationID: 7, expiresIn: 2 * time.Minute, tokenRequests: 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tokenRequests := 0
+			mux := http.NewServeMux()
+			mux.HandleFunc("/repos/acme/app/installation", func(w http.ResponseWriter, r *http.Request) {
+				claims := verifyJWT(t, &key.PublicKey, r.Header.Get("Authorization"))
+				assert.Equal(t, "42", claims["iss"])
+				assert.Less(t, claims["iat"].(float64), float64(time.Now().Unix()))
+				_, _ = w.Write([]byte(`{"id": 7}`))
+			})
+			mux.HandleFunc("/app/installations/7/access_tokens", func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodPost, r.Method)
+				verifyJWT(t, &key.PublicKey, r.Header.Get("Authorization"))
+				tokenRequests++
+				expiresAt := time.Now().Add(tc.expiresIn).UTC().Format(time.RFC3339)
+				fmt.Fprintf(w, `{"token": "ghs_%d", "expires_at": %q}`, tokenRequests, expiresAt)
+			})
+			mux.HandleFunc("/repos/acme/app/pulls/1", func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, fmt.Sprintf("Bearer ghs_%d", tokenRequests), r.Header.Get("Authorization"))
+				_, _ = w.Write([]byte(`{"number": 1}`))
+			})
+			server := httptest.NewServer(http.StripPrefix("/api/v3", mux))
+			t.Cleanup(server.Close)
+
+			client, err := NewClientWithConfig(context.Background(), Config{
+				AppID:          42,
+				PrivateKey:     privateKey,
+				InstallationID: tc.installationID,
+				BaseURL:        server.URL,
+			}, "acme", "app")
+			require.NoError(t, err)
+
+			for i := 0; i < 2; i++ {
+				pr, err := client.GetPullRequest(context.Background(), "acme", "app", 1)
+				require.NoError(t, err)
+				assert.Equal(t, 1, pr.GetNumber())
+			}
+			assert.Equal(t, tc.tokenRequests, tokenRequests)
+		})
+	}
+}
+
+func TestNewClientWithConfigErrors(t *testing.T) {
+	_, err := NewClientWithConfig(context.Background(), Config{}, "acme", "app")
+	assert.Error(t, err)
+
+	_, err = NewClientWithConfig(context.Background(), Config{AppID: 42, PrivateKey: "-----BEGIN nothing"}, "acme", "app")
+	assert.Error(t, err)
+}
+
+func TestLoadPrivateKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "app.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}), 0o600))
+
+	loaded, err := loadPrivateKey(path)
+	require.NoError(t, err)
+	assert.True(t, key.Equal(loaded))
+
+	_, err = loadPrivateKey(filepath.Join(t.TempDir(), "missing.pem"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,210 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v51/github"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/logs"
+)
+
+// maxListedNames is how many files Coverage.String names before summarizing the rest.
+const maxListedNames = 10
+
+// Coverage describes how much of a pull request's changes could be retrieved.
+type Coverage struct {
+	// ChangedFiles is the number of files GitHub reports as changed.
+	ChangedFiles int `json:"changed_files"`
+	// AnalyzedFiles is the number of files whose changes were retrieved.
+	AnalyzedFiles int `json:"analyzed_files"`
+	// FromRawDiff are the files whose patch GitHub left out or truncated and that were recovered from the raw diff.
+	FromRawDiff []string `json:"from_raw_diff,omitempty"`
+	// MissingFiles are the files whose changes could not be retrieved. Files beyond the listing limit of GitHub
+	// are only counted, as their names are unknown.
+	MissingFiles []string `json:"missing_files,omitempty"`
+}
+
+// Complete reports whether the changes of all files were retrieved.
+func (c Coverage) Complete() bool {
+	return len(c.MissingFiles) == 0 && c.AnalyzedFiles >= c.ChangedFiles
+}
+
+// String explains what was left out, or returns an empty string when the coverage is complete.
+func (c Coverage) String() string {
+	if c.Complete() {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Only %d of %d changed files could be analyzed.", c.AnalyzedFiles, c.ChangedFiles)
+	if len(c.MissingFiles) > 0 {
+		names := c.MissingFiles
+		if len(names) > maxListedNames {
+			names = names[:maxListedNames]
+		}
+		fmt.Fprintf(&sb, " Not analyzed: %s", strings.Join(names, ", "))
+		if more := len(c.MissingFiles) - len(names); more > 0 {
+			fmt.Fprintf(&sb, " and %d more", more)
+		}
+		sb.WriteString(".")
+	}
+	return sb.String()
+}
+
+// GetPullRequestChanges returns the commits and all changed files of the pull request. Files are listed page by
+// page instead of relying on the comparison, which stops at 300 files. Patches that GitHub leaves out or truncates
+// are recovered from the raw diff when possible. The coverage reports what is still missing.
+func (c *Client) GetPullRequestChanges(ctx context.Context, owner, repo string, pr *github.PullRequest) (*github.CommitsComparison, Coverage, error) {
+	diff, err := c.CompareCommits(ctx, owner, repo, pr.GetBase().GetSHA(), pr.GetHead().GetSHA())
+	if err != nil {
+		return nil, Coverage{}, err
+	}
+
+	files, err := c.GetPullRequestFiles(ctx, owner, repo, pr.GetNumber())
+	if err != nil {
+		return nil, Coverage{}, err
+	}
+
+	coverage := Coverage{ChangedFiles: pr.GetChangedFiles()}
+	var incomplete []*github.CommitFile
+	for _, file := range files {
+		if !patchComplete(file) {
+			incomplete = append(incomplete, file)
+		}
+	}
+
+	if len(incomplete) > 0 || len(files) < coverage.ChangedFiles {
+		logs.Printf("Diff of %d files is incomplete, falling back to the raw diff\n", len(incomplete)+coverage.ChangedFiles-len(files))
+		raw, err := c.GetPullRequestDiff(ctx, owner, repo, pr.GetNumber())
+		if err != nil {
+			logs.Printf("Error getting raw diff: %v \n", err)
+		} else {
+			files, coverage.FromRawDiff = mergeRawDiff(files, SplitDiff(raw))
+		}
+	}
+
+	for _, file := range files {
+		if patchComplete(file) {
+			coverage.AnalyzedFiles++
+		} else {
+			coverage.MissingFiles = append(coverage.MissingFiles, file.GetFilename())
+		}
+	}
+	if coverage.ChangedFiles < len(files) {
+		coverage.ChangedFiles = len(files)
+	}
+
+	diff.Files = files
+	return diff, coverage, nil
+}
+
+// patchComplete reports whether the patch has all added and deleted lines of the file. Binary files have
+// neither a patch nor changed lines.
+func patchComplete(file *github.CommitFile) bool {
+	if file.GetChanges() == 0 {
+		return true
+	}
+
+	changed := 0
+	for _, line := range strings.Split(file.GetPatch(), "\n") {
+		if strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-") {
+			changed++
+		}
+	}
+	return changed >= file.GetAdditions()+file.GetDeletions()
+}
+
+// mergeRawDiff replaces incomplete patches with the ones from the raw diff and adds the files that were not
+// listed. It returns the merged files and the names of the files taken from the raw diff.
+func mergeRawDiff(files, raw []*github.CommitFile) ([]*github.CommitFile, []string) {
+	rawByName := make(map[string]*github.CommitFile, len(raw))
+	for _, file := range raw {
+		rawByName[file.GetFilename()] = file
+	}
+
+	var recovered []string
+	listed := make(map[string]bool, len(files))
+	for _, file := range files {
+		listed[file.GetFilename()] = true
+		if patchComplete(file) {
+			continue
+		}
+		if r, ok := rawByName[file.GetFilename()]; ok && r.GetPatch() != "" {
+			file.Patch = r.Patch
+			recovered = append(recovered, file.GetFilename())
+		}
+	}
+	for _, file := range raw {
+		if !listed[file.GetFilename()] {
+			files = append(files, file)
+			recovered = append(recovered, file.GetFilename())
+		}
+	}
+
+	return files, recovered
+}
+
+// SplitDiff splits a raw unified diff into files. Patches start at the first hunk like the patches of the API.
+func SplitDiff(raw string) []*github.CommitFile {
+	var files []*github.CommitFile
+	var file *github.CommitFile
+	var patch []string
+	flush := func() {
+		if file == nil {
+			return
+		}
+		if len(patch) > 0 {
+			file.Patch = github.String(strings.Join(patch, "\n"))
+		}
+		file.Changes = github.Int(file.GetAdditions() + file.GetDeletions())
+		files = append(files, file)
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(raw, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flush()
+			file = &github.CommitFile{
+				Filename:  github.String(headerPath(strings.TrimPrefix(line, "diff --git "))),
+				Status:    github.String("modified"),
+				Additions: github.Int(0),
+				Deletions: github.Int(0),
+			}
+			patch = nil
+		case file == nil:
+			continue
+		case len(patch) > 0 || strings.HasPrefix(line, "@@"):
+			patch = append(patch, line)
+			if strings.HasPrefix(line, "+") {
+				*file.Additions++
+			} else if strings.HasPrefix(line, "-") {
+				*file.Deletions++
+			}
+		case strings.HasPrefix(line, "new file mode"):
+			file.Status = github.String("added")
+		case strings.HasPrefix(line, "deleted file mode"):
+			file.Status = github.String("removed")
+		case strings.HasPrefix(line, "rename from "):
+			file.Status = github.String("renamed")
+			file.PreviousFilename = github.String(strings.TrimPrefix(line, "rename from "))
+		case strings.HasPrefix(line, "rename to "):
+			file.Filename = github.String(strings.TrimPrefix(line, "rename to "))
+		case strings.HasPrefix(line, "+++ b/"):
+			file.Filename = github.String(strings.TrimPrefix(line, "+++ b/"))
+		}
+	}
+	flush()
+
+	return files
+}
+
+// headerPath returns the path of "a/path b/path" diff headers. Both sides are the same unless the file is
+// renamed, in which case the rename lines that follow have the path.
+func headerPath(header string) string {
+	if len(header) < 5 || !strings.HasPrefix(header, "a/") {
+		return header
+	}
+	return header[2 : (len(header)-1)/2]
+}
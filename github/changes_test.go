@@ -0,0 +1,170 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v51/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const rawDiff = `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,3 @@
+ package main
+-var a = 1
++var a = 2
+diff --git a/docs/new file.md b/docs/new file.md
+new file mode 100644
+index 0000000..3333333
+--- /dev/null
++++ b/docs/new file.md
+@@ -0,0 +1,2 @@
++# Title
++Text
+diff --git a/old.go b/old.go
+deleted file mode 100644
+index 4444444..0000000
+--- a/old.go
++++ /dev/null
+@@ -1 +0,0 @@
+-package old
+diff --git a/a.go b/b.go
+similarity index 90%
+rename from a.go
+rename to b.go
+index 5555555..6666666 100644
+--- a/a.go
++++ b/b.go
+@@ -1 +1 @@
+-package a
++package b
+diff --git a/logo.png b/logo.png
+index 7777777..8888888 100644
+Binary files a/logo.png and b/logo.png differ
+`
+
+func TestSplitDiff(t *testing.T) {
+	expected := []*github.CommitFile{
+		{Filename: github.String("main.go"), Status: github.String("modified"), Additions: github.Int(1), Deletions: github.Int(1), Changes: github.Int(2), Patch: github.String("@@ -1,3 +1,3 @@\n package main\n-var a = 1\n+var a = 2")},
+		{Filename: github.String("docs/new file.md"), Status: github.String("added"), Additions: github.Int(2), Deletions: github.Int(0), Changes: github.Int(2), Patch: github.String("@@ -0,0 +1,2 @@\n+# Title\n+Text")},
+		{Filename: github.String("old.go"), Status: github.String("removed"), Additions: github.Int(0), Deletions: github.Int(1), Changes: github.Int(1), Patch: github.String("@@ -1 +0,0 @@\n-package old")},
+		{Filename: github.String("b.go"), PreviousFilename: github.String("a.go"), Status: github.String("renamed"), Additions: github.Int(1), Deletions: github.Int(1), Changes: github.Int(2), Patch: github.String("@@ -1 +1 @@\n-package a\n+package b")},
+		{Filename: github.String("logo.png"), Status: github.String("modified"), Additions: github.Int(0), Deletions: github.Int(0), Changes: github.Int(0)},
+	}
+
+	assert.Equal(t, expected, SplitDiff(rawDiff))
+}
+
+func TestGetPullRequestChanges(t *testing.T) {
+	testCases := []struct {
+		name         string
+		changedFiles int
+		rawStatus    int
+		expected     Coverage
+		patches      map[string]string
+	}{
+		{
+			name:         "Complete",
+			changedFiles: 2,
+			rawStatus:    http.StatusOK,
+			expected:     Coverage{ChangedFiles: 2, AnalyzedFiles: 2},
+			patches:      map[string]string{"main.go": "@@ -1 +1 @@\n-var a = 1\n+var a = 2", "logo.png": ""},
+		},
+		{
+			name:         "Missing patch and unlisted files recovered",
+			changedFiles: 6,
+			rawStatus:    http.StatusOK,
+			expected: Coverage{
+				ChangedFiles:  6,
+				AnalyzedFiles: 6,
+				FromRawDiff:   []string{"big.go", "docs/new file.md", "old.go", "b.go"},
+			},
+			patches: map[string]string{"big.go": "@@ -1 +1,2 @@\n package big\n+var a = 1\n+var b = 2", "docs/new file.md": "@@ -0,0 +1,2 @@\n+# Title\n+Text"},
+		},
+		{
+			name:         "Raw diff too large",
+			changedFiles: 6,
+			rawStatus:    http.StatusNotAcceptable,
+			expected: Coverage{
+				ChangedFiles:  6,
+				AnalyzedFiles: 2,
+				MissingFiles:  []string{"big.go"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/repos/acme/app/compare/base...head", func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(`{"commits": [{"sha": "head"}], "files": [{"filename": "main.go"}]}`))
+			})
+			mux.HandleFunc("/repos/acme/app/pulls/1/files", func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("page") == "2" {
+					_, _ = w.Write([]byte(`[{"filename": "logo.png", "changes": 0}]`))
+					return
+				}
+				w.Header().Set("Link", fmt.Sprintf(`<http://%s%s?page=2>; rel="next"`, r.Host, r.URL.Path))
+				files := `{"filename": "main.go", "additions": 1, "deletions": 1, "changes": 2, "patch": "@@ -1 +1 @@\n-var a = 1\n+var a = 2"}`
+				if tc.changedFiles > 2 {
+					files += `, {"filename": "big.go", "additions": 2, "deletions": 0, "changes": 2}`
+				}
+				_, _ = w.Write([]byte("[" + files + "]"))
+			})
+			mux.HandleFunc("/repos/acme/app/pulls/1", func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "application/vnd.github.v3.diff", r.Header.Get("Accept"))
+				w.WriteHeader(tc.rawStatus)
+				if tc.rawStatus == http.StatusOK {
+					_, _ = w.Write([]byte(strings.Replace(rawDiff, "diff --git a/main.go", "diff --git a/big.go b/big.go\n--- a/big.go\n+++ b/big.go\n@@ -1 +1,2 @@\n package big\n+var a = 1\n+var b = 2\ndiff --git a/main.go", 1)))
+				} else {
+					_, _ = w.Write([]byte(`{"message": "diff too large"}`))
+				}
+			})
+			client := newTestClient(t, mux)
+
+			pr := &github.PullRequest{
+				Number:       github.Int(1),
+				ChangedFiles: github.Int(tc.changedFiles),
+				Base:         &github.PullRequestBranch{SHA: github.String("base")},
+				Head:         &github.PullRequestBranch{SHA: github.String("head")},
+			}
+			diff, coverage, err := client.GetPullRequestChanges(context.Background(), "acme", "app", pr)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, coverage)
+			assert.Len(t, diff.Commits, 1)
+			assert.Len(t, diff.Files, coverage.AnalyzedFiles+len(coverage.MissingFiles))
+			patches := map[string]string{}
+			for _, file := range diff.Files {
+				patches[file.GetFilename()] = file.GetPatch()
+			}
+			for name, patch := range tc.patches {
+				if patch == "" {
+					assert.Contains(t, patches, name)
+					continue
+				}
+				assert.Equal(t, patch, patches[name])
+			}
+		})
+	}
+}
+
+func TestCoverageString(t *testing.T) {
+	assert.Empty(t, Coverage{ChangedFiles: 2, AnalyzedFiles: 2}.String())
+	assert.Equal(t, "Only 2 of 3100 changed files could be analyzed.", Coverage{ChangedFiles: 3100, AnalyzedFiles: 2}.String())
+
+	missing := make([]string, 12)
+	for i := range missing {
+		missing[i] = fmt.Sprintf("f%d.go", i)
+	}
+	assert.Equal(t,
+		"Only 0 of 12 changed files could be analyzed. Not analyzed: f0.go, f1.go, f2.go, f3.go, f4.go, f5.go, f6.go, f7.go, f8.go, f9.go and 2 more.",
+		Coverage{ChangedFiles: 12, MissingFiles: missing}.String())
+}
@@ -0,0 +1,79 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v51/github"
+	"golang.org/x/oauth2"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/httpclient"
+)
+
+// Config configures how a Client connects and authenticates: with Token, or as a GitHub App installation when
+// AppID is set.
+type Config struct {
+	Token string
+	// AppID is the ID of the GitHub App.
+	AppID int64
+	// PrivateKey is the PEM encoded private key of the app, or the path to it.
+	PrivateKey string
+	// InstallationID is the installation of the app. When zero it is looked up by owner and repo.
+	InstallationID int64
+	// BaseURL is the API URL of GitHub Enterprise Server, e.g. https://github.example.com/api/v3/. It defaults to
+	// https://api.github.com/.
+	BaseURL string
+	// UploadURL is the upload URL of GitHub Enterprise Server. It defaults to the host of BaseURL.
+	UploadURL string
+	// CABundle is the path to PEM encoded certificates trusted in addition to the system ones.
+	CABundle string
+	// Proxy is the URL of the HTTP proxy. The proxy environment variables are used when empty.
+	Proxy string
+}
+
+// NewClientWithConfig creates a client for owner/repo as described by cfg.
+func NewClientWithConfig(ctx context.Context, cfg Config, owner, repo string) (*Client, error) {
+	transport, err := httpclient.NewTransport(cfg.CABundle, cfg.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	// oauth2 sends its requests and the authenticated ones through this client
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: transport})
+
+	if cfg.AppID != 0 {
+		return newAppClient(ctx, cfg, transport, owner, repo)
+	}
+	if cfg.Token == "" {
+		return nil, errors.New("github token or app id is required")
+	}
+	return newClient(oauth2.NewClient(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: cfg.Token})), cfg)
+}
+
+func newClient(httpClient *http.Client, cfg Config) (*Client, error) {
+	if cfg.BaseURL == "" {
+		return &Client{github.NewClient(httpClient)}, nil
+	}
+
+	uploadURL := cfg.UploadURL
+	if uploadURL == "" {
+		uploadURL = strings.TrimSuffix(strings.TrimSuffix(cfg.BaseURL, "/"), "/api/v3")
+	}
+	client, err := github.NewEnterpriseClient(cfg.BaseURL, uploadURL, httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing github enterprise urls: %w", err)
+	}
+	return &Client{client}, nil
+}
+
+// WebURL returns the URL of the web interface, e.g. https://github.com or https://github.example.com.
+func (c *Client) WebURL() string {
+	u := *c.client.BaseURL
+	if strings.HasPrefix(u.Host, "api.") {
+		u.Host = strings.TrimPrefix(u.Host, "api.")
+	}
+	u.Path = strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), "/api/v3")
+	return u.String()
+}
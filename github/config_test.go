@@ -0,0 +1,100 @@
+package github
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientURLs(t *testing.T) {
+	testCases := []struct {
+		name    string
+		baseURL string
+		web     string
+		upload  string
+	}{
+		{
+			name:   "github.com",
+			web:    "https://github.com",
+			upload: "https://uploads.github.com/",
+		},
+		{
+			name:    "Enterprise host",
+			baseURL: "https://github.example.com",
+			web:     "https://github.example.com",
+			upload:  "https://github.example.com/api/uploads/",
+		},
+		{
+			name:    "Enterprise API URL",
+			baseURL: "https://github.example.com/api/v3/",
+			web:     "https://github.example.com",
+			upload:  "https://github.example.com/api/uploads/",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewClientWithConfig(context.Background(), Config{Token: "token", BaseURL: tc.baseURL}, "acme", "app")
+			require.NoError(t, err)
+			assert.Equal(t, tc.web, client.WebURL())
+			assert.Equal(t, tc.upload, client.client.UploadURL.String())
+		})
+	}
+}
+
+func TestNewClientWithConfigCABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v3/repos/acme/app/pulls/1", r.URL.Path)
+		_, _ = w.Write([]byte(`{"number": 1}`))
+	}))
+	t.Cleanup(server.Close)
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600))
+
+	client, err := NewClientWithConfig(context.Background(), Config{Token: "token", BaseURL: server.URL}, "acme", "app")
+	require.NoError(t, err)
+	_, err = client.GetPullRequest(context.Background(), "acme", "app", 1)
+	assert.Error(t, err, "the test certificate is not trusted without the bundle")
+
+	client, err = NewClientWithConfig(context.Background(), Config{Token: "token", BaseURL: server.URL, CABundle: bundle}, "acme", "app")
+	require.NoError(t, err)
+	pr, err := client.GetPullRequest(context.Background(), "acme", "app", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, pr.GetNumber())
+
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	require.NoError(t, os.WriteFile(empty, nil, 0o600))
+	_, err = NewClientWithConfig(context.Background(), Config{Token: "token", CABundle: empty}, "acme", "app")
+	assert.Error(t, err)
+}
+
+func TestNewClientWithConfigProxy(t *testing.T) {
+	proxied := false
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = true
+		assert.Equal(t, "http://github.example.com/api/v3/repos/acme/app/pulls/1", r.URL.String())
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`{"number": 1}`))
+	}))
+	t.Cleanup(proxy.Close)
+
+	client, err := NewClientWithConfig(context.Background(), Config{
+		Token:   "token",
+		BaseURL: "http://github.example.com",
+		Proxy:   proxy.URL,
+	}, "acme", "app")
+	require.NoError(t, err)
+
+	pr, err := client.GetPullRequest(context.Background(), "acme", "app", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, pr.GetNumber())
+	assert.True(t, proxied)
+}
@@ -2,11 +2,30 @@ package github
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
 
 	"github.com/google/go-github/v51/github"
 	"golang.org/x/oauth2"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/logs"
 )
 
+// PullRequestTemplatePaths are the locations GitHub looks for a pull request template, in order of precedence.
+var PullRequestTemplatePaths = []string{
+	".github/pull_request_template.md",
+	".github/PULL_REQUEST_TEMPLATE.md",
+	"pull_request_template.md",
+	"PULL_REQUEST_TEMPLATE.md",
+	"docs/pull_request_template.md",
+	"docs/PULL_REQUEST_TEMPLATE.md",
+	".github/PULL_REQUEST_TEMPLATE",
+	"PULL_REQUEST_TEMPLATE",
+	"docs/PULL_REQUEST_TEMPLATE",
+}
+
 type Client struct {
 	client *github.Client
 }
@@ -20,9 +39,22 @@ func NewClient(ctx context.Context, token string) *Client {
 	return &Client{github.NewClient(tc)}
 }
 
+// GetPullRequestFiles returns the changed files of a pull request, following pagination. GitHub lists at most
+// 3000 files.
 func (c *Client) GetPullRequestFiles(ctx context.Context, owner, repo string, prNumber int) ([]*github.CommitFile, error) {
-	files, _, err := c.client.PullRequests.ListFiles(ctx, owner, repo, prNumber, nil)
-	return files, err
+	var files []*github.CommitFile
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := c.client.PullRequests.ListFiles(ctx, owner, repo, prNumber, opts)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, page...)
+		if resp.NextPage == 0 {
+			return files, nil
+		}
+		opts.Page = resp.NextPage
+	}
 }
 
 func (c *Client) GetPullRequest(ctx context.Context, owner, repo string, number int) (*github.PullRequest, error) {
@@ -54,3 +86,140 @@ func (c *Client) CompareCommits(ctx context.Context, owner, repo, base, head str
 	comp, _, err := c.client.Repositories.CompareCommits(ctx, owner, repo, base, head, nil)
 	return comp, err
 }
+
+func (c *Client) CreateIssueComment(ctx context.Context, owner, repo string, number int, comment *github.IssueComment) (*github.IssueComment, error) {
+	createdComment, _, err := c.client.Issues.CreateComment(ctx, owner, repo, number, comment)
+	return createdComment, err
+}
+
+// ListIssueComments returns the comments on the pull request itself, following pagination.
+func (c *Client) ListIssueComments(ctx context.Context, owner, repo string, number int) ([]*github.IssueComment, error) {
+	var comments []*github.IssueComment
+	opts := &github.IssueListCommentsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		page, resp, err := c.client.Issues.ListComments(ctx, owner, repo, number, opts)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, page...)
+		if resp.NextPage == 0 {
+			return comments, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// EditIssueComment replaces the body of a comment. GitHub identifies comments by id alone, number is unused.
+func (c *Client) EditIssueComment(ctx context.Context, owner, repo string, number int, id int64, comment *github.IssueComment) (*github.IssueComment, error) {
+	editedComment, _, err := c.client.Issues.EditComment(ctx, owner, repo, id, comment)
+	return editedComment, err
+}
+
+// UpsertReview posts a review with body as a comment, or updates the body of the earlier review containing marker
+// so that re-runs do not post the same review again.
+func (c *Client) UpsertReview(ctx context.Context, owner, repo string, number int, marker, body string) error {
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		reviews, resp, err := c.client.PullRequests.ListReviews(ctx, owner, repo, number, opts)
+		if err != nil {
+			return fmt.Errorf("error listing reviews: %w", err)
+		}
+		for _, review := range reviews {
+			if !strings.Contains(review.GetBody(), marker) {
+				continue
+			}
+			if review.GetBody() == body {
+				logs.Println("Review is up to date")
+				return nil
+			}
+			logs.Println("Updating review")
+			if _, _, err := c.client.PullRequests.UpdateReview(ctx, owner, repo, number, review.GetID(), body); err != nil {
+				return fmt.Errorf("error updating review: %w", err)
+			}
+			return nil
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	logs.Println("Creating review")
+	review := &github.PullRequestReviewRequest{Body: github.String(body), Event: github.String("COMMENT")}
+	if _, _, err := c.client.PullRequests.CreateReview(ctx, owner, repo, number, review); err != nil {
+		return fmt.Errorf("error creating review: %w", err)
+	}
+	return nil
+}
+
+// GetFileContent returns the content of a file at ref. It returns an empty string if the file does not exist.
+func (c *Client) GetFileContent(ctx context.Context, owner, repo, path, ref string) (string, error) {
+	file, _, err := c.getContents(ctx, owner, repo, path, ref)
+	if err != nil || file == nil {
+		return "", err
+	}
+	return file.GetContent()
+}
+
+// GetPullRequestTemplate returns the pull request template of the repository at ref, checking all standard
+// locations. For template directories the first markdown file is used. It returns an empty string if there is none.
+func (c *Client) GetPullRequestTemplate(ctx context.Context, owner, repo, ref string) (string, error) {
+	for _, path := range PullRequestTemplatePaths {
+		file, dir, err := c.getContents(ctx, owner, repo, path, ref)
+		if err != nil {
+			return "", err
+		}
+		if file == nil {
+			for _, entry := range dir {
+				if entry.GetType() == "file" && strings.HasSuffix(strings.ToLower(entry.GetName()), ".md") {
+					return c.GetFileContent(ctx, owner, repo, entry.GetPath(), ref)
+				}
+			}
+			continue
+		}
+		return file.GetContent()
+	}
+	return "", nil
+}
+
+// getContents returns nil contents without an error if the path does not exist.
+func (c *Client) getContents(ctx context.Context, owner, repo, path, ref string) (*github.RepositoryContent, []*github.RepositoryContent, error) {
+	file, dir, _, err := c.client.Repositories.GetContents(ctx, owner, repo, path, &github.RepositoryContentGetOptions{Ref: ref})
+	var errResp *github.ErrorResponse
+	if errors.As(err, &errResp) && errResp.Response != nil && errResp.Response.StatusCode == http.StatusNotFound {
+		return nil, nil, nil
+	}
+	return file, dir, err
+}
+
+// ListCommitsBetween returns all commits reachable from head but not from base, following pagination.
+func (c *Client) ListCommitsBetween(ctx context.Context, owner, repo, base, head string) ([]*github.RepositoryCommit, error) {
+	var commits []*github.RepositoryCommit
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		comp, resp, err := c.client.Repositories.CompareCommits(ctx, owner, repo, base, head, opts)
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, comp.Commits...)
+		if resp.NextPage == 0 {
+			return commits, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func (c *Client) ListPullRequestsWithCommit(ctx context.Context, owner, repo, sha string) ([]*github.PullRequest, error) {
+	prs, _, err := c.client.PullRequests.ListPullRequestsWithCommit(ctx, owner, repo, sha, nil)
+	return prs, err
+}
+
+func (c *Client) CreateRelease(ctx context.Context, owner, repo string, release *github.RepositoryRelease) (*github.RepositoryRelease, error) {
+	createdRelease, _, err := c.client.Repositories.CreateRelease(ctx, owner, repo, release)
+	return createdRelease, err
+}
+
+func (c *Client) GetIssue(ctx context.Context, owner, repo string, number int) (*github.Issue, error) {
+	issue, _, err := c.client.Issues.Get(ctx, owner, repo, number)
+	return issue, err
+}
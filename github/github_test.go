@@ -0,0 +1,119 @@
+package github
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v51/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T, handler http.Handler) *Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := github.NewClient(nil)
+	baseURL, err := url.Parse(server.URL + "/")
+	require.NoError(t, err)
+	client.BaseURL = baseURL
+
+	return &Client{client: client}
+}
+
+func writeFile(w http.ResponseWriter, path, content string) {
+	fmt.Fprintf(w, `{"type": "file", "path": %q, "encoding": "base64", "content": %q}`, path, base64.StdEncoding.EncodeToString([]byte(content)))
+}
+
+func TestGetPullRequestTemplate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		files    map[string]string
+		dirs     map[string]string
+		expected string
+	}{
+		{
+			name:     "Template in .github",
+			files:    map[string]string{".github/pull_request_template.md": "## Why"},
+			expected: "## Why",
+		},
+		{
+			name:     "Template in docs",
+			files:    map[string]string{"docs/PULL_REQUEST_TEMPLATE.md": "## Docs"},
+			expected: "## Docs",
+		},
+		{
+			name:     "Template directory",
+			files:    map[string]string{".github/PULL_REQUEST_TEMPLATE/feature.md": "## Feature"},
+			dirs:     map[string]string{".github/PULL_REQUEST_TEMPLATE": ".github/PULL_REQUEST_TEMPLATE/feature.md"},
+			expected: "## Feature",
+		},
+		{
+			name: "No template",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "main", r.URL.Query().Get("ref"))
+				path := r.URL.Path[len("/repos/owner/repo/contents/"):]
+				if content, ok := tc.files[path]; ok {
+					writeFile(w, path, content)
+					return
+				}
+				if file, ok := tc.dirs[path]; ok {
+					fmt.Fprintf(w, `[{"type": "file", "name": "feature.md", "path": %q}]`, file)
+					return
+				}
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"message": "Not Found"}`)
+			}))
+
+			template, err := client.GetPullRequestTemplate(context.Background(), "owner", "repo", "main")
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, template)
+		})
+	}
+}
+
+func TestUpsertReview(t *testing.T) {
+	const marker = "<!-- gpt:test -->"
+	body := marker + "\nNew"
+
+	testCases := []struct {
+		name     string
+		reviews  string
+		expected string
+	}{
+		{name: "No earlier review", reviews: `[{"id": 1, "body": "Looks good"}]`, expected: "POST /repos/owner/repo/pulls/1/reviews"},
+		{name: "Earlier review", reviews: `[{"id": 1, "body": "Looks good"}, {"id": 2, "body": "<!-- gpt:test -->\nOld"}]`, expected: "PUT /repos/owner/repo/pulls/1/reviews/2"},
+		{name: "Up to date", reviews: `[{"id": 2, "body": "<!-- gpt:test -->\nNew"}]`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests []string
+			client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet {
+					fmt.Fprint(w, tc.reviews)
+					return
+				}
+				requests = append(requests, r.Method+" "+r.URL.Path)
+				fmt.Fprint(w, `{"id": 3}`)
+			}))
+
+			require.NoError(t, client.UpsertReview(context.Background(), "owner", "repo", 1, marker, body))
+			if tc.expected == "" {
+				assert.Empty(t, requests)
+				return
+			}
+			assert.Equal(t, []string{tc.expected}, requests)
+		})
+	}
+}
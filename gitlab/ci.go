@@ -0,0 +1,32 @@
+package gitlab
+
+import "os"
+
+// ciVariables maps the environment variables of the commands to the predefined variables of GitLab CI.
+var ciVariables = map[string]string{
+	"GITLAB_URL": "CI_SERVER_URL",
+	"OWNER":      "CI_PROJECT_NAMESPACE",
+	"REPO":       "CI_PROJECT_NAME",
+	"PR_NUMBER":  "CI_MERGE_REQUEST_IID",
+}
+
+// ApplyCIEnv configures the commands from the predefined variables of a GitLab CI merge request pipeline, so
+// only GITLAB_TOKEN has to be set. Variables that are already set are kept. It does nothing outside of
+// GitLab CI.
+func ApplyCIEnv() {
+	if os.Getenv("GITLAB_CI") != "true" {
+		return
+	}
+
+	setDefault("CODE_HOST", "gitlab")
+	for name, ciName := range ciVariables {
+		setDefault(name, os.Getenv(ciName))
+	}
+}
+
+func setDefault(name, value string) {
+	if _, ok := os.LookupEnv(name); ok || value == "" {
+		return
+	}
+	_ = os.Setenv(name, value)
+}
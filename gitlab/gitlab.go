@@ -0,0 +1,496 @@
+// Package gitlab reads and comments on GitLab merge requests through the REST API version 4.
+package gitlab
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v51/github"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/codehost"
+	ghClient "github.com/ravilushqa/gpt-pullrequest-updater/github"
+)
+
+// DefaultURL is the URL of gitlab.com.
+const DefaultURL = "https://gitlab.com"
+
+// TemplatePath is the merge request template GitLab uses by default.
+const TemplatePath = ".gitlab/merge_request_templates/Default.md"
+
+// Config configures a Client.
+type Config struct {
+	// BaseURL is the GitLab URL. It defaults to DefaultURL.
+	BaseURL string
+	// Token is a personal, project or group access token with the api scope.
+	Token      string
+	HTTPClient *http.Client
+}
+
+// Client implements codehost.Host for GitLab. Owner is the namespace of the project, including subgroups,
+// repo the project name and number the IID of the merge request.
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+	token      string
+
+	mu sync.Mutex
+	// changes caches the changes of merge requests, inline comments need their diff refs and patches
+	changes map[string]*changesResponse
+}
+
+var _ codehost.Host = (*Client)(nil)
+
+func NewClient(cfg Config) (*Client, error) {
+	if cfg.Token == "" {
+		return nil, errors.New("gitlab token is required")
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = DefaultURL
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{}
+	}
+
+	return &Client{
+		httpClient: cfg.HTTPClient,
+		baseURL:    strings.TrimSuffix(strings.TrimSuffix(cfg.BaseURL, "/"), "/api/v4") + "/api/v4",
+		token:      cfg.Token,
+		changes:    map[string]*changesResponse{},
+	}, nil
+}
+
+type diffRefs struct {
+	BaseSHA  string `json:"base_sha"`
+	HeadSHA  string `json:"head_sha"`
+	StartSHA string `json:"start_sha"`
+}
+
+type mergeRequest struct {
+	IID          int        `json:"iid"`
+	Title        string     `json:"title"`
+	Description  string     `json:"description"`
+	State        string     `json:"state"`
+	WebURL       string     `json:"web_url"`
+	SourceBranch string     `json:"source_branch"`
+	TargetBranch string     `json:"target_branch"`
+	SHA          string     `json:"sha"`
+	MergedAt     *time.Time `json:"merged_at"`
+	Labels       []string   `json:"labels"`
+	ChangesCount string     `json:"changes_count"`
+	Author       struct {
+		Username string `json:"username"`
+		// Name identifies the author's commits, GitLab does not link commits to users.
+		Name string `json:"name"`
+	} `json:"author"`
+	DiffRefs diffRefs `json:"diff_refs"`
+}
+
+type change struct {
+	OldPath     string `json:"old_path"`
+	NewPath     string `json:"new_path"`
+	NewFile     bool   `json:"new_file"`
+	RenamedFile bool   `json:"renamed_file"`
+	DeletedFile bool   `json:"deleted_file"`
+	Diff        string `json:"diff"`
+}
+
+type changesResponse struct {
+	mergeRequest
+	Changes []change `json:"changes"`
+	// Overflow is set when GitLab left out files because the merge request has too many changes.
+	Overflow bool `json:"overflow"`
+}
+
+type commit struct {
+	ID          string `json:"id"`
+	Message     string `json:"message"`
+	AuthorName  string `json:"author_name"`
+	AuthorEmail string `json:"author_email"`
+}
+
+type note struct {
+	ID   int64  `json:"id"`
+	Body string `json:"body"`
+	// System is set for the notes GitLab adds itself, e.g. when commits are pushed.
+	System bool `json:"system"`
+}
+
+type discussion struct {
+	ID    string `json:"id"`
+	Notes []note `json:"notes"`
+}
+
+type errorResponse struct {
+	Message interface{} `json:"message"`
+	Error   string      `json:"error"`
+}
+
+// GetPullRequest returns the merge request mapped to a pull request.
+func (c *Client) GetPullRequest(ctx context.Context, owner, repo string, number int) (*github.PullRequest, error) {
+	var mr mergeRequest
+	if err := c.get(ctx, mergeRequestPath(owner, repo, number), &mr); err != nil {
+		return nil, fmt.Errorf("error getting merge request !%d: %w", number, err)
+	}
+	return mr.pullRequest(), nil
+}
+
+// GetPullRequestChanges returns the commits and the changes of the merge request. The coverage is incomplete when
+// GitLab leaves out files of very large merge requests.
+func (c *Client) GetPullRequestChanges(ctx context.Context, owner, repo string, pr *github.PullRequest) (*github.CommitsComparison, ghClient.Coverage, error) {
+	changes, err := c.getChanges(ctx, owner, repo, pr.GetNumber())
+	if err != nil {
+		return nil, ghClient.Coverage{}, err
+	}
+	commits, err := c.listCommits(ctx, owner, repo, pr.GetNumber())
+	if err != nil {
+		return nil, ghClient.Coverage{}, err
+	}
+
+	diff := &github.CommitsComparison{Commits: commits}
+	for _, ch := range changes.Changes {
+		diff.Files = append(diff.Files, ch.commitFile())
+	}
+
+	coverage := ghClient.Coverage{ChangedFiles: len(diff.Files), AnalyzedFiles: len(diff.Files)}
+	if changes.Overflow {
+		// changes_count is e.g. "1000+" for overflowing merge requests, so the total is only a lower bound
+		count, _ := strconv.Atoi(strings.TrimSuffix(changes.ChangesCount, "+"))
+		coverage.ChangedFiles = count
+		if coverage.ChangedFiles <= len(diff.Files) {
+			coverage.ChangedFiles = len(diff.Files) + 1
+		}
+	}
+
+	return diff, coverage, nil
+}
+
+// UpdatePullRequest updates the title and the description of the merge request.
+func (c *Client) UpdatePullRequest(ctx context.Context, owner, repo string, number int, pr *github.PullRequest) (*github.PullRequest, error) {
+	update := map[string]string{}
+	if pr.Title != nil {
+		update["title"] = pr.GetTitle()
+	}
+	if pr.Body != nil {
+		update["description"] = pr.GetBody()
+	}
+
+	var mr mergeRequest
+	if err := c.send(ctx, http.MethodPut, mergeRequestPath(owner, repo, number), update, &mr); err != nil {
+		return nil, fmt.Errorf("error updating merge request !%d: %w", number, err)
+	}
+	return mr.pullRequest(), nil
+}
+
+// CreatePullRequestComment starts a discussion on the line of the merge request diff at the position of the
+// comment.
+func (c *Client) CreatePullRequestComment(ctx context.Context, owner, repo string, number int, comment *github.PullRequestComment) (*github.PullRequestComment, error) {
+	changes, err := c.getChanges(ctx, owner, repo, number)
+	if err != nil {
+		return nil, err
+	}
+
+	var file *change
+	for i := range changes.Changes {
+		if changes.Changes[i].NewPath == comment.GetPath() {
+			file = &changes.Changes[i]
+			break
+		}
+	}
+	if file == nil {
+		return nil, fmt.Errorf("error creating discussion: %s is not changed", comment.GetPath())
+	}
+	line, err := codehost.LineAt(file.Diff, comment.GetPosition())
+	if err != nil {
+		return nil, fmt.Errorf("error creating discussion on %s: %w", comment.GetPath(), err)
+	}
+
+	position := map[string]interface{}{
+		"position_type": "text",
+		"base_sha":      changes.DiffRefs.BaseSHA,
+		"start_sha":     changes.DiffRefs.StartSHA,
+		"head_sha":      changes.DiffRefs.HeadSHA,
+		"old_path":      file.OldPath,
+		"new_path":      file.NewPath,
+	}
+	if line.New != 0 {
+		position["new_line"] = line.New
+	}
+	if line.Old != 0 {
+		position["old_line"] = line.Old
+	}
+
+	var d discussion
+	payload := map[string]interface{}{"body": comment.GetBody(), "position": position}
+	if err := c.send(ctx, http.MethodPost, mergeRequestPath(owner, repo, number)+"/discussions", payload, &d); err != nil {
+		return nil, fmt.Errorf("error creating discussion: %w", err)
+	}
+
+	created := &github.PullRequestComment{Path: comment.Path, Position: comment.Position, Body: comment.Body}
+	if len(d.Notes) > 0 {
+		created.ID = github.Int64(d.Notes[0].ID)
+	}
+	return created, nil
+}
+
+// CreateIssueComment adds a note to the merge request.
+func (c *Client) CreateIssueComment(ctx context.Context, owner, repo string, number int, comment *github.IssueComment) (*github.IssueComment, error) {
+	var n note
+	if err := c.send(ctx, http.MethodPost, mergeRequestPath(owner, repo, number)+"/notes", map[string]string{"body": comment.GetBody()}, &n); err != nil {
+		return nil, fmt.Errorf("error creating note: %w", err)
+	}
+	return &github.IssueComment{ID: github.Int64(n.ID), Body: github.String(n.Body)}, nil
+}
+
+// ListIssueComments returns the notes on the merge request, oldest first, without the notes GitLab adds itself.
+func (c *Client) ListIssueComments(ctx context.Context, owner, repo string, number int) ([]*github.IssueComment, error) {
+	var comments []*github.IssueComment
+	page := "1"
+	for page != "" {
+		var batch []note
+		resp, err := c.doJSON(ctx, http.MethodGet, mergeRequestPath(owner, repo, number)+"/notes?sort=asc&order_by=created_at&per_page=100&page="+page, nil, &batch)
+		if err != nil {
+			return nil, fmt.Errorf("error listing notes of merge request !%d: %w", number, err)
+		}
+		for _, n := range batch {
+			if n.System {
+				continue
+			}
+			comments = append(comments, &github.IssueComment{ID: github.Int64(n.ID), Body: github.String(n.Body)})
+		}
+		page = resp.Header.Get("X-Next-Page")
+	}
+	return comments, nil
+}
+
+// EditIssueComment replaces the body of a note on the merge request.
+func (c *Client) EditIssueComment(ctx context.Context, owner, repo string, number int, id int64, comment *github.IssueComment) (*github.IssueComment, error) {
+	var n note
+	path := mergeRequestPath(owner, repo, number) + "/notes/" + strconv.FormatInt(id, 10)
+	if err := c.send(ctx, http.MethodPut, path, map[string]string{"body": comment.GetBody()}, &n); err != nil {
+		return nil, fmt.Errorf("error updating note: %w", err)
+	}
+	return &github.IssueComment{ID: github.Int64(n.ID), Body: github.String(n.Body)}, nil
+}
+
+// GetPullRequestTemplate returns the default merge request template at ref, or an empty string if there is none.
+func (c *Client) GetPullRequestTemplate(ctx context.Context, owner, repo, ref string) (string, error) {
+	path := projectPath(owner, repo) + "/repository/files/" + url.PathEscape(TemplatePath) + "/raw?ref=" + url.QueryEscape(ref)
+	resp, err := c.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return "", fmt.Errorf("error getting merge request template: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	body, err := readBody(resp)
+	if err != nil {
+		return "", fmt.Errorf("error getting merge request template: %w", err)
+	}
+	return string(body), nil
+}
+
+func (c *Client) getChanges(ctx context.Context, owner, repo string, number int) (*changesResponse, error) {
+	key := fmt.Sprintf("%s/%s!%d", owner, repo, number)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if changes, ok := c.changes[key]; ok {
+		return changes, nil
+	}
+
+	// access_raw_diffs lifts the size limits of the diffs stored in the database
+	var changes changesResponse
+	if err := c.get(ctx, mergeRequestPath(owner, repo, number)+"/changes?access_raw_diffs=true", &changes); err != nil {
+		return nil, fmt.Errorf("error getting changes of merge request !%d: %w", number, err)
+	}
+	c.changes[key] = &changes
+	return &changes, nil
+}
+
+// listCommits returns the commits of the merge request, oldest first.
+func (c *Client) listCommits(ctx context.Context, owner, repo string, number int) ([]*github.RepositoryCommit, error) {
+	var commits []*github.RepositoryCommit
+	page := "1"
+	for page != "" {
+		var batch []commit
+		resp, err := c.doJSON(ctx, http.MethodGet, mergeRequestPath(owner, repo, number)+"/commits?per_page=100&page="+page, nil, &batch)
+		if err != nil {
+			return nil, fmt.Errorf("error listing commits of merge request !%d: %w", number, err)
+		}
+		for _, cm := range batch {
+			commits = append(commits, &github.RepositoryCommit{
+				SHA: github.String(cm.ID),
+				Commit: &github.Commit{
+					Message: github.String(cm.Message),
+					Author:  &github.CommitAuthor{Name: github.String(cm.AuthorName), Email: github.String(cm.AuthorEmail)},
+				},
+			})
+		}
+		page = resp.Header.Get("X-Next-Page")
+	}
+
+	// GitLab lists the newest commit first
+	for i, j := 0, len(commits)-1; i < j; i, j = i+1, j-1 {
+		commits[i], commits[j] = commits[j], commits[i]
+	}
+	return commits, nil
+}
+
+func (c *Client) get(ctx context.Context, path string, v interface{}) error {
+	_, err := c.doJSON(ctx, http.MethodGet, path, nil, v)
+	return err
+}
+
+func (c *Client) send(ctx context.Context, method, path string, payload, v interface{}) error {
+	_, err := c.doJSON(ctx, method, path, payload, v)
+	return err
+}
+
+func (c *Client) doJSON(ctx context.Context, method, path string, payload, v interface{}) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	resp, err := c.do(ctx, method, path, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := readBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(respBody, v); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+	return resp, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("PRIVATE-TOKEN", c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.httpClient.Do(req)
+}
+
+// readBody reads the response body and turns error statuses into errors.
+func readBody(resp *http.Response) ([]byte, error) {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var errResp errorResponse
+		if err := json.Unmarshal(body, &errResp); err == nil && (errResp.Message != nil || errResp.Error != "") {
+			message := errResp.Error
+			if errResp.Message != nil {
+				message = fmt.Sprint(errResp.Message)
+			}
+			return nil, fmt.Errorf("gitlab error, status code: %d, message: %s", resp.StatusCode, message)
+		}
+		return nil, fmt.Errorf("gitlab error, status code: %d", resp.StatusCode)
+	}
+	return body, nil
+}
+
+func projectPath(owner, repo string) string {
+	return "/projects/" + url.PathEscape(owner+"/"+repo)
+}
+
+func mergeRequestPath(owner, repo string, number int) string {
+	return projectPath(owner, repo) + "/merge_requests/" + strconv.Itoa(number)
+}
+
+func (mr *mergeRequest) pullRequest() *github.PullRequest {
+	state := "closed"
+	if mr.State == "opened" {
+		state = "open"
+	}
+	headSHA := mr.DiffRefs.HeadSHA
+	if headSHA == "" {
+		headSHA = mr.SHA
+	}
+
+	pr := &github.PullRequest{
+		Number:  github.Int(mr.IID),
+		Title:   github.String(mr.Title),
+		Body:    github.String(mr.Description),
+		State:   github.String(state),
+		HTMLURL: github.String(mr.WebURL),
+		Merged:  github.Bool(mr.State == "merged"),
+		User:    &github.User{Login: github.String(mr.Author.Username), Name: github.String(mr.Author.Name)},
+		Head:    &github.PullRequestBranch{Ref: github.String(mr.SourceBranch), SHA: github.String(headSHA)},
+		Base:    &github.PullRequestBranch{Ref: github.String(mr.TargetBranch), SHA: github.String(mr.DiffRefs.BaseSHA)},
+	}
+	if mr.MergedAt != nil {
+		pr.MergedAt = &github.Timestamp{Time: *mr.MergedAt}
+	}
+	if count, err := strconv.Atoi(mr.ChangesCount); err == nil {
+		pr.ChangedFiles = github.Int(count)
+	}
+	for _, label := range mr.Labels {
+		pr.Labels = append(pr.Labels, &github.Label{Name: github.String(label)})
+	}
+	return pr
+}
+
+func (ch *change) commitFile() *github.CommitFile {
+	status := "modified"
+	switch {
+	case ch.NewFile:
+		status = "added"
+	case ch.DeletedFile:
+		status = "removed"
+	case ch.RenamedFile:
+		status = "renamed"
+	}
+
+	file := &github.CommitFile{
+		Filename: github.String(ch.NewPath),
+		Status:   github.String(status),
+	}
+	if ch.RenamedFile {
+		file.PreviousFilename = github.String(ch.OldPath)
+	}
+
+	additions, deletions := 0, 0
+	// binary files have no hunks
+	if strings.HasPrefix(ch.Diff, "@@") {
+		patch := strings.TrimSuffix(ch.Diff, "\n")
+		file.Patch = github.String(patch)
+		for _, line := range strings.Split(patch, "\n") {
+			if strings.HasPrefix(line, "+") {
+				additions++
+			} else if strings.HasPrefix(line, "-") {
+				deletions++
+			}
+		}
+	}
+	file.Additions = github.Int(additions)
+	file.Deletions = github.Int(deletions)
+	file.Changes = github.Int(additions + deletions)
+	return file
+}
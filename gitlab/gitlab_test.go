@@ -0,0 +1,314 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v51/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ghClient "github.com/ravilushqa/gpt-pullrequest-updater/github"
+)
+
+const (
+	mrPath     = "/api/v4/projects/acme%2Fpayments%2Fapi/merge_requests/7"
+	mergeReqJS = `{
+		"iid": 7, "title": "Round totals", "description": "Body", "state": "merged", "web_url": "https://gitlab.example.com/acme/payments/api/-/merge_requests/7",
+		"source_branch": "feature/round", "target_branch": "main", "sha": "head", "merged_at": "2024-05-01T10:00:00Z",
+		"labels": ["backend"], "changes_count": "2", "author": {"username": "jane", "name": "Jane"},
+		"diff_refs": {"base_sha": "base", "head_sha": "head", "start_sha": "start"}
+	}`
+)
+
+// fakeGitLab serves a single merge request and records the requests that change it.
+type fakeGitLab struct {
+	t        *testing.T
+	overflow bool
+	requests map[string]map[string]interface{}
+}
+
+func (f *fakeGitLab) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	assert.Equal(f.t, "secret", r.Header.Get("PRIVATE-TOKEN"))
+
+	record := func() {
+		var payload map[string]interface{}
+		require.NoError(f.t, json.NewDecoder(r.Body).Decode(&payload))
+		f.requests[r.Method+" "+r.URL.EscapedPath()] = payload
+	}
+
+	switch r.Method + " " + r.URL.EscapedPath() {
+	case "GET " + mrPath:
+		_, _ = w.Write([]byte(mergeReqJS))
+	case "PUT " + mrPath:
+		record()
+		_, _ = w.Write([]byte(mergeReqJS))
+	case "GET " + mrPath + "/changes":
+		assert.Equal(f.t, "true", r.URL.Query().Get("access_raw_diffs"))
+		changes := `[
+			{"old_path": "round.go", "new_path": "round.go", "diff": "@@ -1,3 +1,3 @@\n package pay\n-var mode = 1\n+var mode = 2\n"},
+			{"old_path": "old.go", "new_path": "new.go", "renamed_file": true, "diff": ""},
+			{"old_path": "logo.png", "new_path": "logo.png", "new_file": true, "diff": "Binary files differ"}
+		]`
+		overflow := "false"
+		if f.overflow {
+			overflow = "true"
+		}
+		_, _ = w.Write([]byte(`{"iid": 7, "changes_count": "1000+", "overflow": ` + overflow + `, "diff_refs": {"base_sha": "base", "head_sha": "head", "start_sha": "start"}, "changes": ` + changes + `}`))
+	case "GET " + mrPath + "/commits":
+		if r.URL.Query().Get("page") == "2" {
+			_, _ = w.Write([]byte(`[{"id": "c1", "message": "Add rounding", "author_name": "Jane"}]`))
+			return
+		}
+		w.Header().Set("X-Next-Page", "2")
+		_, _ = w.Write([]byte(`[{"id": "c2", "message": "Fix tests", "author_name": "Jane"}]`))
+	case "POST " + mrPath + "/discussions":
+		record()
+		_, _ = w.Write([]byte(`{"id": "d1", "notes": [{"id": 11, "body": "[bug] Wrong mode"}]}`))
+	case "POST " + mrPath + "/notes":
+		record()
+		_, _ = w.Write([]byte(`{"id": 12, "body": "Summary"}`))
+	case "GET " + mrPath + "/notes":
+		if r.URL.Query().Get("page") == "2" {
+			_, _ = w.Write([]byte(`[{"id": 12, "body": "Summary"}]`))
+			return
+		}
+		w.Header().Set("X-Next-Page", "2")
+		_, _ = w.Write([]byte(`[{"id": 10, "body": "Looks good"}, {"id": 11, "body": "added 1 commit", "system": true}]`))
+	case "PUT " + mrPath + "/notes/12":
+		record()
+		_, _ = w.Write([]byte(`{"id": 12, "body": "New summary"}`))
+	case "GET /api/v4/projects/acme%2Fpayments%2Fapi/repository/files/.gitlab%2Fmerge_request_templates%2FDefault.md/raw":
+		if r.URL.Query().Get("ref") != "main" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message": "404 File Not Found"}`))
+			return
+		}
+		_, _ = w.Write([]byte("## What does this MR do?"))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"message": "404 Not Found"}`))
+	}
+}
+
+func newTestClient(t *testing.T, overflow bool) (*Client, *fakeGitLab) {
+	fake := &fakeGitLab{t: t, overflow: overflow, requests: map[string]map[string]interface{}{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(Config{BaseURL: server.URL + "/", Token: "secret"})
+	require.NoError(t, err)
+	return client, fake
+}
+
+func TestGetPullRequest(t *testing.T) {
+	client, _ := newTestClient(t, false)
+
+	pr, err := client.GetPullRequest(context.Background(), "acme/payments", "api", 7)
+	require.NoError(t, err)
+
+	assert.Equal(t, &github.PullRequest{
+		Number:       github.Int(7),
+		Title:        github.String("Round totals"),
+		Body:         github.String("Body"),
+		State:        github.String("closed"),
+		HTMLURL:      github.String("https://gitlab.example.com/acme/payments/api/-/merge_requests/7"),
+		Merged:       github.Bool(true),
+		MergedAt:     &github.Timestamp{Time: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)},
+		ChangedFiles: github.Int(2),
+		User:         &github.User{Login: github.String("jane"), Name: github.String("Jane")},
+		Head:         &github.PullRequestBranch{Ref: github.String("feature/round"), SHA: github.String("head")},
+		Base:         &github.PullRequestBranch{Ref: github.String("main"), SHA: github.String("base")},
+		Labels:       []*github.Label{{Name: github.String("backend")}},
+	}, pr)
+
+	_, err = client.GetPullRequest(context.Background(), "acme/payments", "api", 8)
+	assert.ErrorContains(t, err, "404 Not Found")
+}
+
+func TestGetPullRequestChanges(t *testing.T) {
+	testCases := []struct {
+		name     string
+		overflow bool
+		expected ghClient.Coverage
+	}{
+		{name: "Complete", expected: ghClient.Coverage{ChangedFiles: 3, AnalyzedFiles: 3}},
+		{name: "Overflow", overflow: true, expected: ghClient.Coverage{ChangedFiles: 1000, AnalyzedFiles: 3}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, _ := newTestClient(t, tc.overflow)
+
+			diff, coverage, err := client.GetPullRequestChanges(context.Background(), "acme/payments", "api", &github.PullRequest{Number: github.Int(7)})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, coverage)
+
+			require.Len(t, diff.Commits, 2)
+			assert.Equal(t, "c1", diff.Commits[0].GetSHA())
+			assert.Equal(t, "Fix tests", diff.Commits[1].GetCommit().GetMessage())
+
+			assert.Equal(t, []*github.CommitFile{
+				{Filename: github.String("round.go"), Status: github.String("modified"), Patch: github.String("@@ -1,3 +1,3 @@\n package pay\n-var mode = 1\n+var mode = 2"), Additions: github.Int(1), Deletions: github.Int(1), Changes: github.Int(2)},
+				{Filename: github.String("new.go"), PreviousFilename: github.String("old.go"), Status: github.String("renamed"), Additions: github.Int(0), Deletions: github.Int(0), Changes: github.Int(0)},
+				{Filename: github.String("logo.png"), Status: github.String("added"), Additions: github.Int(0), Deletions: github.Int(0), Changes: github.Int(0)},
+			}, diff.Files)
+		})
+	}
+}
+
+func TestUpdatePullRequest(t *testing.T) {
+	client, fake := newTestClient(t, false)
+
+	_, err := client.UpdatePullRequest(context.Background(), "acme/payments", "api", 7, &github.PullRequest{Body: github.String("New body")})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{"description": "New body"}, fake.requests["PUT "+mrPath])
+}
+
+func TestCreatePullRequestComment(t *testing.T) {
+	testCases := []struct {
+		name      string
+		path      string
+		position  int
+		expected  map[string]interface{}
+		expectErr bool
+	}{
+		{
+			name:     "Added line",
+			path:     "round.go",
+			position: 3,
+			expected: map[string]interface{}{
+				"position_type": "text", "base_sha": "base", "start_sha": "start", "head_sha": "head",
+				"old_path": "round.go", "new_path": "round.go", "new_line": float64(2),
+			},
+		},
+		{
+			name:     "Removed line",
+			path:     "round.go",
+			position: 2,
+			expected: map[string]interface{}{
+				"position_type": "text", "base_sha": "base", "start_sha": "start", "head_sha": "head",
+				"old_path": "round.go", "new_path": "round.go", "old_line": float64(2),
+			},
+		},
+		{
+			name:     "Context line",
+			path:     "round.go",
+			position: 1,
+			expected: map[string]interface{}{
+				"position_type": "text", "base_sha": "base", "start_sha": "start", "head_sha": "head",
+				"old_path": "round.go", "new_path": "round.go", "old_line": float64(1), "new_line": float64(1),
+			},
+		},
+		{name: "Outside of the patch", path: "round.go", position: 9, expectErr: true},
+		{name: "Unchanged file", path: "main.go", position: 1, expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, fake := newTestClient(t, false)
+
+			comment, err := client.CreatePullRequestComment(context.Background(), "acme/payments", "api", 7, &github.PullRequestComment{
+				Path:     github.String(tc.path),
+				Position: github.Int(tc.position),
+				Body:     github.String("[bug] Wrong mode"),
+			})
+			if tc.expectErr {
+				assert.Error(t, err)
+				assert.Empty(t, fake.requests)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, int64(11), comment.GetID())
+
+			payload := fake.requests["POST "+mrPath+"/discussions"]
+			assert.Equal(t, "[bug] Wrong mode", payload["body"])
+			assert.Equal(t, tc.expected, payload["position"])
+		})
+	}
+}
+
+func TestCreateIssueComment(t *testing.T) {
+	client, fake := newTestClient(t, false)
+
+	comment, err := client.CreateIssueComment(context.Background(), "acme/payments", "api", 7, &github.IssueComment{Body: github.String("Summary")})
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(12), comment.GetID())
+	assert.Equal(t, map[string]interface{}{"body": "Summary"}, fake.requests["POST "+mrPath+"/notes"])
+}
+
+func TestListIssueComments(t *testing.T) {
+	client, _ := newTestClient(t, false)
+
+	comments, err := client.ListIssueComments(context.Background(), "acme/payments", "api", 7)
+	require.NoError(t, err)
+
+	assert.Equal(t, []*github.IssueComment{
+		{ID: github.Int64(10), Body: github.String("Looks good")},
+		{ID: github.Int64(12), Body: github.String("Summary")},
+	}, comments)
+}
+
+func TestEditIssueComment(t *testing.T) {
+	client, fake := newTestClient(t, false)
+
+	comment, err := client.EditIssueComment(context.Background(), "acme/payments", "api", 7, 12, &github.IssueComment{Body: github.String("New summary")})
+	require.NoError(t, err)
+
+	assert.Equal(t, "New summary", comment.GetBody())
+	assert.Equal(t, map[string]interface{}{"body": "New summary"}, fake.requests["PUT "+mrPath+"/notes/12"])
+}
+
+func TestGetPullRequestTemplate(t *testing.T) {
+	client, _ := newTestClient(t, false)
+
+	template, err := client.GetPullRequestTemplate(context.Background(), "acme/payments", "api", "main")
+	require.NoError(t, err)
+	assert.Equal(t, "## What does this MR do?", template)
+
+	template, err = client.GetPullRequestTemplate(context.Background(), "acme/payments", "api", "release")
+	require.NoError(t, err)
+	assert.Empty(t, template)
+}
+
+func TestNewClient(t *testing.T) {
+	_, err := NewClient(Config{})
+	assert.Error(t, err)
+
+	client, err := NewClient(Config{Token: "secret"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://gitlab.com/api/v4", client.baseURL)
+
+	client, err = NewClient(Config{BaseURL: "https://gitlab.example.com/api/v4/", Token: "secret"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://gitlab.example.com/api/v4", client.baseURL)
+}
+
+func TestApplyCIEnv(t *testing.T) {
+	t.Setenv("GITLAB_CI", "true")
+	t.Setenv("CI_SERVER_URL", "https://gitlab.example.com")
+	t.Setenv("CI_PROJECT_NAMESPACE", "acme/payments")
+	t.Setenv("CI_PROJECT_NAME", "api")
+	t.Setenv("CI_MERGE_REQUEST_IID", "7")
+	// explicit configuration wins over the CI variables
+	t.Setenv("REPO", "other")
+	for _, name := range []string{"CODE_HOST", "GITLAB_URL", "OWNER", "PR_NUMBER"} {
+		t.Setenv(name, "")
+		require.NoError(t, os.Unsetenv(name))
+	}
+
+	ApplyCIEnv()
+
+	assert.Equal(t, "gitlab", os.Getenv("CODE_HOST"))
+	assert.Equal(t, "https://gitlab.example.com", os.Getenv("GITLAB_URL"))
+	assert.Equal(t, "acme/payments", os.Getenv("OWNER"))
+	assert.Equal(t, "other", os.Getenv("REPO"))
+	assert.Equal(t, "7", os.Getenv("PR_NUMBER"))
+}
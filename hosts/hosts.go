@@ -0,0 +1,70 @@
+// Package hosts creates the code host client selected on the command line.
+package hosts
+
+import (
+	"context"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/bitbucket"
+	"github.com/ravilushqa/gpt-pullrequest-updater/codehost"
+	ghClient "github.com/ravilushqa/gpt-pullrequest-updater/github"
+	"github.com/ravilushqa/gpt-pullrequest-updater/gitlab"
+)
+
+// GitHubOptions configure the GitHub client. Commands that only work on GitHub embed them in their flags.
+type GitHubOptions struct {
+	GithubToken             string `long:"gh-token" env:"GITHUB_TOKEN" description:"GitHub token. Not required when authenticating as a GitHub App"`
+	GithubAppID             int64  `long:"gh-app-id" env:"GITHUB_APP_ID" description:"GitHub App ID. Authenticates as the app instead of with --gh-token"`
+	GithubAppKey            string `long:"gh-app-private-key" env:"GITHUB_APP_PRIVATE_KEY" description:"PEM encoded private key of the GitHub App, or the path to it"`
+	GithubAppInstallationID int64  `long:"gh-app-installation-id" env:"GITHUB_APP_INSTALLATION_ID" description:"Installation ID of the GitHub App. Looked up from the repository when empty"`
+	GithubBaseURL           string `long:"gh-base-url" env:"GITHUB_BASE_URL" description:"GitHub Enterprise Server API URL. Example: https://github.example.com/api/v3/"`
+	GithubUploadURL         string `long:"gh-upload-url" env:"GITHUB_UPLOAD_URL" description:"GitHub Enterprise Server upload URL. Defaults to the host of --gh-base-url"`
+	GithubCABundle          string `long:"gh-ca-bundle" env:"GITHUB_CA_BUNDLE" description:"PEM file with additional CA certificates trusted for GitHub"`
+	GithubProxy             string `long:"gh-proxy" env:"GITHUB_PROXY" description:"HTTP proxy URL for GitHub. Defaults to the proxy environment variables"`
+}
+
+// Options select and configure the code host. Commands embed them in their flags.
+type Options struct {
+	GitHubOptions
+	Host              string `long:"host" env:"CODE_HOST" description:"Code host of the pull request" choice:"github" choice:"gitlab" choice:"bitbucket" choice:"bitbucket-datacenter" default:"github"`
+	GitlabURL         string `long:"gitlab-url" env:"GITLAB_URL" description:"GitLab URL" default:"https://gitlab.com"`
+	GitlabToken       string `long:"gitlab-token" env:"GITLAB_TOKEN" description:"GitLab access token with the api scope. Required for the gitlab host"`
+	BitbucketURL      string `long:"bitbucket-url" env:"BITBUCKET_URL" description:"Bitbucket API URL. Defaults to Bitbucket Cloud, required for the bitbucket-datacenter host"`
+	BitbucketUsername string `long:"bitbucket-username" env:"BITBUCKET_USERNAME" description:"Bitbucket username for app passwords. When empty, the token is sent as a bearer token"`
+	BitbucketToken    string `long:"bitbucket-token" env:"BITBUCKET_TOKEN" description:"Bitbucket app password or access token. Required for the bitbucket hosts"`
+}
+
+// Type returns the selected code host, GitHub when none is selected.
+func (o Options) Type() codehost.Type {
+	if o.Host == "" {
+		return codehost.TypeGitHub
+	}
+	return codehost.Type(o.Host)
+}
+
+// New creates the client of the code host selected by opts for the repository.
+func New(ctx context.Context, opts Options, owner, repo string) (codehost.Host, error) {
+	switch opts.Type() {
+	case codehost.TypeGitLab:
+		return gitlab.NewClient(gitlab.Config{BaseURL: opts.GitlabURL, Token: opts.GitlabToken})
+	case codehost.TypeBitbucket:
+		return bitbucket.NewCloud(bitbucket.Config{BaseURL: opts.BitbucketURL, Username: opts.BitbucketUsername, Token: opts.BitbucketToken})
+	case codehost.TypeBitbucketDataCenter:
+		return bitbucket.NewDataCenter(bitbucket.Config{BaseURL: opts.BitbucketURL, Username: opts.BitbucketUsername, Token: opts.BitbucketToken})
+	}
+
+	return NewGitHub(ctx, opts.GitHubOptions, owner, repo)
+}
+
+// NewGitHub creates the GitHub client configured by opts for the repository.
+func NewGitHub(ctx context.Context, opts GitHubOptions, owner, repo string) (*ghClient.Client, error) {
+	return ghClient.NewClientWithConfig(ctx, ghClient.Config{
+		Token:          opts.GithubToken,
+		AppID:          opts.GithubAppID,
+		PrivateKey:     opts.GithubAppKey,
+		InstallationID: opts.GithubAppInstallationID,
+		BaseURL:        opts.GithubBaseURL,
+		UploadURL:      opts.GithubUploadURL,
+		CABundle:       opts.GithubCABundle,
+		Proxy:          opts.GithubProxy,
+	}, owner, repo)
+}
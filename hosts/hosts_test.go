@@ -0,0 +1,58 @@
+package hosts
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/bitbucket"
+	"github.com/ravilushqa/gpt-pullrequest-updater/codehost"
+	ghClient "github.com/ravilushqa/gpt-pullrequest-updater/github"
+	"github.com/ravilushqa/gpt-pullrequest-updater/gitlab"
+)
+
+func TestNew(t *testing.T) {
+	testCases := []struct {
+		name        string
+		opts        Options
+		expected    codehost.Host
+		expectError bool
+	}{
+		{name: "GitHub", opts: Options{GitHubOptions: GitHubOptions{GithubToken: "token"}}, expected: &ghClient.Client{}},
+		{name: "GitHub without credentials", opts: Options{Host: "github"}, expectError: true},
+		{name: "GitLab", opts: Options{Host: "gitlab", GitlabURL: "https://gitlab.com", GitlabToken: "token"}, expected: &gitlab.Client{}},
+		{name: "Bitbucket Cloud", opts: Options{Host: "bitbucket", BitbucketToken: "token"}, expected: &bitbucket.Cloud{}},
+		{name: "Bitbucket Data Center", opts: Options{Host: "bitbucket-datacenter", BitbucketURL: "https://bitbucket.example.com", BitbucketToken: "token"}, expected: &bitbucket.DataCenter{}},
+		{name: "Bitbucket Data Center without URL", opts: Options{Host: "bitbucket-datacenter", BitbucketToken: "token"}, expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			host, err := New(context.Background(), tc.opts, "owner", "repo")
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.IsType(t, tc.expected, host)
+		})
+	}
+}
+
+func TestOptionsFlags(t *testing.T) {
+	var opts struct {
+		Options
+		Owner string `long:"owner"`
+	}
+	_, err := flags.ParseArgs(&opts, []string{"--host=gitlab", "--gitlab-token=token", "--gh-token=gh-token", "--owner=acme"})
+	require.NoError(t, err)
+
+	assert.Equal(t, codehost.TypeGitLab, opts.Type())
+	assert.Equal(t, "token", opts.GitlabToken)
+	assert.Equal(t, "https://gitlab.com", opts.GitlabURL)
+	assert.Equal(t, "gh-token", opts.GithubToken)
+	assert.Equal(t, "acme", opts.Owner)
+}
@@ -0,0 +1,269 @@
+package jira
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/criteria"
+)
+
+// Config configures a Client.
+type Config struct {
+	// BaseURL is the Jira URL. Example: https://example.atlassian.net
+	BaseURL string
+	// Email of the Jira Cloud account the API token belongs to. When empty, Token is used as a personal
+	// access token of Jira Server or Data Center.
+	Email string
+	Token string
+	// AcceptanceCriteriaField is the ID of the custom field holding the acceptance criteria, e.g.
+	// customfield_10100. When empty, they are taken from the "Acceptance criteria" section of the
+	// description.
+	AcceptanceCriteriaField string
+	HTTPClient              *http.Client
+}
+
+// Client reads and updates issues through the Jira REST API version 2, which Jira Cloud, Server and Data Center
+// all support.
+type Client struct {
+	httpClient              *http.Client
+	baseURL                 string
+	email                   string
+	token                   string
+	acceptanceCriteriaField string
+}
+
+// Issue is a Jira issue with the fields used to describe pull requests.
+type Issue struct {
+	Key                string
+	Summary            string
+	Description        string
+	AcceptanceCriteria []string
+}
+
+func NewClient(cfg Config) (*Client, error) {
+	if cfg.BaseURL == "" {
+		return nil, errors.New("jira url is required")
+	}
+	if cfg.Token == "" {
+		return nil, errors.New("jira token is required")
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{}
+	}
+
+	return &Client{
+		httpClient:              cfg.HTTPClient,
+		baseURL:                 strings.TrimSuffix(cfg.BaseURL, "/"),
+		email:                   cfg.Email,
+		token:                   cfg.Token,
+		acceptanceCriteriaField: cfg.AcceptanceCriteriaField,
+	}, nil
+}
+
+type issueResponse struct {
+	Key    string                     `json:"key"`
+	Fields map[string]json.RawMessage `json:"fields"`
+}
+
+type errorResponse struct {
+	ErrorMessages []string `json:"errorMessages"`
+}
+
+// GetIssue fetches the issue with the given key.
+func (c *Client) GetIssue(ctx context.Context, key string) (*Issue, error) {
+	fields := []string{"summary", "description"}
+	if c.acceptanceCriteriaField != "" {
+		fields = append(fields, c.acceptanceCriteriaField)
+	}
+	query := url.Values{"fields": {strings.Join(fields, ",")}}
+
+	var resp issueResponse
+	if err := c.get(ctx, "/rest/api/2/issue/"+url.PathEscape(key)+"?"+query.Encode(), &resp); err != nil {
+		return nil, fmt.Errorf("error getting issue %s: %w", key, err)
+	}
+
+	issue := &Issue{
+		Key:         resp.Key,
+		Summary:     stringField(resp.Fields["summary"]),
+		Description: stringField(resp.Fields["description"]),
+	}
+	if c.acceptanceCriteriaField != "" {
+		issue.AcceptanceCriteria = criteria.Items(stringField(resp.Fields[c.acceptanceCriteriaField]))
+	} else {
+		issue.AcceptanceCriteria = criteria.FromDescription(issue.Description)
+	}
+
+	return issue, nil
+}
+
+func (c *Client) get(ctx context.Context, path string, v interface{}) error {
+	return c.do(ctx, http.MethodGet, path, nil, v)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body io.Reader, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.email != "" {
+		req.SetBasicAuth(c.email, c.token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var errResp errorResponse
+		if err := json.Unmarshal(respBody, &errResp); err == nil && len(errResp.ErrorMessages) > 0 {
+			return fmt.Errorf("jira error, status code: %d, message: %s", resp.StatusCode, strings.Join(errResp.ErrorMessages, "; "))
+		}
+		return fmt.Errorf("jira error, status code: %d", resp.StatusCode)
+	}
+
+	if v == nil || len(respBody) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, v); err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return nil
+}
+
+// stringField returns the text of a string field. Other values, e.g. rich text of the version 3 API,
+// are ignored.
+func stringField(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(s)
+}
+
+// Comment is a comment of a Jira issue.
+type Comment struct {
+	ID   string `json:"id"`
+	Body string `json:"body"`
+}
+
+type commentsResponse struct {
+	StartAt    int       `json:"startAt"`
+	MaxResults int       `json:"maxResults"`
+	Total      int       `json:"total"`
+	Comments   []Comment `json:"comments"`
+}
+
+// ListComments returns all comments of the issue.
+func (c *Client) ListComments(ctx context.Context, key string) ([]Comment, error) {
+	var comments []Comment
+	for {
+		query := url.Values{"startAt": {strconv.Itoa(len(comments))}, "maxResults": {"100"}}
+		var resp commentsResponse
+		if err := c.get(ctx, "/rest/api/2/issue/"+url.PathEscape(key)+"/comment?"+query.Encode(), &resp); err != nil {
+			return nil, fmt.Errorf("error listing comments of %s: %w", key, err)
+		}
+		comments = append(comments, resp.Comments...)
+		if len(resp.Comments) == 0 || len(comments) >= resp.Total {
+			return comments, nil
+		}
+	}
+}
+
+// CommentMarker identifies the comment posted for a pull request, so that later runs update it instead of a
+// comment that merely links the pull request. The anchor macro renders as nothing.
+func CommentMarker(owner, repo string, number int) string {
+	return fmt.Sprintf("{anchor:gpt-pr-updater-%s-%s-%d}", owner, repo, number)
+}
+
+// UpsertComment adds the comment to the issue, or updates the existing comment containing marker so that
+// re-runs do not add the same comment again.
+func (c *Client) UpsertComment(ctx context.Context, key, marker, body string) error {
+	comments, err := c.ListComments(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	payload := Comment{Body: body}
+	for _, comment := range comments {
+		if !strings.Contains(comment.Body, marker) {
+			continue
+		}
+		if comment.Body == body {
+			return nil
+		}
+		path := "/rest/api/2/issue/" + url.PathEscape(key) + "/comment/" + url.PathEscape(comment.ID)
+		if err := c.send(ctx, http.MethodPut, path, payload, nil); err != nil {
+			return fmt.Errorf("error updating comment of %s: %w", key, err)
+		}
+		return nil
+	}
+
+	if err := c.send(ctx, http.MethodPost, "/rest/api/2/issue/"+url.PathEscape(key)+"/comment", payload, nil); err != nil {
+		return fmt.Errorf("error adding comment to %s: %w", key, err)
+	}
+	return nil
+}
+
+type transitionsResponse struct {
+	Transitions []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+		To   struct {
+			Name string `json:"name"`
+		} `json:"to"`
+	} `json:"transitions"`
+}
+
+// Transition moves the issue through the workflow transition with the given name or target status.
+// It returns false when no such transition is available, e.g. because the issue already is in that status.
+func (c *Client) Transition(ctx context.Context, key, name string) (bool, error) {
+	path := "/rest/api/2/issue/" + url.PathEscape(key) + "/transitions"
+
+	var resp transitionsResponse
+	if err := c.get(ctx, path, &resp); err != nil {
+		return false, fmt.Errorf("error getting transitions of %s: %w", key, err)
+	}
+
+	for _, t := range resp.Transitions {
+		if !strings.EqualFold(t.Name, name) && !strings.EqualFold(t.To.Name, name) {
+			continue
+		}
+		payload := map[string]interface{}{"transition": map[string]string{"id": t.ID}}
+		if err := c.send(ctx, http.MethodPost, path, payload, nil); err != nil {
+			return false, fmt.Errorf("error transitioning %s: %w", key, err)
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (c *Client) send(ctx context.Context, method, path string, payload, v interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error encoding request: %w", err)
+	}
+	return c.do(ctx, method, path, bytes.NewReader(body), v)
+}
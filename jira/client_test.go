@@ -0,0 +1,196 @@
+package jira
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T, handler http.HandlerFunc) string {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestGetIssue(t *testing.T) {
+	testCases := []struct {
+		name          string
+		cfg           Config
+		response      string
+		expectedAuth  func(t *testing.T, r *http.Request)
+		expectedField string
+		expected      *Issue
+	}{
+		{
+			name:     "Cloud with criteria in the description",
+			cfg:      Config{Email: "bot@example.com", Token: "api-token"},
+			response: `{"key": "PAY-1", "fields": {"summary": "Round totals", "description": "Totals are off.\n\nh3. Acceptance Criteria\n* Totals are rounded half up\n* Rounding is logged\n\nh3. Notes\n* not a criterion"}}`,
+			expectedAuth: func(t *testing.T, r *http.Request) {
+				user, password, ok := r.BasicAuth()
+				assert.True(t, ok)
+				assert.Equal(t, "bot@example.com", user)
+				assert.Equal(t, "api-token", password)
+			},
+			expectedField: "summary,description",
+			expected: &Issue{
+				Key:                "PAY-1",
+				Summary:            "Round totals",
+				Description:        "Totals are off.\n\nh3. Acceptance Criteria\n* Totals are rounded half up\n* Rounding is logged\n\nh3. Notes\n* not a criterion",
+				AcceptanceCriteria: []string{"Totals are rounded half up", "Rounding is logged"},
+			},
+		},
+		{
+			name:     "Server with a custom field",
+			cfg:      Config{Token: "pat", AcceptanceCriteriaField: "customfield_10100"},
+			response: `{"key": "OPS-7", "fields": {"summary": "Retry", "description": null, "customfield_10100": "Failed payments are retried\nRetries are capped"}}`,
+			expectedAuth: func(t *testing.T, r *http.Request) {
+				assert.Equal(t, "Bearer pat", r.Header.Get("Authorization"))
+			},
+			expectedField: "summary,description,customfield_10100",
+			expected: &Issue{
+				Key:                "OPS-7",
+				Summary:            "Retry",
+				AcceptanceCriteria: []string{"Failed payments are retried", "Retries are capped"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.cfg.BaseURL = newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/rest/api/2/issue/"+tc.expected.Key, r.URL.Path)
+				assert.Equal(t, tc.expectedField, r.URL.Query().Get("fields"))
+				tc.expectedAuth(t, r)
+				_, _ = w.Write([]byte(tc.response))
+			})
+			client, err := NewClient(tc.cfg)
+			require.NoError(t, err)
+
+			issue, err := client.GetIssue(context.Background(), tc.expected.Key)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, issue)
+		})
+	}
+}
+
+func TestGetIssueError(t *testing.T) {
+	baseURL := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"errorMessages": ["Issue does not exist or you do not have permission to see it."]}`))
+	})
+	client, err := NewClient(Config{BaseURL: baseURL, Token: "pat"})
+	require.NoError(t, err)
+
+	_, err = client.GetIssue(context.Background(), "PAY-404")
+	assert.ErrorContains(t, err, "status code: 404, message: Issue does not exist")
+}
+
+// fakeJira keeps the comments of a single issue.
+type fakeJira struct {
+	comments    []Comment
+	transitions []string
+	requests    []string
+}
+
+func (f *fakeJira) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/rest/api/2/issue/PAY-1/comment":
+		// one comment per page to exercise pagination
+		start, _ := strconv.Atoi(r.URL.Query().Get("startAt"))
+		page := f.comments[start:]
+		if len(page) > 1 {
+			page = page[:1]
+		}
+		_ = json.NewEncoder(w).Encode(commentsResponse{StartAt: start, Total: len(f.comments), Comments: page})
+	case r.Method == http.MethodPost && r.URL.Path == "/rest/api/2/issue/PAY-1/comment":
+		var c Comment
+		_ = json.NewDecoder(r.Body).Decode(&c)
+		c.ID = strconv.Itoa(len(f.comments) + 1)
+		f.comments = append(f.comments, c)
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/rest/api/2/issue/PAY-1/comment/"):
+		var c Comment
+		_ = json.NewDecoder(r.Body).Decode(&c)
+		id := strings.TrimPrefix(r.URL.Path, "/rest/api/2/issue/PAY-1/comment/")
+		for i := range f.comments {
+			if f.comments[i].ID == id {
+				f.comments[i].Body = c.Body
+			}
+		}
+	case r.Method == http.MethodGet && r.URL.Path == "/rest/api/2/issue/PAY-1/transitions":
+		_, _ = w.Write([]byte(`{"transitions": [{"id": "21", "name": "Start review", "to": {"name": "In Review"}}, {"id": "31", "name": "Done", "to": {"name": "Done"}}]}`))
+	case r.Method == http.MethodPost && r.URL.Path == "/rest/api/2/issue/PAY-1/transitions":
+		var payload struct {
+			Transition struct {
+				ID string `json:"id"`
+			} `json:"transition"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		f.transitions = append(f.transitions, payload.Transition.ID)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestUpsertComment(t *testing.T) {
+	// a human comment linking the pull request is left alone
+	human := Comment{ID: "1", Body: "Looks good, see https://github.com/owner/repo/pull/1"}
+	fake := &fakeJira{comments: []Comment{human}}
+	client, err := NewClient(Config{BaseURL: newTestServer(t, fake.ServeHTTP), Token: "pat"})
+	require.NoError(t, err)
+	ctx := context.Background()
+	marker := CommentMarker("owner", "repo", 1)
+
+	require.NoError(t, client.UpsertComment(ctx, "PAY-1", marker, "Summary v1\n"+marker))
+	require.NoError(t, client.UpsertComment(ctx, "PAY-1", marker, "Summary v1\n"+marker))
+	require.NoError(t, client.UpsertComment(ctx, "PAY-1", marker, "Summary v2\n"+marker))
+
+	assert.Equal(t, []Comment{human, {ID: "2", Body: "Summary v2\n" + marker}}, fake.comments)
+	var writes []string
+	for _, r := range fake.requests {
+		if !strings.HasPrefix(r, http.MethodGet) {
+			writes = append(writes, r)
+		}
+	}
+	assert.Equal(t, []string{"POST /rest/api/2/issue/PAY-1/comment", "PUT /rest/api/2/issue/PAY-1/comment/2"}, writes)
+}
+
+func TestCommentMarker(t *testing.T) {
+	assert.Equal(t, "{anchor:gpt-pr-updater-owner-repo-1}", CommentMarker("owner", "repo", 1))
+	assert.NotEqual(t, CommentMarker("owner", "repo", 1), CommentMarker("owner", "repo", 12))
+}
+
+func TestTransition(t *testing.T) {
+	testCases := []struct {
+		name        string
+		transition  string
+		expected    bool
+		transitions []string
+	}{
+		{name: "By status", transition: "in review", expected: true, transitions: []string{"21"}},
+		{name: "By name", transition: "Done", expected: true, transitions: []string{"31"}},
+		{name: "Not available", transition: "Closed", expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := &fakeJira{}
+			client, err := NewClient(Config{BaseURL: newTestServer(t, fake.ServeHTTP), Token: "pat"})
+			require.NoError(t, err)
+
+			ok, err := client.Transition(context.Background(), "PAY-1", tc.transition)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, ok)
+			assert.Equal(t, tc.transitions, fake.transitions)
+		})
+	}
+}
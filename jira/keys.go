@@ -0,0 +1,96 @@
+package jira
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/v51/github"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/marker"
+)
+
+// keyPattern matches an issue key (e.g. PROJ-123) that is not part of a longer word or number. Project keys may
+// contain underscores, e.g. MY_PROJ-1.
+var keyPattern = regexp.MustCompile(`(?:^|[^A-Za-z0-9_])([A-Za-z][A-Za-z0-9_]*-[0-9]+)(?:[^0-9]|$)`)
+
+// Extractor finds issue keys in pull requests.
+type Extractor struct {
+	projects map[string]bool
+}
+
+// NewExtractor creates an Extractor that accepts only keys of the given projects. All projects are
+// accepted when the list is empty.
+func NewExtractor(projects []string) *Extractor {
+	e := &Extractor{projects: map[string]bool{}}
+	for _, p := range projects {
+		if p = strings.ToUpper(strings.TrimSpace(p)); p != "" {
+			e.projects[p] = true
+		}
+	}
+	return e
+}
+
+// FromPullRequest returns the keys found in the title, head branch, body and commit messages of the
+// pull request, uppercased and deduplicated in the order they were found.
+// Only uppercase keys are taken, which leaves out words like utf-8, x/net-0.17.0 and add-2fa. With a project
+// allowlist the title and branch are matched case-insensitively, since branch names are often lowercase.
+func (e *Extractor) FromPullRequest(pr *github.PullRequest, commits []*github.RepositoryCommit) []string {
+	var keys []string
+	seen := map[string]bool{}
+	add := func(text string, anyCase bool) {
+		for _, key := range e.find(text, anyCase) {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	anyCase := len(e.projects) > 0
+	add(pr.GetTitle(), anyCase)
+	add(pr.GetHead().GetRef(), anyCase)
+	// keys in the generated section may be stale
+	add(marker.StripGenerated(pr.GetBody()), false)
+	for _, c := range commits {
+		add(c.GetCommit().GetMessage(), false)
+	}
+
+	return keys
+}
+
+func (e *Extractor) find(s string, anyCase bool) []string {
+	var keys []string
+	// the match may consume the character before the next key, so search again from the key end
+	for s != "" {
+		loc := keyPattern.FindStringSubmatchIndex(s)
+		if loc == nil {
+			break
+		}
+		match := s[loc[2]:loc[3]]
+		s = s[loc[3]:]
+
+		if key, ok := e.key(match, anyCase); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// key returns the uppercased key in match. A key may be joined to a lowercase word before it with an underscore,
+// e.g. fix_PAY-1, so when match as a whole is not a key, what follows each underscore is tried. Uppercase matches
+// such as MY_PROJ-1 are never split.
+func (e *Extractor) key(match string, anyCase bool) (string, bool) {
+	for {
+		upper := strings.ToUpper(match)
+		if c := match[0]; 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' {
+			if (anyCase || upper == match) && (len(e.projects) == 0 || e.projects[upper[:strings.LastIndex(upper, "-")]]) {
+				return upper, true
+			}
+		}
+		i := strings.Index(match, "_")
+		if upper == match || i < 0 {
+			return "", false
+		}
+		match = match[i+1:]
+	}
+}
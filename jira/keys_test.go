@@ -0,0 +1,109 @@
+package jira
+
+import (
+	"testing"
+
+	"github.com/google/go-github/v51/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractorFromPullRequest(t *testing.T) {
+	pr := &github.PullRequest{
+		Title: github.String("[Pay-123] Fix rounding"),
+		Head:  &github.PullRequestBranch{Ref: github.String("feature/ops-7-retry_PAY-123")},
+		Body:  github.String("Also fixes CORE-9 and PAY-124. Encoding is utf-8, see UTF-8.\n<!-- gpt:start -->\nOLD-1\n<!-- gpt:end -->"),
+	}
+	commits := []*github.RepositoryCommit{
+		{Commit: &github.Commit{Message: github.String("Retry payments\n\nRefs OPS-7, CORE-10")}},
+		{Commit: &github.Commit{Message: github.String("Bump sha256-1 fixture, x_PAY-125")}},
+	}
+
+	testCases := []struct {
+		name     string
+		projects []string
+		expected []string
+	}{
+		{
+			name:     "All projects",
+			expected: []string{"PAY-123", "CORE-9", "PAY-124", "UTF-8", "OPS-7", "CORE-10", "PAY-125"},
+		},
+		{
+			name:     "Allowlist",
+			projects: []string{"pay", " OPS "},
+			expected: []string{"PAY-123", "OPS-7", "PAY-124", "PAY-125"},
+		},
+		{
+			name:     "No matches",
+			projects: []string{"NONE"},
+			expected: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, NewExtractor(tc.projects).FromPullRequest(pr, commits))
+		})
+	}
+}
+
+func TestExtractorIgnoresLowercaseWithoutAllowlist(t *testing.T) {
+	testCases := []struct {
+		name   string
+		title  string
+		branch string
+	}{
+		{name: "Dependabot", title: "Bump golang.org/x/net from 0.7.0 to 0.17.0", branch: "dependabot/go_modules/golang.org/x/net-0.17.0"},
+		{name: "Feature", title: "Add 2FA", branch: "feature/add-2fa"},
+		{name: "Version", title: "Upgrade to python-3.11", branch: "chore/python-3.11"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pr := &github.PullRequest{Title: github.String(tc.title), Head: &github.PullRequestBranch{Ref: github.String(tc.branch)}}
+			assert.Empty(t, NewExtractor(nil).FromPullRequest(pr, nil))
+		})
+	}
+}
+
+func TestKeyPattern(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []string
+	}{
+		{input: "PROJ-123", expected: []string{"PROJ-123"}},
+		{input: "[PROJ-1][PROJ-2]", expected: []string{"PROJ-1", "PROJ-2"}},
+		{input: "`PROJ-1`", expected: []string{"PROJ-1"}},
+		{input: "feature/pay-123-foo", expected: []string{"PAY-123"}},
+		{input: "A1B-22x", expected: []string{"A1B-22"}},
+		{input: "PROJ-1a", expected: []string{"PROJ-1"}},
+		{input: "1PROJ-1 ^-1 _-1", expected: nil},
+		{input: "MY_PROJ-1", expected: []string{"MY_PROJ-1"}},
+		{input: "no keys here", expected: nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			assert.Equal(t, tc.expected, NewExtractor(nil).find(tc.input, true))
+		})
+	}
+}
+
+func TestFindUppercase(t *testing.T) {
+	testCases := []struct {
+		input    string
+		projects []string
+		expected []string
+	}{
+		{input: "MY_PROJ-1", expected: []string{"MY_PROJ-1"}},
+		{input: "fix_PAY-1", expected: []string{"PAY-1"}},
+		{input: "fix_my_PROJ-1", expected: []string{"PROJ-1"}},
+		{input: "MY_PROJ-1", projects: []string{"PROJ"}, expected: nil},
+		{input: "Pay-1 x/net-0.17.0", expected: nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			assert.Equal(t, tc.expected, NewExtractor(tc.projects).find(tc.input, false))
+		})
+	}
+}
@@ -0,0 +1,31 @@
+// Package logs prints the progress of a run. The logs go to stdout unless a command sends them elsewhere,
+// e.g. to stderr when stdout is kept for a JSON result.
+package logs
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+var output io.Writer = os.Stdout
+
+// SetOutput sets where the logs are written.
+func SetOutput(w io.Writer) {
+	output = w
+}
+
+// Print formats like fmt.Print and writes to the logs.
+func Print(a ...interface{}) {
+	_, _ = fmt.Fprint(output, a...)
+}
+
+// Println formats like fmt.Println and writes to the logs.
+func Println(a ...interface{}) {
+	_, _ = fmt.Fprintln(output, a...)
+}
+
+// Printf formats like fmt.Printf and writes to the logs.
+func Printf(format string, a ...interface{}) {
+	_, _ = fmt.Fprintf(output, format, a...)
+}
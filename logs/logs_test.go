@@ -0,0 +1,21 @@
+package logs
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetOutput(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	defer SetOutput(os.Stdout)
+
+	Print("a", "b")
+	Println("c", 1)
+	Printf("%s=%d\n", "d", 2)
+
+	assert.Equal(t, "abc 1\nd=2\n", buf.String())
+}
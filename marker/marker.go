@@ -0,0 +1,70 @@
+// Package marker finds the hidden markers the commands leave in pull request bodies.
+package marker
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Markers surrounding the generated part of a pull request body.
+const (
+	Start = "<!-- gpt:start -->"
+	End   = "<!-- gpt:end -->"
+)
+
+var diffHashRe = regexp.MustCompile(`<!-- gpt:diff-hash:([0-9a-f]+) -->`)
+
+// Section returns the bounds of the first well-formed generated section of body, markers included.
+// A stray start marker never extends the section over author content before it.
+func Section(body string) (int, int, bool) {
+	offset := 0
+	for {
+		i := strings.Index(body[offset:], End)
+		if i < 0 {
+			return 0, 0, false
+		}
+		end := offset + i
+		if start := strings.LastIndex(body[offset:end], Start); start >= 0 {
+			return offset + start, end + len(End), true
+		}
+		offset = end + len(End)
+	}
+}
+
+// StripGenerated returns body without the generated section, i.e. only the content written by the author.
+func StripGenerated(body string) string {
+	start, end, ok := Section(body)
+	if !ok {
+		return body
+	}
+	return strings.TrimSpace(body[:start] + body[end:])
+}
+
+// ExtractGenerated returns the generated section of body without the markers, or an empty string if there is none.
+func ExtractGenerated(body string) string {
+	start, end, ok := Section(body)
+	if !ok {
+		return ""
+	}
+	section := body[start+len(Start) : end-len(End)]
+	return strings.TrimSpace(diffHashRe.ReplaceAllString(section, ""))
+}
+
+// DiffHash returns the hidden marker storing hash in the generated section.
+func DiffHash(hash string) string {
+	return fmt.Sprintf("<!-- gpt:diff-hash:%s -->", hash)
+}
+
+// ExtractDiffHash returns the diff hash stored in the generated section of body, if any.
+func ExtractDiffHash(body string) string {
+	start, end, ok := Section(body)
+	if !ok {
+		return ""
+	}
+	m := diffHashRe.FindStringSubmatch(body[start:end])
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
@@ -0,0 +1,92 @@
+package marker
+
+import (
+	"testing"
+)
+
+func TestSection(t *testing.T) {
+	testCases := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{
+			name:     "Section",
+			body:     "notes\n<!-- gpt:start -->\ngenerated\n<!-- gpt:end -->\nmore",
+			expected: "<!-- gpt:start -->\ngenerated\n<!-- gpt:end -->",
+		},
+		{
+			name:     "Stray start marker",
+			body:     "<!-- gpt:start -->\nnotes\n<!-- gpt:start -->\ngenerated\n<!-- gpt:end -->",
+			expected: "<!-- gpt:start -->\ngenerated\n<!-- gpt:end -->",
+		},
+		{
+			name: "Stray end marker",
+			body: "notes\n<!-- gpt:end -->",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			start, end, ok := Section(tc.body)
+			if ok != (tc.expected != "") {
+				t.Fatalf("expected found %v, but got %v", tc.expected != "", ok)
+			}
+			if ok && tc.body[start:end] != tc.expected {
+				t.Errorf("expected section %q, but got %q", tc.expected, tc.body[start:end])
+			}
+		})
+	}
+}
+
+func TestStripGenerated(t *testing.T) {
+	body := "notes\n\n<!-- gpt:start -->\ngenerated\n<!-- gpt:end -->"
+	if result := StripGenerated(body); result != "notes" {
+		t.Errorf("expected result %q, but got %q", "notes", result)
+	}
+	if result := StripGenerated("notes"); result != "notes" {
+		t.Errorf("expected result %q, but got %q", "notes", result)
+	}
+}
+
+func TestExtractDiffHash(t *testing.T) {
+	testCases := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{
+			name:     "Hash in generated section",
+			body:     "notes\n\n" + Start + "\ngenerated\n" + DiffHash("abc123") + "\n" + End,
+			expected: "abc123",
+		},
+		{
+			name:     "Hash outside generated section",
+			body:     DiffHash("abc123") + "\n" + Start + "\ngenerated\n" + End,
+			expected: "",
+		},
+		{
+			name:     "No generated section",
+			body:     "notes",
+			expected: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := ExtractDiffHash(tc.body); result != tc.expected {
+				t.Errorf("expected result %q, but got %q", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestExtractGenerated(t *testing.T) {
+	body := "notes\n\n" + Start + "\ngenerated\n" + DiffHash("abc123") + "\n" + End
+	if result := ExtractGenerated(body); result != "generated" {
+		t.Errorf("expected result %q, but got %q", "generated", result)
+	}
+	if result := ExtractGenerated("notes"); result != "" {
+		t.Errorf("expected empty result, but got %q", result)
+	}
+}
@@ -5,9 +5,14 @@ import (
 	_ "embed"
 	"errors"
 	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/sashabaranov/go-openai"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/cache"
+	"github.com/ravilushqa/gpt-pullrequest-updater/logs"
+	"github.com/ravilushqa/gpt-pullrequest-updater/usage"
 )
 
 //go:embed prompts/review
@@ -19,25 +24,226 @@ var PromptDescribeChanges string
 //go:embed prompts/describe_overall
 var PromptDescribeOverall string
 
+//go:embed prompts/describe_group
+var PromptDescribeGroup string
+
+//go:embed prompts/describe_template
+var PromptDescribeTemplate string
+
+//go:embed prompts/title
+var PromptTitle string
+
+//go:embed prompts/squash_commit
+var PromptSquashCommit string
+
+//go:embed prompts/changelog
+var PromptChangelog string
+
+//go:embed prompts/criteria_coverage
+var PromptCriteriaCoverage string
+
+//go:embed prompts/release_item
+var PromptReleaseItem string
+
+//go:embed prompts/release_overall
+var PromptReleaseOverall string
+
+// Provider is a kind of OpenAI-compatible API the client talks to.
+type Provider string
+
+const (
+	// ProviderOpenAI is the hosted OpenAI API.
+	ProviderOpenAI Provider = "openai"
+	// ProviderLocal is an OpenAI-compatible local server such as Ollama, vLLM or llama.cpp.
+	ProviderLocal Provider = "local"
+)
+
+const (
+	// DefaultModel is used for the hosted OpenAI API when no model is given.
+	DefaultModel = "gpt-3.5-turbo"
+	// DefaultLocalBaseURL is the Ollama OpenAI-compatible endpoint.
+	DefaultLocalBaseURL = "http://localhost:11434/v1"
+	// DefaultMaxPromptLength is the prompt length in characters used to chunk patches.
+	DefaultMaxPromptLength = 4096
+	// DefaultLocalMaxPromptLength is smaller because local models usually run with a 2k token context.
+	DefaultLocalMaxPromptLength = 2048
+
+	temperature = 0.1
+	// finishReasonLength is reported when the completion was cut off at the token limit.
+	finishReasonLength = "length"
+)
+
+// Config configures a Client.
+type Config struct {
+	Provider Provider
+	Token    string
+	Model    string
+	BaseURL  string
+	// MaxPromptLength is the maximum prompt length in characters. Zero means the provider default.
+	MaxPromptLength int
+	HTTPClient      *http.Client
+	// Tracker collects the token usage of every call. Optional.
+	Tracker *usage.Tracker
+	// Budget caps the tokens and cost of the run. Optional.
+	Budget *usage.Budget
+	// Cache stores completions on disk. Optional.
+	Cache *cache.Cache
+}
+
+// PromptLimiter is implemented by clients that know the context window of their model.
+type PromptLimiter interface {
+	MaxPromptLength() int
+}
+
+// MaxPromptLength returns the prompt length supported by c, or DefaultMaxPromptLength when it is unknown.
+func MaxPromptLength(c interface{}) int {
+	if l, ok := c.(PromptLimiter); ok && l.MaxPromptLength() > 0 {
+		return l.MaxPromptLength()
+	}
+	return DefaultMaxPromptLength
+}
+
+// PromptRoom returns how many characters of content fit in the prompt of c next to prompt. It is never negative,
+// even when the maximum prompt length is smaller than prompt itself.
+func PromptRoom(c interface{}, prompt string) int {
+	if room := MaxPromptLength(c) - len(prompt); room > 0 {
+		return room
+	}
+	return 0
+}
+
+// Truncate cuts s to n characters and marks the cut with "...". s is returned as is when it fits.
+func Truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	if n < 0 {
+		n = 0
+	}
+	return s[:n] + "..."
+}
+
 type Client struct {
-	client *openai.Client
-	model  string
+	client          *openai.Client
+	provider        Provider
+	baseURL         string
+	model           string
+	maxPromptLength int
+	tracker         *usage.Tracker
+	budget          *usage.Budget
+	cache           *cache.Cache
 }
 
 func NewClient(token, model string) *Client {
 	return &Client{
-		client: openai.NewClient(token),
-		model:  model,
+		client:          openai.NewClient(token),
+		provider:        ProviderOpenAI,
+		baseURL:         openai.DefaultConfig(token).BaseURL,
+		model:           model,
+		maxPromptLength: DefaultMaxPromptLength,
 	}
 }
 
+// NewClientWithConfig creates a client for the provider described by cfg.
+func NewClientWithConfig(cfg Config) (*Client, error) {
+	if cfg.Provider == "" {
+		cfg.Provider = ProviderOpenAI
+	}
+
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+
+	maxPromptLength := cfg.MaxPromptLength
+	switch cfg.Provider {
+	case ProviderOpenAI:
+		if cfg.Token == "" {
+			return nil, errors.New("openai token is required for the openai provider")
+		}
+		if cfg.Model == "" {
+			cfg.Model = DefaultModel
+		}
+		if maxPromptLength == 0 {
+			maxPromptLength = DefaultMaxPromptLength
+		}
+	case ProviderLocal:
+		if cfg.Model == "" {
+			return nil, errors.New("model is required for the local provider")
+		}
+		if cfg.BaseURL == "" {
+			cfg.BaseURL = DefaultLocalBaseURL
+		}
+		if maxPromptLength == 0 {
+			maxPromptLength = DefaultLocalMaxPromptLength
+		}
+	default:
+		return nil, fmt.Errorf("unknown provider: %s", cfg.Provider)
+	}
+
+	if cfg.Token == "" {
+		// local servers do not expect auth, so don't send an empty bearer token
+		transport := httpClient.Transport
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+		httpClient = &http.Client{
+			Transport: noAuthTransport{transport},
+			Timeout:   httpClient.Timeout,
+		}
+	}
+
+	config := openai.DefaultConfig(cfg.Token)
+	if cfg.BaseURL != "" {
+		config.BaseURL = cfg.BaseURL
+	}
+	config.HTTPClient = httpClient
+
+	return &Client{
+		client:          openai.NewClientWithConfig(config),
+		provider:        cfg.Provider,
+		baseURL:         config.BaseURL,
+		model:           cfg.Model,
+		maxPromptLength: maxPromptLength,
+		tracker:         cfg.Tracker,
+		budget:          cfg.Budget,
+		cache:           cfg.Cache,
+	}, nil
+}
+
+// MaxPromptLength returns the maximum prompt length in characters supported by the model.
+func (c *Client) MaxPromptLength() int {
+	return c.maxPromptLength
+}
+
+// Budget returns the budget the client spends from.
+func (c *Client) Budget() *usage.Budget {
+	return c.budget
+}
+
 func (c *Client) ChatCompletion(ctx context.Context, messages []openai.ChatCompletionMessage) (string, error) {
+	key := cache.Key(cache.Request{
+		Provider:    string(c.provider),
+		Endpoint:    c.baseURL,
+		Model:       c.model,
+		Temperature: temperature,
+		Messages:    messages,
+	})
+	if completion, ok := c.cache.Get(key); ok {
+		logs.Println("Using cached completion")
+		return completion, nil
+	}
+
+	if err := c.budget.Check(c.model, usage.EstimateTokens(messages)); err != nil {
+		return "", err
+	}
+
 	resp, err := c.client.CreateChatCompletion(
 		ctx,
 		openai.ChatCompletionRequest{
 			Model:       c.model,
 			Messages:    messages,
-			Temperature: 0.1,
+			Temperature: temperature,
 		},
 	)
 
@@ -45,8 +251,8 @@ func (c *Client) ChatCompletion(ctx context.Context, messages []openai.ChatCompl
 		if errors.Is(err, context.Canceled) {
 			return "", err
 		}
-		fmt.Println("Error completing prompt:", err)
-		fmt.Println("Retrying after 1 minute")
+		logs.Println("Error completing prompt:", err)
+		logs.Println("Retrying after 1 minute")
 		// retry once after 1 minute
 		time.Sleep(time.Minute)
 		resp, err = c.client.CreateChatCompletion(
@@ -54,7 +260,7 @@ func (c *Client) ChatCompletion(ctx context.Context, messages []openai.ChatCompl
 			openai.ChatCompletionRequest{
 				Model:       c.model,
 				Messages:    messages,
-				Temperature: 0.1,
+				Temperature: temperature,
 			},
 		)
 		if err != nil {
@@ -62,5 +268,29 @@ func (c *Client) ChatCompletion(ctx context.Context, messages []openai.ChatCompl
 		}
 	}
 
-	return resp.Choices[0].Message.Content, nil
+	c.tracker.Add(c.model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+
+	if len(resp.Choices) == 0 {
+		return "", errors.New("error completing prompt: empty response")
+	}
+
+	completion := resp.Choices[0].Message.Content
+	if resp.Choices[0].FinishReason == finishReasonLength {
+		// a cut off completion is used once but not reused
+		logs.Println("Completion was truncated: max tokens reached")
+	} else if err := c.cache.Set(key, c.model, completion); err != nil {
+		logs.Println("Error caching completion:", err)
+	}
+
+	return completion, nil
+}
+
+type noAuthTransport struct {
+	base http.RoundTripper
+}
+
+func (t noAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Del("Authorization")
+	return t.base.RoundTrip(req)
 }
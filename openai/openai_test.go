@@ -0,0 +1,222 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/cache"
+	"github.com/ravilushqa/gpt-pullrequest-updater/logs"
+)
+
+func TestNewClientWithConfig(t *testing.T) {
+	testCases := []struct {
+		name           string
+		cfg            Config
+		expectedModel  string
+		expectedMaxLen int
+		expectError    bool
+	}{
+		{
+			name:           "OpenAI defaults",
+			cfg:            Config{Token: "token"},
+			expectedModel:  DefaultModel,
+			expectedMaxLen: DefaultMaxPromptLength,
+		},
+		{
+			name:        "OpenAI without token",
+			cfg:         Config{Provider: ProviderOpenAI},
+			expectError: true,
+		},
+		{
+			name:           "Local defaults",
+			cfg:            Config{Provider: ProviderLocal, Model: "llama3"},
+			expectedModel:  "llama3",
+			expectedMaxLen: DefaultLocalMaxPromptLength,
+		},
+		{
+			name:           "Local with custom prompt length",
+			cfg:            Config{Provider: ProviderLocal, Model: "llama3", MaxPromptLength: 1000},
+			expectedModel:  "llama3",
+			expectedMaxLen: 1000,
+		},
+		{
+			name:        "Local without model",
+			cfg:         Config{Provider: ProviderLocal},
+			expectError: true,
+		},
+		{
+			name:        "Unknown provider",
+			cfg:         Config{Provider: "unknown", Token: "token"},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewClientWithConfig(tc.cfg)
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedModel, client.model)
+			assert.Equal(t, tc.expectedMaxLen, client.MaxPromptLength())
+		})
+	}
+}
+
+type limitedClient int
+
+func (c limitedClient) MaxPromptLength() int {
+	return int(c)
+}
+
+func TestPromptRoom(t *testing.T) {
+	assert.Equal(t, 90, PromptRoom(limitedClient(100), strings.Repeat("p", 10)))
+	assert.Equal(t, 0, PromptRoom(limitedClient(100), strings.Repeat("p", 800)))
+	assert.Equal(t, DefaultMaxPromptLength-10, PromptRoom(nil, strings.Repeat("p", 10)))
+}
+
+func TestTruncate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		s        string
+		n        int
+		expected string
+	}{
+		{name: "Fits", s: "patch", n: 5, expected: "patch"},
+		{name: "Too long", s: "patch", n: 3, expected: "pat..."},
+		{name: "No room", s: "patch", n: 0, expected: "..."},
+		{name: "Negative room", s: "patch", n: -700, expected: "..."},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Truncate(tc.s, tc.n))
+		})
+	}
+}
+
+func TestChatCompletionLocalServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+		assert.Empty(t, r.Header.Get("Authorization"))
+
+		var req openai.ChatCompletionRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "llama3", req.Model)
+		assert.Len(t, req.Messages, 2)
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Model: req.Model,
+			Choices: []openai.ChatCompletionChoice{
+				{Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: "local completion"}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	client, err := NewClientWithConfig(Config{
+		Provider: ProviderLocal,
+		Model:    "llama3",
+		BaseURL:  server.URL + "/v1",
+	})
+	require.NoError(t, err)
+
+	completion, err := client.ChatCompletion(context.Background(), []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: "system"},
+		{Role: openai.ChatMessageRoleUser, Content: "patch"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "local completion", completion)
+}
+
+func TestChatCompletionCache(t *testing.T) {
+	testCases := []struct {
+		name         string
+		finishReason string
+		calls        int
+	}{
+		{name: "Complete", finishReason: "stop", calls: 1},
+		{name: "Truncated", finishReason: finishReasonLength, calls: 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				_ = json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+					Choices: []openai.ChatCompletionChoice{
+						{
+							Message:      openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: "completion"},
+							FinishReason: tc.finishReason,
+						},
+					},
+				})
+			}))
+			defer server.Close()
+
+			completionCache, err := cache.New(t.TempDir(), 0, 0)
+			require.NoError(t, err)
+			client, err := NewClientWithConfig(Config{
+				Provider: ProviderLocal,
+				Model:    "llama3",
+				BaseURL:  server.URL + "/v1",
+				Cache:    completionCache,
+			})
+			require.NoError(t, err)
+
+			messages := []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "patch"}}
+			for i := 0; i < 2; i++ {
+				completion, err := client.ChatCompletion(context.Background(), messages)
+				require.NoError(t, err)
+				assert.Equal(t, "completion", completion)
+			}
+			assert.Equal(t, tc.calls, calls)
+		})
+	}
+}
+
+func TestChatCompletionTruncated(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{
+				{
+					Message:      openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: "compl"},
+					FinishReason: finishReasonLength,
+				},
+			},
+		})
+	}))
+	defer server.Close()
+
+	var logBuf bytes.Buffer
+	logs.SetOutput(&logBuf)
+	defer logs.SetOutput(os.Stdout)
+
+	client, err := NewClientWithConfig(Config{
+		Provider: ProviderLocal,
+		Model:    "llama3",
+		BaseURL:  server.URL + "/v1",
+	})
+	require.NoError(t, err)
+
+	completion, err := client.ChatCompletion(context.Background(), []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleUser, Content: "patch"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "compl", completion)
+	assert.Contains(t, logBuf.String(), "Completion was truncated: max tokens reached")
+}
@@ -0,0 +1,159 @@
+// Package output defines the machine-readable result of the description and review commands.
+//
+// The schema is versioned by SchemaVersion. Fields may be added within a version; renaming or removing
+// a field, or changing its meaning, requires a new version.
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/description"
+	ghClient "github.com/ravilushqa/gpt-pullrequest-updater/github"
+	"github.com/ravilushqa/gpt-pullrequest-updater/review"
+	"github.com/ravilushqa/gpt-pullrequest-updater/usage"
+)
+
+// SchemaVersion is the version of the Result schema.
+const SchemaVersion = 1
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+const (
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+)
+
+// Result is the output of a single run of a command.
+type Result struct {
+	SchemaVersion int    `json:"schema_version"`
+	Command       string `json:"command"`
+	// Status is StatusSuccess, or StatusFailed when the run stopped with Error. The other fields then hold
+	// what was done before the failure.
+	Status      string      `json:"status"`
+	Error       string      `json:"error,omitempty"`
+	PullRequest PullRequest `json:"pull_request"`
+	// Description is the generated description. Empty for the review command.
+	Description string `json:"description,omitempty"`
+	// Title is the generated title, or the suggestion for a non-conforming title in validate mode.
+	Title  string  `json:"title,omitempty"`
+	Files  []File  `json:"files"`
+	Issues []Issue `json:"issues"`
+	// Skipped is true when the description was not regenerated because the diff has not changed.
+	Skipped bool `json:"skipped,omitempty"`
+	// Coverage is set when the changes of some files could not be retrieved and were not analyzed.
+	Coverage *ghClient.Coverage `json:"coverage,omitempty"`
+	Metadata Metadata           `json:"metadata"`
+}
+
+type PullRequest struct {
+	Owner  string `json:"owner"`
+	Repo   string `json:"repo"`
+	Number int    `json:"number"`
+	URL    string `json:"url,omitempty"`
+}
+
+// File is the result for a single file. Summary is set by the description command, Quality by the
+// review command.
+type File struct {
+	Filename string `json:"filename"`
+	Summary  string `json:"summary,omitempty"`
+	Quality  string `json:"quality,omitempty"`
+}
+
+type Issue struct {
+	File        string `json:"file"`
+	Line        int    `json:"line"`
+	Type        string `json:"type"`
+	Severity    string `json:"severity"`
+	Description string `json:"description"`
+}
+
+type Metadata struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	// Usage has the tokens, the estimated cost and the files skipped by the budget.
+	Usage usage.Report `json:"usage"`
+}
+
+// New creates an empty result of the command for the pull request.
+func New(command, owner, repo string, number int) *Result {
+	return &Result{
+		SchemaVersion: SchemaVersion,
+		Command:       command,
+		Status:        StatusSuccess,
+		PullRequest:   PullRequest{Owner: owner, Repo: repo, Number: number},
+		Files:         []File{},
+		Issues:        []Issue{},
+	}
+}
+
+// SetError records the error the run stopped with. A nil err keeps the result successful.
+func (r *Result) SetError(err error) {
+	if err == nil {
+		return
+	}
+	r.Status = StatusFailed
+	r.Error = err.Error()
+}
+
+// AddDescription adds the generated description and its per-file summaries.
+func (r *Result) AddDescription(d *description.Result) {
+	r.Description = d.Description
+	for _, f := range d.Files {
+		r.Files = append(r.Files, File{Filename: f.Filename, Summary: f.Summary})
+	}
+}
+
+// AddReviews adds the reviewed files and their issues.
+func (r *Result) AddReviews(reviews []review.FileReview) {
+	for _, fr := range reviews {
+		r.Files = append(r.Files, File{Filename: fr.Filename, Quality: string(fr.Quality)})
+		for _, issue := range fr.Issues {
+			r.Issues = append(r.Issues, Issue{
+				File:        fr.Filename,
+				Line:        issue.Line,
+				Type:        issue.Type,
+				Severity:    issue.Severity,
+				Description: issue.Description,
+			})
+		}
+	}
+}
+
+// AddCoverage records the coverage when it is incomplete.
+func (r *Result) AddCoverage(c ghClient.Coverage) {
+	if !c.Complete() {
+		r.Coverage = &c
+	}
+}
+
+// Encode writes the result as indented JSON.
+func (r *Result) Encode(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(r); err != nil {
+		return fmt.Errorf("error encoding result: %w", err)
+	}
+	return nil
+}
+
+// Write writes the result to path, or to w when path is empty.
+func (r *Result) Write(w io.Writer, path string) error {
+	if path == "" {
+		return r.Encode(w)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating output file: %w", err)
+	}
+	defer f.Close()
+
+	return r.Encode(f)
+}
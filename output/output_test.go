@@ -0,0 +1,114 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/description"
+	ghClient "github.com/ravilushqa/gpt-pullrequest-updater/github"
+	"github.com/ravilushqa/gpt-pullrequest-updater/review"
+	"github.com/ravilushqa/gpt-pullrequest-updater/usage"
+)
+
+func TestResultJSON(t *testing.T) {
+	testCases := []struct {
+		name     string
+		result   func() *Result
+		expected string
+	}{
+		{
+			name: "Description",
+			result: func() *Result {
+				r := New("description", "owner", "repo", 1)
+				r.AddDescription(&description.Result{
+					Description: "## Summary",
+					Files:       []description.FileSummary{{Filename: "main.go", Summary: "Adds a flag"}},
+				})
+				r.AddCoverage(ghClient.Coverage{ChangedFiles: 1, AnalyzedFiles: 1})
+				return r
+			},
+			expected: `{
+				"schema_version": 1,
+				"command": "description",
+				"status": "success",
+				"pull_request": {"owner": "owner", "repo": "repo", "number": 1},
+				"description": "## Summary",
+				"files": [{"filename": "main.go", "summary": "Adds a flag"}],
+				"issues": [],
+				"metadata": {"provider": "", "model": "", "usage": {"calls": 0, "prompt_tokens": 0, "completion_tokens": 0, "total_tokens": 0, "estimated_cost_usd": 0, "models": null}}
+			}`,
+		},
+		{
+			name: "Review",
+			result: func() *Result {
+				r := New("review", "owner", "repo", 2)
+				r.AddReviews([]review.FileReview{{
+					Filename: "main.go",
+					Review: review.Review{
+						Quality: review.Bad,
+						Issues:  []review.Issue{{Type: "bug", Severity: "high", Line: 3, Description: "Nil dereference"}},
+					},
+				}})
+				r.AddCoverage(ghClient.Coverage{ChangedFiles: 3100, AnalyzedFiles: 2999, FromRawDiff: []string{"big.go"}, MissingFiles: []string{"huge.go"}})
+				r.Metadata = Metadata{Provider: "openai", Model: "gpt-4o", Usage: usage.Report{Skipped: []usage.Skipped{{Item: "go.sum", Reason: "low priority"}}}}
+				return r
+			},
+			expected: `{
+				"schema_version": 1,
+				"command": "review",
+				"status": "success",
+				"pull_request": {"owner": "owner", "repo": "repo", "number": 2},
+				"files": [{"filename": "main.go", "quality": "bad"}],
+				"issues": [{"file": "main.go", "line": 3, "type": "bug", "severity": "high", "description": "Nil dereference"}],
+				"coverage": {"changed_files": 3100, "analyzed_files": 2999, "from_raw_diff": ["big.go"], "missing_files": ["huge.go"]},
+				"metadata": {"provider": "openai", "model": "gpt-4o", "usage": {"calls": 0, "prompt_tokens": 0, "completion_tokens": 0, "total_tokens": 0, "estimated_cost_usd": 0, "models": null, "skipped": [{"item": "go.sum", "reason": "low priority"}]}}
+			}`,
+		},
+		{
+			name: "Failed",
+			result: func() *Result {
+				r := New("review", "owner", "repo", 3)
+				r.SetError(errors.New("error getting pull request: 404 Not Found"))
+				return r
+			},
+			expected: `{
+				"schema_version": 1,
+				"command": "review",
+				"status": "failed",
+				"error": "error getting pull request: 404 Not Found",
+				"pull_request": {"owner": "owner", "repo": "repo", "number": 3},
+				"files": [],
+				"issues": [],
+				"metadata": {"provider": "", "model": "", "usage": {"calls": 0, "prompt_tokens": 0, "completion_tokens": 0, "total_tokens": 0, "estimated_cost_usd": 0, "models": null}}
+			}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, tc.result().Write(&buf, ""))
+			assert.JSONEq(t, tc.expected, buf.String())
+		})
+	}
+}
+
+func TestResultWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "result.json")
+	require.NoError(t, New("review", "owner", "repo", 1).Write(nil, path))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	var r Result
+	require.NoError(t, json.Unmarshal(data, &r))
+	assert.Equal(t, SchemaVersion, r.SchemaVersion)
+	assert.Equal(t, "review", r.Command)
+}
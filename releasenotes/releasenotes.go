@@ -0,0 +1,256 @@
+package releasenotes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v51/github"
+	"github.com/sashabaranov/go-openai"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/logs"
+	"github.com/ravilushqa/gpt-pullrequest-updater/marker"
+	oAIClient "github.com/ravilushqa/gpt-pullrequest-updater/openai"
+	"github.com/ravilushqa/gpt-pullrequest-updater/usage"
+)
+
+type Completer interface {
+	ChatCompletion(ctx context.Context, messages []openai.ChatCompletionMessage) (string, error)
+}
+
+// maxReduceRounds stops merging the pull request summaries when they do not shrink, e.g. with a tiny prompt
+// limit. The final prompt is truncated then.
+const maxReduceRounds = 5
+
+// prNumber finds the pull request in the subject of squash merges, "Fix crash (#123)", and of merge commits,
+// "Merge pull request #123 from owner/branch".
+var prNumber = regexp.MustCompile(`\(#([0-9]+)\)$|^Merge pull request #([0-9]+) `)
+
+type PullRequestLister interface {
+	ListCommitsBetween(ctx context.Context, owner, repo, base, head string) ([]*github.RepositoryCommit, error)
+	ListPullRequestsWithCommit(ctx context.Context, owner, repo, sha string) ([]*github.PullRequest, error)
+	GetPullRequest(ctx context.Context, owner, repo string, number int) (*github.PullRequest, error)
+}
+
+// FindMergedPullRequests returns the pull requests merged between two tags or SHAs, ordered by merge time.
+// Only pull requests whose merge commit is part of the range are returned. The pull request is taken from the
+// commit subject when it names one, other commits are looked up one by one.
+func FindMergedPullRequests(ctx context.Context, lister PullRequestLister, owner, repo, from, to string) ([]*github.PullRequest, error) {
+	commits, err := lister.ListCommitsBetween(ctx, owner, repo, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("error listing commits: %w", err)
+	}
+
+	inRange := make(map[string]bool, len(commits))
+	for _, c := range commits {
+		inRange[c.GetSHA()] = true
+	}
+	merged := func(pr *github.PullRequest) bool {
+		return pr.MergedAt != nil && inRange[pr.GetMergeCommitSHA()]
+	}
+
+	var prs []*github.PullRequest
+	seen := make(map[int]bool)
+	for i, c := range commits {
+		subject, _, _ := strings.Cut(c.GetCommit().GetMessage(), "\n")
+		if m := prNumber.FindStringSubmatch(strings.TrimSpace(subject)); m != nil {
+			number, _ := strconv.Atoi(m[1] + m[2])
+			if seen[number] {
+				continue
+			}
+			pr, err := lister.GetPullRequest(ctx, owner, repo, number)
+			if err != nil && !notFound(err) {
+				return nil, fmt.Errorf("error getting pull request #%d: %w", number, err)
+			}
+			// a cherry-picked commit names a pull request merged elsewhere, and a subject written by hand may name
+			// an issue or a pull request of another repository, the commit is looked up then
+			if err == nil && merged(pr) {
+				seen[number] = true
+				prs = append(prs, pr)
+				continue
+			}
+		}
+
+		logs.Printf("finding pull requests of commit: %s %d/%d\n", c.GetSHA(), i+1, len(commits))
+		commitPRs, err := lister.ListPullRequestsWithCommit(ctx, owner, repo, c.GetSHA())
+		if err != nil {
+			return nil, fmt.Errorf("error listing pull requests of commit %s: %w", c.GetSHA(), err)
+		}
+		for _, pr := range commitPRs {
+			if seen[pr.GetNumber()] || !merged(pr) {
+				continue
+			}
+			seen[pr.GetNumber()] = true
+			prs = append(prs, pr)
+		}
+	}
+
+	sort.SliceStable(prs, func(i, j int) bool { return prs[i].GetMergedAt().Before(prs[j].GetMergedAt().Time) })
+
+	return prs, nil
+}
+
+// notFound reports whether GitHub has no pull request with the number, e.g. because it is an issue.
+func notFound(err error) bool {
+	var errResp *github.ErrorResponse
+	return errors.As(err, &errResp) && errResp.Response != nil && errResp.Response.StatusCode == http.StatusNotFound
+}
+
+// Generate summarizes every pull request, then writes grouped release notes from the summaries.
+func Generate(ctx context.Context, client Completer, prs []*github.PullRequest) (string, error) {
+	if len(prs) == 0 {
+		return "No changes.", nil
+	}
+
+	maxLength := oAIClient.PromptRoom(client, oAIClient.PromptReleaseItem)
+	budget := usage.BudgetOf(client)
+	var summaries, entries []string
+	for i, pr := range prs {
+		logs.Printf("processing pull request: #%d %d/%d\n", pr.GetNumber(), i+1, len(prs))
+		summary := pr.GetTitle()
+
+		desc := marker.ExtractGenerated(pr.GetBody())
+		if desc == "" {
+			desc = pr.GetBody()
+		}
+		// a pull request without description is summarized by its title
+		if strings.TrimSpace(desc) != "" {
+			content := fmt.Sprintf("Title: %s\nLabels: %s\n\nDescription:\n%s", pr.GetTitle(), labels(pr), desc)
+			if len(content) > maxLength {
+				logs.Println("Description is too long, truncating")
+				content = oAIClient.Truncate(content, maxLength)
+			}
+
+			completion, err := client.ChatCompletion(ctx, []openai.ChatCompletionMessage{
+				{
+					Role:    openai.ChatMessageRoleSystem,
+					Content: oAIClient.PromptReleaseItem,
+				},
+				{
+					Role:    openai.ChatMessageRoleUser,
+					Content: content,
+				},
+			})
+			switch {
+			case errors.Is(err, usage.ErrBudgetExceeded):
+				budget.Skip(fmt.Sprintf("#%d", pr.GetNumber()), err.Error())
+			case err != nil:
+				return "", fmt.Errorf("error summarizing pull request #%d: %w", pr.GetNumber(), err)
+			default:
+				logs.Println("Completion:", completion)
+				summary = strings.TrimSpace(completion)
+			}
+		}
+
+		entries = append(entries, fmt.Sprintf("Pull request #%d: %s\nLabels: %s\nSummary: %s\n\n", pr.GetNumber(), pr.GetTitle(), labels(pr), summary))
+		summaries = append(summaries, fmt.Sprintf("- %s (#%d)", summary, pr.GetNumber()))
+	}
+
+	room := oAIClient.PromptRoom(client, oAIClient.PromptReleaseOverall)
+	entries, err := reduceEntries(ctx, client, entries, room)
+	if errors.Is(err, usage.ErrBudgetExceeded) {
+		budget.Skip("partial release notes", err.Error())
+		return "### Changes\n" + strings.Join(summaries, "\n"), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	OverallReleaseCompletion := strings.Join(entries, "")
+	if len(OverallReleaseCompletion) > room {
+		logs.Println("Prompt is too long, truncating")
+		OverallReleaseCompletion = oAIClient.Truncate(OverallReleaseCompletion, room)
+	}
+
+	logs.Println("Summarizing release notes")
+	overallCompletion, err := summarize(ctx, client, OverallReleaseCompletion)
+	if errors.Is(err, usage.ErrBudgetExceeded) {
+		// keep the per pull request work instead of failing the whole run
+		budget.Skip("release notes summary", err.Error())
+		return "### Changes\n" + strings.Join(summaries, "\n"), nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error completing final prompt: %w", err)
+	}
+
+	logs.Println("Release notes:", overallCompletion)
+
+	return overallCompletion, nil
+}
+
+// reduceEntries merges the pull request entries into partial release notes, in batches that fit in limit
+// characters, until all of them fit in the final prompt.
+func reduceEntries(ctx context.Context, client Completer, entries []string, limit int) ([]string, error) {
+	for round := 0; round < maxReduceRounds && totalLength(entries) > limit && len(entries) > 1; round++ {
+		logs.Println("Pull requests do not fit in the prompt, writing partial release notes")
+		var reduced []string
+		for _, batch := range batches(entries, limit) {
+			if len(batch) == 1 {
+				reduced = append(reduced, batch[0])
+				continue
+			}
+			notes, err := summarize(ctx, client, oAIClient.Truncate(strings.Join(batch, ""), limit))
+			if err != nil {
+				return nil, fmt.Errorf("error writing partial release notes: %w", err)
+			}
+			reduced = append(reduced, strings.TrimSpace(notes)+"\n\n")
+		}
+		entries = reduced
+	}
+	return entries, nil
+}
+
+func summarize(ctx context.Context, client Completer, content string) (string, error) {
+	return client.ChatCompletion(ctx, []openai.ChatCompletionMessage{
+		{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: oAIClient.PromptReleaseOverall,
+		},
+		{
+			Role:    openai.ChatMessageRoleUser,
+			Content: content,
+		},
+	})
+}
+
+// batches splits entries into consecutive batches that fit in limit characters.
+func batches(entries []string, limit int) [][]string {
+	var result [][]string
+	var current []string
+	length := 0
+	for _, e := range entries {
+		if len(current) > 0 && length+len(e) > limit {
+			result = append(result, current)
+			current, length = nil, 0
+		}
+		current = append(current, e)
+		length += len(e)
+	}
+	if len(current) > 0 {
+		result = append(result, current)
+	}
+	return result
+}
+
+func totalLength(entries []string) int {
+	length := 0
+	for _, e := range entries {
+		length += len(e)
+	}
+	return length
+}
+
+func labels(pr *github.PullRequest) string {
+	names := make([]string, 0, len(pr.Labels))
+	for _, l := range pr.Labels {
+		names = append(names, l.GetName())
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,182 @@
+package releasenotes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v51/github"
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockCompleter struct {
+	mock.Mock
+}
+
+func (m *MockCompleter) ChatCompletion(ctx context.Context, messages []openai.ChatCompletionMessage) (string, error) {
+	args := m.Called(ctx, messages)
+	return args.String(0), args.Error(1)
+}
+
+type MockPullRequestLister struct {
+	mock.Mock
+}
+
+func (m *MockPullRequestLister) ListCommitsBetween(ctx context.Context, owner, repo, base, head string) ([]*github.RepositoryCommit, error) {
+	args := m.Called(ctx, owner, repo, base, head)
+	return args.Get(0).([]*github.RepositoryCommit), args.Error(1)
+}
+
+func (m *MockPullRequestLister) ListPullRequestsWithCommit(ctx context.Context, owner, repo, sha string) ([]*github.PullRequest, error) {
+	args := m.Called(ctx, owner, repo, sha)
+	return args.Get(0).([]*github.PullRequest), args.Error(1)
+}
+
+func (m *MockPullRequestLister) GetPullRequest(ctx context.Context, owner, repo string, number int) (*github.PullRequest, error) {
+	args := m.Called(ctx, owner, repo, number)
+	return args.Get(0).(*github.PullRequest), args.Error(1)
+}
+
+type MockLimitedCompleter struct {
+	MockCompleter
+	maxPromptLength int
+}
+
+func (m *MockLimitedCompleter) MaxPromptLength() int {
+	return m.maxPromptLength
+}
+
+func mergedPR(number int, mergeSHA string, mergedAt time.Time) *github.PullRequest {
+	return &github.PullRequest{
+		Number:         github.Int(number),
+		Title:          github.String("title"),
+		MergeCommitSHA: github.String(mergeSHA),
+		MergedAt:       &github.Timestamp{Time: mergedAt},
+	}
+}
+
+func TestFindMergedPullRequests(t *testing.T) {
+	now := time.Now()
+	first := mergedPR(1, "sha1", now.Add(-time.Hour))
+	second := mergedPR(2, "sha2", now)
+	otherBranch := mergedPR(3, "sha-elsewhere", now)
+	open := &github.PullRequest{Number: github.Int(4)}
+
+	lister := new(MockPullRequestLister)
+	lister.On("ListCommitsBetween", mock.Anything, "owner", "repo", "v1.0.0", "v1.1.0").Return([]*github.RepositoryCommit{
+		{SHA: github.String("sha2")},
+		{SHA: github.String("sha1")},
+	}, nil)
+	lister.On("ListPullRequestsWithCommit", mock.Anything, "owner", "repo", "sha2").Return([]*github.PullRequest{second, otherBranch}, nil)
+	lister.On("ListPullRequestsWithCommit", mock.Anything, "owner", "repo", "sha1").Return([]*github.PullRequest{first, second, open}, nil)
+
+	prs, err := FindMergedPullRequests(context.Background(), lister, "owner", "repo", "v1.0.0", "v1.1.0")
+	require.NoError(t, err)
+
+	assert.Equal(t, []*github.PullRequest{first, second}, prs)
+}
+
+func TestFindMergedPullRequestsFromSubjects(t *testing.T) {
+	now := time.Now()
+	squashed := mergedPR(5, "sha5", now.Add(-time.Hour))
+	merged := mergedPR(6, "sha6", now)
+	cherryPicked := mergedPR(7, "sha-elsewhere", now)
+	rebased := mergedPR(8, "sha8", now)
+
+	lister := new(MockPullRequestLister)
+	lister.On("ListCommitsBetween", mock.Anything, "owner", "repo", "v1.0.0", "v1.1.0").Return([]*github.RepositoryCommit{
+		{SHA: github.String("sha8"), Commit: &github.Commit{Message: github.String("Rebased change")}},
+		{SHA: github.String("sha7"), Commit: &github.Commit{Message: github.String("Backport fix (#7)")}},
+		{SHA: github.String("sha6"), Commit: &github.Commit{Message: github.String("Merge pull request #6 from owner/feature\n\nAdd feature")}},
+		{SHA: github.String("sha6-1"), Commit: &github.Commit{Message: github.String("Add feature (#6)")}},
+		{SHA: github.String("sha5"), Commit: &github.Commit{Message: github.String("Fix crash (#5)\n\n* Fix crash\n* Add test")}},
+	}, nil)
+	lister.On("GetPullRequest", mock.Anything, "owner", "repo", 5).Return(squashed, nil).Once()
+	lister.On("GetPullRequest", mock.Anything, "owner", "repo", 6).Return(merged, nil).Once()
+	lister.On("GetPullRequest", mock.Anything, "owner", "repo", 7).Return(cherryPicked, nil).Once()
+	lister.On("ListPullRequestsWithCommit", mock.Anything, "owner", "repo", "sha7").Return([]*github.PullRequest{}, nil).Once()
+	lister.On("ListPullRequestsWithCommit", mock.Anything, "owner", "repo", "sha8").Return([]*github.PullRequest{rebased}, nil).Once()
+
+	prs, err := FindMergedPullRequests(context.Background(), lister, "owner", "repo", "v1.0.0", "v1.1.0")
+	require.NoError(t, err)
+
+	assert.Equal(t, []*github.PullRequest{squashed, rebased, merged}, prs)
+	lister.AssertExpectations(t)
+}
+
+func TestFindMergedPullRequestsSubjectWithoutPullRequest(t *testing.T) {
+	now := time.Now()
+	fixed := mergedPR(9, "sha9", now)
+	notFound := &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusNotFound}, Message: "Not Found"}
+
+	lister := new(MockPullRequestLister)
+	lister.On("ListCommitsBetween", mock.Anything, "owner", "repo", "v1.0.0", "v1.1.0").Return([]*github.RepositoryCommit{
+		{SHA: github.String("sha9"), Commit: &github.Commit{Message: github.String("Fix issue (#3)")}},
+	}, nil)
+	lister.On("GetPullRequest", mock.Anything, "owner", "repo", 3).Return((*github.PullRequest)(nil), notFound).Once()
+	lister.On("ListPullRequestsWithCommit", mock.Anything, "owner", "repo", "sha9").Return([]*github.PullRequest{fixed}, nil).Once()
+
+	prs, err := FindMergedPullRequests(context.Background(), lister, "owner", "repo", "v1.0.0", "v1.1.0")
+	require.NoError(t, err)
+
+	assert.Equal(t, []*github.PullRequest{fixed}, prs)
+	lister.AssertExpectations(t)
+}
+
+func TestFindMergedPullRequestsGetPullRequestError(t *testing.T) {
+	lister := new(MockPullRequestLister)
+	lister.On("ListCommitsBetween", mock.Anything, "owner", "repo", "v1.0.0", "v1.1.0").Return([]*github.RepositoryCommit{
+		{SHA: github.String("sha9"), Commit: &github.Commit{Message: github.String("Fix issue (#3)")}},
+	}, nil)
+	lister.On("GetPullRequest", mock.Anything, "owner", "repo", 3).Return((*github.PullRequest)(nil), errors.New("rate limited")).Once()
+
+	_, err := FindMergedPullRequests(context.Background(), lister, "owner", "repo", "v1.0.0", "v1.1.0")
+	assert.Error(t, err)
+}
+
+func TestGenerate(t *testing.T) {
+	withBody := mergedPR(1, "sha1", time.Now())
+	withBody.Body = github.String("Adds a cache.")
+	withoutBody := mergedPR(2, "sha2", time.Now())
+
+	mockCompleter := new(MockCompleter)
+	mockCompleter.On("ChatCompletion", mock.Anything, mock.MatchedBy(func(messages []openai.ChatCompletionMessage) bool {
+		return strings.HasPrefix(messages[1].Content, "Title:")
+	})).Return("Add completion cache.", nil).Once()
+	mockCompleter.On("ChatCompletion", mock.Anything, mock.MatchedBy(func(messages []openai.ChatCompletionMessage) bool {
+		return strings.Contains(messages[1].Content, "Pull request #1: title\nLabels: none\nSummary: Add completion cache.") &&
+			strings.Contains(messages[1].Content, "Pull request #2: title\nLabels: none\nSummary: title")
+	})).Return("### Features\n- Add completion cache (#1)", nil).Once()
+
+	notes, err := Generate(context.Background(), mockCompleter, []*github.PullRequest{withBody, withoutBody})
+	require.NoError(t, err)
+
+	assert.Equal(t, "### Features\n- Add completion cache (#1)", notes)
+	mockCompleter.AssertExpectations(t)
+}
+
+func TestGeneratePromptWithinLimit(t *testing.T) {
+	const limit = 1200
+	var prs []*github.PullRequest
+	for i := 1; i <= 20; i++ {
+		prs = append(prs, mergedPR(i, "sha", time.Now()))
+	}
+
+	mockCompleter := &MockLimitedCompleter{maxPromptLength: limit}
+	mockCompleter.On("ChatCompletion", mock.Anything, mock.MatchedBy(func(messages []openai.ChatCompletionMessage) bool {
+		return len(messages[0].Content)+len(messages[1].Content) <= limit
+	})).Return("### Other\n- Changes (#1)", nil)
+
+	notes, err := Generate(context.Background(), mockCompleter, prs)
+	require.NoError(t, err)
+
+	assert.Equal(t, "### Other\n- Changes (#1)", notes)
+	// the pull requests were merged in more than one step
+	assert.Greater(t, len(mockCompleter.Calls), 1)
+}
@@ -2,12 +2,15 @@ package review
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/google/go-github/v51/github"
 	"github.com/sashabaranov/go-openai"
 
+	"github.com/ravilushqa/gpt-pullrequest-updater/logs"
 	oAIClient "github.com/ravilushqa/gpt-pullrequest-updater/openai"
+	"github.com/ravilushqa/gpt-pullrequest-updater/usage"
 )
 
 type PullRequestUpdater interface {
@@ -25,10 +28,17 @@ type Review struct {
 
 type Issue struct {
 	Type        string `json:"type"`
+	Severity    string `json:"severity"`
 	Line        int    `json:"line"`
 	Description string `json:"description"`
 }
 
+// FileReview is the review of a single file of the diff.
+type FileReview struct {
+	Filename string
+	Review
+}
+
 type Quality string
 
 const (
@@ -37,20 +47,62 @@ const (
 	Neutral Quality = "neutral"
 )
 
+// CoverageMarker is hidden in the comment saying the review is incomplete, so re-runs update it instead of posting
+// it again.
+const CoverageMarker = "<!-- gpt:review-coverage -->"
+
+// UsageMarker is hidden in the comment with the token usage of the review, so re-runs update it instead of posting
+// it again.
+const UsageMarker = "<!-- gpt:review-usage -->"
+
+const (
+	SeverityHigh   = "high"
+	SeverityMedium = "medium"
+	SeverityLow    = "low"
+)
+
+// defaultSeverity is used when the model does not report the severity of an issue.
+func defaultSeverity(issueType string) string {
+	switch issueType {
+	case "bug", "security":
+		return SeverityHigh
+	case "performance":
+		return SeverityMedium
+	default:
+		return SeverityLow
+	}
+}
+
 func GenerateCommentsFromDiff(ctx context.Context, openAIClient Completer, diff *github.CommitsComparison) ([]*github.PullRequestComment, error) {
-	var comments []*github.PullRequestComment
+	reviews, err := ReviewDiff(ctx, openAIClient, diff)
+	if err != nil {
+		return nil, err
+	}
+	return Comments(diff, reviews), nil
+}
 
+// ReviewDiff reviews every file of the diff. Files that are skipped or whose review cannot be parsed
+// are left out.
+func ReviewDiff(ctx context.Context, openAIClient Completer, diff *github.CommitsComparison) ([]FileReview, error) {
+	var reviews []FileReview
+
+	maxLength := oAIClient.PromptRoom(openAIClient, oAIClient.PromptReview)
+	budget := usage.BudgetOf(openAIClient)
 	for i, file := range diff.Files {
 		patch := file.GetPatch()
-		fmt.Printf("processing file: %s %d/%d\n", file.GetFilename(), i+1, len(diff.Files))
+		logs.Printf("processing file: %s %d/%d\n", file.GetFilename(), i+1, len(diff.Files))
 		if patch == "" || file.GetStatus() == "removed" || file.GetStatus() == "renamed" {
 			continue
 		}
 
-		maxLength := 4096 - len(oAIClient.PromptReview)
+		if budget.NearlyExhausted() && usage.IsLowPriority(file.GetFilename()) {
+			budget.Skip(file.GetFilename(), "low priority file, budget nearly used up")
+			continue
+		}
+
 		if len(patch) > maxLength {
-			fmt.Println("Patch is too long, truncating")
-			patch = fmt.Sprintf("%s...", patch[:maxLength])
+			logs.Println("Patch is too long, truncating")
+			patch = oAIClient.Truncate(patch, maxLength)
 		}
 		completion, err := openAIClient.ChatCompletion(ctx, []openai.ChatCompletionMessage{
 			{
@@ -63,46 +115,66 @@ func GenerateCommentsFromDiff(ctx context.Context, openAIClient Completer, diff
 			},
 		})
 
+		if errors.Is(err, usage.ErrBudgetExceeded) {
+			budget.Skip(file.GetFilename(), err.Error())
+			continue
+		}
 		if err != nil {
 			return nil, fmt.Errorf("error getting completion: %w", err)
 		}
 
-		fmt.Println("Completion:", completion)
+		logs.Println("Completion:", completion)
 
 		review, err := extractReviewFromString(completion)
 		if err != nil {
-			fmt.Println("Error extracting JSON:", err)
+			logs.Println("Error extracting JSON:", err)
 			continue
 		}
+		for i := range review.Issues {
+			if review.Issues[i].Severity == "" {
+				review.Issues[i].Severity = defaultSeverity(review.Issues[i].Type)
+			}
+		}
+
+		reviews = append(reviews, FileReview{Filename: file.GetFilename(), Review: *review})
+	}
 
+	return reviews, nil
+}
+
+// Comments converts the reviews into pull request comments on the last commit of the diff.
+func Comments(diff *github.CommitsComparison, reviews []FileReview) []*github.PullRequestComment {
+	var comments []*github.PullRequestComment
+	for _, review := range reviews {
 		if review.Quality == Good {
-			fmt.Println("Review is good")
+			logs.Println("Review is good")
 			continue
 		}
 		for _, issue := range review.Issues {
 			if issue.Line == 0 {
-				fmt.Printf("Skipping file-level issue: %v\n", issue)
+				logs.Printf("Skipping file-level issue: %v\n", issue)
 				continue // TODO: add support for file-level issues
 			}
 			body := fmt.Sprintf("[%s] %s", issue.Type, issue.Description)
+			line := issue.Line
 			comment := &github.PullRequestComment{
 				CommitID: diff.Commits[len(diff.Commits)-1].SHA,
-				Path:     file.Filename,
+				Path:     github.String(review.Filename),
 				Body:     &body,
-				Position: &issue.Line,
+				Position: &line,
 			}
 			comments = append(comments, comment)
 		}
 	}
 
-	return comments, nil
+	return comments
 }
 
 func PushComments(ctx context.Context, prUpdated PullRequestUpdater, owner, repo string, number int, comments []*github.PullRequestComment) error {
 	for i, c := range comments {
-		fmt.Printf("creating comment: %s %d/%d\n", *c.Path, i+1, len(comments))
+		logs.Printf("creating comment: %s %d/%d\n", *c.Path, i+1, len(comments))
 		if _, err := prUpdated.CreatePullRequestComment(ctx, owner, repo, number, c); err != nil {
-			fmt.Printf("error creating comment: %s\n%+v", err, *c) // TODO: return error instead of printing
+			logs.Printf("error creating comment: %s\n%+v", err, *c) // TODO: return error instead of printing
 		}
 	}
 	return nil
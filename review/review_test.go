@@ -8,6 +8,8 @@ import (
 	"github.com/sashabaranov/go-openai"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/usage"
 )
 
 type MockCompleter struct {
@@ -111,6 +113,104 @@ func TestGenerateCommentsFromDiff(t *testing.T) {
 	}
 }
 
+func TestReviewDiffSeverity(t *testing.T) {
+	mockCompleter := new(MockCompleter)
+	mockCompleter.On("ChatCompletion", mock.Anything, mock.Anything).Return(`{
+		"quality": "bad",
+		"issues": [
+			{"type": "bug", "line": 1, "description": "Nil dereference"},
+			{"type": "performance", "severity": "low", "line": 2, "description": "Allocation in a loop"},
+			{"type": "maintenance", "line": 3, "description": "Long function"}
+		]
+	}`, nil)
+	mockDiff := &github.CommitsComparison{
+		Files: []*github.CommitFile{
+			{
+				Filename: ptrOf("file1").(*string),
+				Patch:    ptrOf("patch1").(*string),
+				Status:   ptrOf("modified").(*string),
+			},
+		},
+	}
+
+	reviews, err := ReviewDiff(context.Background(), mockCompleter, mockDiff)
+
+	assert.NoError(t, err)
+	if assert.Len(t, reviews, 1) {
+		assert.Equal(t, "file1", reviews[0].Filename)
+		var severities []string
+		for _, issue := range reviews[0].Issues {
+			severities = append(severities, issue.Severity)
+		}
+		assert.Equal(t, []string{SeverityHigh, SeverityLow, SeverityLow}, severities)
+	}
+}
+
+type MockLimitedCompleter struct {
+	MockCompleter
+	maxPromptLength int
+}
+
+func (m *MockLimitedCompleter) MaxPromptLength() int {
+	return m.maxPromptLength
+}
+
+func TestReviewDiffPromptLimitSmallerThanPrompt(t *testing.T) {
+	mockCompleter := &MockLimitedCompleter{maxPromptLength: 100}
+	mockCompleter.On("ChatCompletion", mock.Anything, mock.MatchedBy(func(messages []openai.ChatCompletionMessage) bool {
+		return messages[1].Content == "..."
+	})).Return(`{"quality": "good", "issues": []}`, nil)
+	mockDiff := &github.CommitsComparison{
+		Files: []*github.CommitFile{
+			{
+				Filename: ptrOf("file1").(*string),
+				Patch:    ptrOf("patch1").(*string),
+				Status:   ptrOf("modified").(*string),
+			},
+		},
+	}
+
+	_, err := ReviewDiff(context.Background(), mockCompleter, mockDiff)
+
+	assert.NoError(t, err)
+	mockCompleter.AssertExpectations(t)
+}
+
+type MockBudgetedCompleter struct {
+	MockCompleter
+	budget *usage.Budget
+}
+
+func (m *MockBudgetedCompleter) Budget() *usage.Budget {
+	return m.budget
+}
+
+func TestGenerateCommentsFromDiffBudgetExceeded(t *testing.T) {
+	tracker := usage.NewTracker()
+	mockCompleter := &MockBudgetedCompleter{budget: usage.NewBudget(tracker, usage.DefaultPrices, 1, 0)}
+	mockCompleter.On("ChatCompletion", mock.Anything, mock.Anything).Return("", usage.ErrBudgetExceeded)
+	mockDiff := &github.CommitsComparison{
+		Files: []*github.CommitFile{
+			{
+				Filename: ptrOf("file1").(*string),
+				Patch:    ptrOf("patch1").(*string),
+				Status:   ptrOf("modified").(*string),
+			},
+			{
+				Filename: ptrOf("file2").(*string),
+				Patch:    ptrOf("patch2").(*string),
+				Status:   ptrOf("modified").(*string),
+			},
+		},
+	}
+
+	comments, err := GenerateCommentsFromDiff(context.Background(), mockCompleter, mockDiff)
+
+	assert.NoError(t, err)
+	assert.Empty(t, comments)
+	assert.Len(t, tracker.Report(usage.DefaultPrices).Skipped, 2)
+}
+
 func TestPushComments(t *testing.T) {
 	testCases := []struct {
 		name     string
@@ -0,0 +1,200 @@
+package squash
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/v51/github"
+	"github.com/sashabaranov/go-openai"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/description"
+	"github.com/ravilushqa/gpt-pullrequest-updater/logs"
+	oAIClient "github.com/ravilushqa/gpt-pullrequest-updater/openai"
+)
+
+// CommentMarker is hidden in the comment with the suggested message, so re-runs update it instead of posting it again.
+const CommentMarker = "<!-- gpt:squash-message -->"
+
+// LineWidth is the column the commit message body is wrapped at.
+const LineWidth = 72
+
+var coAuthorRe = regexp.MustCompile(`(?im)^co-authored-by:\s*(.+?)\s*<([^>]+)>\s*$`)
+
+type Completer interface {
+	ChatCompletion(ctx context.Context, messages []openai.ChatCompletionMessage) (string, error)
+}
+
+// Message is a squash-merge commit message.
+type Message struct {
+	Subject  string
+	Body     string
+	Trailers []string
+}
+
+// String renders the message with the body wrapped at LineWidth columns.
+func (m Message) String() string {
+	var sb strings.Builder
+	sb.WriteString(m.Subject)
+	if body := Wrap(m.Body, LineWidth); body != "" {
+		sb.WriteString("\n\n")
+		sb.WriteString(body)
+	}
+	if len(m.Trailers) > 0 {
+		sb.WriteString("\n\n")
+		sb.WriteString(strings.Join(m.Trailers, "\n"))
+	}
+	return sb.String()
+}
+
+// GenerateMessage generates a squash-merge commit message from the pull request, its description and commits.
+func GenerateMessage(ctx context.Context, client Completer, diff *github.CommitsComparison, pr *github.PullRequest, desc string) (*Message, error) {
+	maxLength := oAIClient.PromptRoom(client, oAIClient.PromptSquashCommit)
+	commits := description.CommitMessages(diff.Commits, maxLength/2)
+
+	files := make([]string, 0, len(diff.Files))
+	for _, file := range diff.Files {
+		files = append(files, fmt.Sprintf("- %s (+%d -%d)", file.GetFilename(), file.GetAdditions(), file.GetDeletions()))
+	}
+
+	// the pull request number is appended to the subject, which must still fit in LineWidth
+	suffix := fmt.Sprintf(" (#%d)", pr.GetNumber())
+	subjectLength := LineWidth - len(suffix)
+
+	content := fmt.Sprintf("Subject limit: %d characters\n\nPull request title: %s\n\nDescription:\n%s\n\nCommits:\n%s\n\nChanged files:\n%s",
+		subjectLength, pr.GetTitle(), desc, commits, strings.Join(files, "\n"))
+	if len(content) > maxLength {
+		logs.Println("Prompt is too long, truncating")
+		content = oAIClient.Truncate(content, maxLength)
+	}
+
+	logs.Println("Generating squash commit message")
+	completion, err := client.ChatCompletion(ctx, []openai.ChatCompletionMessage{
+		{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: oAIClient.PromptSquashCommit,
+		},
+		{
+			Role:    openai.ChatMessageRoleUser,
+			Content: content,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error completing prompt: %w", err)
+	}
+
+	msg := parseMessage(completion)
+	if msg.Subject == "" {
+		msg.Subject = pr.GetTitle()
+	}
+	msg.Subject = shortenSubject(msg.Subject, subjectLength) + suffix
+	msg.Trailers = CoAuthors(diff.Commits, pr.GetUser())
+
+	return msg, nil
+}
+
+// parseMessage splits a completion into subject and body, dropping any trailers the model added.
+func parseMessage(completion string) *Message {
+	completion = strings.Trim(strings.TrimSpace(completion), "`")
+	completion = coAuthorRe.ReplaceAllString(completion, "")
+	parts := strings.SplitN(strings.TrimSpace(completion), "\n", 2)
+
+	msg := &Message{Subject: strings.TrimSuffix(strings.TrimSpace(parts[0]), ".")}
+	if len(parts) == 2 {
+		msg.Body = strings.TrimSpace(parts[1])
+	}
+	return msg
+}
+
+// shortenSubject cuts subject to at most n characters at a word boundary.
+func shortenSubject(subject string, n int) string {
+	if len(subject) <= n {
+		return subject
+	}
+	// the character after the cut is included so a word ending right at n is kept
+	cut := subject[:n]
+	if i := strings.LastIndexByte(subject[:n+1], ' '); i > 0 {
+		cut = subject[:i]
+	}
+	return strings.TrimRight(cut, " ,;:-")
+}
+
+// CoAuthors returns Co-authored-by trailers for the co-authors named in the commits and for the commit authors
+// other than the pull request author, deduplicated by email. Commits are the pull request author's when they
+// are linked to the author's login or have the author's email or name, as GitLab and Bitbucket do not link
+// every commit to a user.
+func CoAuthors(commits []*github.RepositoryCommit, prAuthor *github.User) []string {
+	var trailers []string
+	seen := make(map[string]bool)
+	add := func(name, email string) {
+		key := strings.ToLower(email)
+		if email == "" || seen[key] {
+			return
+		}
+		seen[key] = true
+		trailers = append(trailers, fmt.Sprintf("Co-authored-by: %s <%s>", name, email))
+	}
+
+	for _, c := range commits {
+		if isAuthor(c, prAuthor) {
+			seen[strings.ToLower(c.GetCommit().GetAuthor().GetEmail())] = true
+		}
+	}
+	for _, c := range commits {
+		author := c.GetCommit().GetAuthor()
+		if !isAuthor(c, prAuthor) {
+			add(author.GetName(), author.GetEmail())
+		}
+		for _, m := range coAuthorRe.FindAllStringSubmatch(c.GetCommit().GetMessage(), -1) {
+			add(m[1], m[2])
+		}
+	}
+
+	return trailers
+}
+
+// isAuthor reports whether the commit was written by user.
+func isAuthor(c *github.RepositoryCommit, user *github.User) bool {
+	author := c.GetCommit().GetAuthor()
+	equal := func(a, b string) bool { return a != "" && strings.EqualFold(a, b) }
+	return equal(user.GetLogin(), c.GetAuthor().GetLogin()) ||
+		equal(user.GetEmail(), author.GetEmail()) ||
+		equal(user.GetName(), author.GetName())
+}
+
+// Wrap wraps text at width columns. Bullet points get a hanging indent, and indented lines such as code
+// are kept as they are.
+func Wrap(text string, width int) string {
+	var out []string
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t") || len(line) <= width {
+			out = append(out, strings.TrimRight(line, " "))
+			continue
+		}
+
+		indent := ""
+		trimmed := strings.TrimLeft(line, " ")
+		prefix := line[:len(line)-len(trimmed)]
+		if strings.HasPrefix(trimmed, "- ") || strings.HasPrefix(trimmed, "* ") {
+			indent = prefix + "  "
+		} else {
+			indent = prefix
+		}
+
+		current := prefix
+		for i, word := range strings.Fields(trimmed) {
+			switch {
+			case i == 0:
+				current += word
+			case len(current)+1+len(word) > width:
+				out = append(out, current)
+				current = indent + word
+			default:
+				current += " " + word
+			}
+		}
+		out = append(out, current)
+	}
+	return strings.Join(out, "\n")
+}
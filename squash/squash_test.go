@@ -0,0 +1,140 @@
+package squash
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v51/github"
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockCompleter struct {
+	mock.Mock
+}
+
+func (m *MockCompleter) ChatCompletion(ctx context.Context, messages []openai.ChatCompletionMessage) (string, error) {
+	args := m.Called(ctx, messages)
+	return args.String(0), args.Error(1)
+}
+
+func commit(login, name, email, message string) *github.RepositoryCommit {
+	return &github.RepositoryCommit{
+		Author: &github.User{Login: github.String(login)},
+		Commit: &github.Commit{
+			Message: github.String(message),
+			Author:  &github.CommitAuthor{Name: github.String(name), Email: github.String(email)},
+		},
+	}
+}
+
+func TestCoAuthors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		login    string
+		prAuthor *github.User
+	}{
+		{name: "GitHub login", login: "alice", prAuthor: &github.User{Login: github.String("alice")}},
+		{name: "Email without login", prAuthor: &github.User{Login: github.String("alice.w"), Email: github.String("Alice@example.com")}},
+		{name: "Name without login", prAuthor: &github.User{Login: github.String("alice.w"), Name: github.String("Alice")}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bobLogin := ""
+			if tc.login != "" {
+				bobLogin = "bob"
+			}
+			commits := []*github.RepositoryCommit{
+				commit(tc.login, "Alice", "alice@example.com", "feat: add cache"),
+				commit(bobLogin, "Bob", "bob@example.com", "fix typo"),
+				commit(tc.login, "Alice", "alice@example.com", "address review\n\nCo-authored-by: Carol <carol@example.com>\nCo-authored-by: Bob <BOB@example.com>"),
+			}
+
+			trailers := CoAuthors(commits, tc.prAuthor)
+
+			assert.Equal(t, []string{
+				"Co-authored-by: Bob <bob@example.com>",
+				"Co-authored-by: Carol <carol@example.com>",
+			}, trailers)
+		})
+	}
+}
+
+func TestWrap(t *testing.T) {
+	text := "This body line is definitely longer than seventy-two columns and must be wrapped.\n\n" +
+		"- a bullet point that is also much longer than seventy-two columns so it wraps nicely\n" +
+		"    code line that is long but must never be wrapped because it is indented code block"
+
+	wrapped := Wrap(text, 40)
+
+	for _, line := range strings.Split(wrapped, "\n") {
+		if !strings.HasPrefix(line, "    ") {
+			assert.LessOrEqual(t, len(line), 40, line)
+		}
+	}
+	assert.Contains(t, wrapped, "\n  ", "bullet continuation must be indented")
+	assert.Contains(t, wrapped, "    code line that is long but must never be wrapped because it is indented code block")
+}
+
+func TestGenerateMessage(t *testing.T) {
+	mockCompleter := new(MockCompleter)
+	mockCompleter.On("ChatCompletion", mock.Anything, mock.MatchedBy(func(messages []openai.ChatCompletionMessage) bool {
+		return !strings.Contains(messages[1].Content, "Merge branch") && strings.Contains(messages[1].Content, "- feat: add cache") &&
+			strings.HasPrefix(messages[1].Content, "Subject limit: 66 characters\n")
+	})).Return("```\nfeat(cache): add completion cache.\n\nStore completions on disk.\n\nCo-authored-by: Someone <someone@example.com>\n```", nil)
+
+	diff := &github.CommitsComparison{
+		Commits: []*github.RepositoryCommit{
+			commit("alice", "Alice", "alice@example.com", "feat: add cache"),
+			commit("alice", "Alice", "alice@example.com", "Merge branch 'main' into cache"),
+			commit("bob", "Bob", "bob@example.com", "fix typo"),
+		},
+		Files: []*github.CommitFile{{Filename: github.String("cache/cache.go")}},
+	}
+	pr := &github.PullRequest{
+		Number: github.Int(42),
+		Title:  github.String("Add cache"),
+		User:   &github.User{Login: github.String("alice")},
+	}
+
+	msg, err := GenerateMessage(context.Background(), mockCompleter, diff, pr, "description")
+	require.NoError(t, err)
+
+	assert.Equal(t, "feat(cache): add completion cache (#42)\n\nStore completions on disk.\n\nCo-authored-by: Bob <bob@example.com>", msg.String())
+}
+
+func TestGenerateMessageLongSubject(t *testing.T) {
+	mockCompleter := new(MockCompleter)
+	mockCompleter.On("ChatCompletion", mock.Anything, mock.Anything).
+		Return("feat(cache): add a completion cache that is shared by the review and description commands\n\nBody", nil)
+
+	pr := &github.PullRequest{Number: github.Int(1234), Title: github.String("Add cache")}
+	msg, err := GenerateMessage(context.Background(), mockCompleter, &github.CommitsComparison{}, pr, "description")
+	require.NoError(t, err)
+
+	assert.Equal(t, "feat(cache): add a completion cache that is shared by the review (#1234)", msg.Subject)
+	assert.LessOrEqual(t, len(msg.Subject), LineWidth)
+}
+
+func TestShortenSubject(t *testing.T) {
+	testCases := []struct {
+		subject  string
+		n        int
+		expected string
+	}{
+		{subject: "fix: short", n: 20, expected: "fix: short"},
+		{subject: "fix: cut at the word boundary", n: 20, expected: "fix: cut at the word"},
+		{subject: "fix: drop trailing, punctuation", n: 19, expected: "fix: drop trailing"},
+		{subject: "fix:averyveryverylongword", n: 10, expected: "fix:averyv"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.subject, func(t *testing.T) {
+			assert.Equal(t, tc.expected, shortenSubject(tc.subject, tc.n))
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package tracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v51/github"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/criteria"
+	"github.com/ravilushqa/gpt-pullrequest-updater/marker"
+)
+
+var (
+	// closingKeyword matches the references GitHub closes on merge, e.g. "Fixes #123" or
+	// "closes owner/repo#123".
+	closingKeyword = regexp.MustCompile(`(?i)\b(?:close[sd]?|fix(?:e[sd])?|resolve[sd]?):?\s+((?:[\w.-]+/[\w.-]+)?#[0-9]+)\b`)
+	// issueBranch matches branches created from an issue: issue-123 or gh-123 in any path component, or the
+	// 123-fix-crash branches GitHub creates, which start with the number followed by a slug. Numbers alone,
+	// e.g. in release/2024-01 or hotfix/1-2, are not issues.
+	issueBranch = regexp.MustCompile(`(?i)(?:^|/)(?:issue|gh)-([0-9]+)(?:[-_/]|$)|^([0-9]+)-[a-z]`)
+)
+
+type gitHubTracker struct {
+	url         string
+	owner, repo string
+	issues      IssueGetter
+}
+
+// newGitHub links the issues on the host issues are read from, e.g. a GitHub Enterprise Server.
+func newGitHub(issues IssueGetter, owner, repo string) (Tracker, error) {
+	if issues == nil {
+		return nil, errors.New("the github tracker requires the github host")
+	}
+	return &gitHubTracker{url: strings.TrimSuffix(issues.WebURL(), "/"), owner: owner, repo: repo, issues: issues}, nil
+}
+
+func (t *gitHubTracker) Name() string {
+	return "GitHub issue"
+}
+
+// Keys returns the issues closed by the pull request. Keys are #123 for issues of the repository and
+// owner/repo#123 for other repositories.
+func (t *gitHubTracker) Keys(pr *github.PullRequest, commits []*github.RepositoryCommit) []string {
+	var keys []string
+	seen := map[string]bool{}
+	add := func(key string) {
+		owner, repo, _ := t.parseKey(key)
+		if strings.EqualFold(owner, t.owner) && strings.EqualFold(repo, t.repo) {
+			key = key[strings.Index(key, "#"):]
+		}
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	texts := []string{pr.GetTitle(), marker.StripGenerated(pr.GetBody())}
+	for _, c := range commits {
+		texts = append(texts, c.GetCommit().GetMessage())
+	}
+	for i, text := range texts {
+		for _, m := range closingKeyword.FindAllStringSubmatch(text, -1) {
+			add(m[1])
+		}
+		if i == 0 {
+			// the branch goes after the title
+			if m := issueBranch.FindStringSubmatch(pr.GetHead().GetRef()); m != nil {
+				add("#" + m[1] + m[2])
+			}
+		}
+	}
+
+	return keys
+}
+
+func (t *gitHubTracker) URL(key string) string {
+	owner, repo, number := t.parseKey(key)
+	return fmt.Sprintf("%s/%s/%s/issues/%d", t.url, owner, repo, number)
+}
+
+func (t *gitHubTracker) Issue(ctx context.Context, key string) (*Issue, error) {
+	owner, repo, number := t.parseKey(key)
+	issue, err := t.issues.GetIssue(ctx, owner, repo, number)
+	if err != nil {
+		return nil, fmt.Errorf("error getting issue %s: %w", key, err)
+	}
+	return &Issue{
+		Key:                key,
+		Title:              issue.GetTitle(),
+		Description:        issue.GetBody(),
+		AcceptanceCriteria: criteria.FromDescription(issue.GetBody()),
+	}, nil
+}
+
+// parseKey splits #123 or owner/repo#123 into its parts.
+func (t *gitHubTracker) parseKey(key string) (owner, repo string, number int) {
+	owner, repo = t.owner, t.repo
+	i := strings.Index(key, "#")
+	if name := key[:i]; name != "" {
+		parts := strings.SplitN(name, "/", 2)
+		owner, repo = parts[0], parts[1]
+	}
+	number, _ = strconv.Atoi(key[i+1:])
+	return owner, repo, number
+}
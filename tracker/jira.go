@@ -0,0 +1,68 @@
+package tracker
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/go-github/v51/github"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/jira"
+)
+
+type jiraTracker struct {
+	url       string
+	extractor *jira.Extractor
+}
+
+// jiraFetcher is a Jira tracker with API access.
+type jiraFetcher struct {
+	*jiraTracker
+	client *jira.Client
+}
+
+func newJira(cfg Config) (Tracker, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("jira url is required")
+	}
+	t := &jiraTracker{url: cfg.URL, extractor: jira.NewExtractor(cfg.Projects)}
+	if cfg.Token == "" {
+		return t, nil
+	}
+
+	client, err := jira.NewClient(jira.Config{
+		BaseURL:                 cfg.URL,
+		Email:                   cfg.Email,
+		Token:                   cfg.Token,
+		AcceptanceCriteriaField: cfg.AcceptanceCriteriaField,
+		HTTPClient:              cfg.HTTPClient,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &jiraFetcher{jiraTracker: t, client: client}, nil
+}
+
+func (t *jiraTracker) Name() string {
+	return "JIRA ticket"
+}
+
+func (t *jiraTracker) Keys(pr *github.PullRequest, commits []*github.RepositoryCommit) []string {
+	return t.extractor.FromPullRequest(pr, commits)
+}
+
+func (t *jiraTracker) URL(key string) string {
+	return jira.GenerateJiraTicketURL(t.url, key)
+}
+
+func (t *jiraFetcher) Issue(ctx context.Context, key string) (*Issue, error) {
+	issue, err := t.client.GetIssue(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return &Issue{
+		Key:                issue.Key,
+		Title:              issue.Summary,
+		Description:        issue.Description,
+		AcceptanceCriteria: issue.AcceptanceCriteria,
+	}, nil
+}
@@ -0,0 +1,100 @@
+package tracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v51/github"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/criteria"
+	"github.com/ravilushqa/gpt-pullrequest-updater/jira"
+)
+
+const defaultLinearAPIURL = "https://api.linear.app/graphql"
+
+const linearIssueQuery = `query Issue($id: String!) { issue(id: $id) { identifier title description } }`
+
+// linearTracker links Linear issues, whose identifiers look like Jira keys, e.g. ENG-123.
+type linearTracker struct {
+	workspaceURL string
+	extractor    *jira.Extractor
+}
+
+type linearFetcher struct {
+	*linearTracker
+	httpClient *http.Client
+	apiURL     string
+	token      string
+}
+
+func newLinear(cfg Config) (Tracker, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("linear workspace url is required, e.g. https://linear.app/acme")
+	}
+	t := &linearTracker{workspaceURL: strings.TrimSuffix(cfg.URL, "/"), extractor: jira.NewExtractor(cfg.Projects)}
+	if cfg.Token == "" {
+		return t, nil
+	}
+
+	apiURL := cfg.APIURL
+	if apiURL == "" {
+		apiURL = defaultLinearAPIURL
+	}
+	return &linearFetcher{linearTracker: t, httpClient: cfg.HTTPClient, apiURL: apiURL, token: cfg.Token}, nil
+}
+
+func (t *linearTracker) Name() string {
+	return "Linear issue"
+}
+
+func (t *linearTracker) Keys(pr *github.PullRequest, commits []*github.RepositoryCommit) []string {
+	return t.extractor.FromPullRequest(pr, commits)
+}
+
+func (t *linearTracker) URL(key string) string {
+	return fmt.Sprintf("%s/issue/%s", t.workspaceURL, key)
+}
+
+type linearResponse struct {
+	Data struct {
+		Issue *struct {
+			Identifier  string `json:"identifier"`
+			Title       string `json:"title"`
+			Description string `json:"description"`
+		} `json:"issue"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func (t *linearFetcher) Issue(ctx context.Context, key string) (*Issue, error) {
+	payload := map[string]interface{}{
+		"query":     linearIssueQuery,
+		"variables": map[string]string{"id": key},
+	}
+	// personal API keys are sent without a scheme
+	header := http.Header{"Authorization": {t.token}}
+
+	var resp linearResponse
+	if err := doJSON(ctx, t.httpClient, http.MethodPost, t.apiURL, header, payload, &resp); err != nil {
+		return nil, fmt.Errorf("error getting issue %s: %w", key, err)
+	}
+	if len(resp.Errors) > 0 {
+		return nil, fmt.Errorf("error getting issue %s: %s", key, resp.Errors[0].Message)
+	}
+	if resp.Data.Issue == nil {
+		return nil, fmt.Errorf("error getting issue %s: not found", key)
+	}
+
+	issue := resp.Data.Issue
+	return &Issue{
+		Key:                issue.Identifier,
+		Title:              issue.Title,
+		Description:        issue.Description,
+		AcceptanceCriteria: criteria.FromDescription(issue.Description),
+	}, nil
+}
@@ -0,0 +1,181 @@
+// Package tracker links pull requests to issue trackers.
+package tracker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v51/github"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/httpclient"
+	"github.com/ravilushqa/gpt-pullrequest-updater/logs"
+)
+
+// Type is a kind of issue tracker.
+type Type string
+
+const (
+	TypeJira     Type = "jira"
+	TypeLinear   Type = "linear"
+	TypeYouTrack Type = "youtrack"
+	TypeGitHub   Type = "github"
+)
+
+// Tracker finds the issues referenced by a pull request and links them.
+type Tracker interface {
+	// Name is the singular noun used for issues of the tracker in the description, e.g. JIRA ticket.
+	Name() string
+	// Keys returns the keys of the issues referenced by the pull request, deduplicated in the order they
+	// were found.
+	Keys(pr *github.PullRequest, commits []*github.RepositoryCommit) []string
+	// URL returns the link to the issue.
+	URL(key string) string
+}
+
+// Fetcher is implemented by trackers that can fetch issue details, usually when they have a token.
+type Fetcher interface {
+	Issue(ctx context.Context, key string) (*Issue, error)
+}
+
+// Issue is an issue of any tracker with the fields used to describe pull requests.
+type Issue struct {
+	Key                string
+	Title              string
+	Description        string
+	AcceptanceCriteria []string
+}
+
+// String formats the issue for the description prompt.
+func (i *Issue) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s: %s\n", i.Key, i.Title)
+	if i.Description != "" {
+		fmt.Fprintf(&sb, "Description: %s\n", i.Description)
+	}
+	if len(i.AcceptanceCriteria) > 0 {
+		sb.WriteString("Acceptance criteria:\n")
+		for _, c := range i.AcceptanceCriteria {
+			fmt.Fprintf(&sb, "- %s\n", c)
+		}
+	}
+	return sb.String()
+}
+
+// Fetch fetches the issues when the tracker supports it. Issues that cannot be fetched are left out.
+func Fetch(ctx context.Context, t Tracker, keys []string) []*Issue {
+	f, ok := t.(Fetcher)
+	if !ok {
+		return nil
+	}
+
+	var issues []*Issue
+	for _, key := range keys {
+		logs.Println("Fetching issue", key)
+		issue, err := f.Issue(ctx, key)
+		if err != nil {
+			logs.Printf("Error fetching issue: %v \n", err)
+			continue
+		}
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+// Config configures a tracker.
+type Config struct {
+	Type Type `json:"type"`
+	// URL is the tracker URL: the Jira or YouTrack instance, or the Linear workspace,
+	// e.g. https://linear.app/acme. Not used for GitHub Issues, which are linked on the GitHub host.
+	URL string `json:"url"`
+	// Projects restricts the keys to these projects or teams. All keys are accepted when empty.
+	Projects []string `json:"projects"`
+	// Email of the Jira Cloud account the token belongs to.
+	Email string `json:"email"`
+	// AcceptanceCriteriaField is the Jira custom field with the acceptance criteria.
+	AcceptanceCriteriaField string `json:"acceptance_criteria_field"`
+	// CABundle is the path to PEM encoded certificates trusted in addition to the system ones.
+	CABundle string `json:"ca_bundle"`
+	// Proxy is the URL of the HTTP proxy. The proxy environment variables are used when empty.
+	Proxy string `json:"proxy"`
+	// Token is the API token. It is never read from the config file.
+	Token string `json:"-"`
+	// APIURL overrides the API endpoint, e.g. for tests.
+	APIURL string `json:"-"`
+	// HTTPClient sends the API requests. When nil, NewHTTPClient creates one from the config.
+	HTTPClient *http.Client `json:"-"`
+}
+
+// requestTimeout bounds every tracker request, a hanging tracker must not stall the run.
+const requestTimeout = 30 * time.Second
+
+// NewHTTPClient returns a client with a timeout that trusts the CA bundle and uses the proxy of cfg.
+func NewHTTPClient(cfg Config) (*http.Client, error) {
+	transport, err := httpclient.NewTransport(cfg.CABundle, cfg.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: transport, Timeout: requestTimeout}, nil
+}
+
+// File is a tracker config file shared by several repositories.
+type File struct {
+	// Default is used for repositories that are not listed.
+	Default *Config `json:"default"`
+	// Repos maps owner/repo to the tracker of the repository.
+	Repos map[string]*Config `json:"repos"`
+}
+
+// LoadConfig returns the tracker of owner/repo from the config file, or nil when the file has none.
+func LoadConfig(path, owner, repo string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading tracker config: %w", err)
+	}
+
+	var file File
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("error decoding tracker config: %w", err)
+	}
+
+	for name, cfg := range file.Repos {
+		if strings.EqualFold(name, owner+"/"+repo) {
+			return cfg, nil
+		}
+	}
+	return file.Default, nil
+}
+
+// IssueGetter gets GitHub issues.
+type IssueGetter interface {
+	GetIssue(ctx context.Context, owner, repo string, number int) (*github.Issue, error)
+	// WebURL returns the URL of the web interface the issues are linked to.
+	WebURL() string
+}
+
+// New creates the tracker described by cfg. GitHub Issues of owner/repo are read through issues.
+func New(cfg Config, issues IssueGetter, owner, repo string) (Tracker, error) {
+	if cfg.HTTPClient == nil && cfg.Type != TypeGitHub {
+		var err error
+		if cfg.HTTPClient, err = NewHTTPClient(cfg); err != nil {
+			return nil, fmt.Errorf("error creating tracker http client: %w", err)
+		}
+	}
+
+	switch cfg.Type {
+	case TypeJira:
+		return newJira(cfg)
+	case TypeLinear:
+		return newLinear(cfg)
+	case TypeYouTrack:
+		return newYouTrack(cfg)
+	case TypeGitHub:
+		return newGitHub(issues, owner, repo)
+	default:
+		return nil, fmt.Errorf("unknown tracker: %q", cfg.Type)
+	}
+}
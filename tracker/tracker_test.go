@@ -0,0 +1,240 @@
+package tracker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-github/v51/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	ghClient "github.com/ravilushqa/gpt-pullrequest-updater/github"
+)
+
+type MockIssueGetter struct {
+	mock.Mock
+	webURL string
+}
+
+func (m *MockIssueGetter) WebURL() string {
+	return m.webURL
+}
+
+func (m *MockIssueGetter) GetIssue(ctx context.Context, owner, repo string, number int) (*github.Issue, error) {
+	args := m.Called(ctx, owner, repo, number)
+	return args.Get(0).(*github.Issue), args.Error(1)
+}
+
+func newTestServer(t *testing.T, handler http.HandlerFunc) string {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trackers.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"default": {"type": "jira", "url": "https://acme.atlassian.net", "projects": ["PAY"]},
+		"repos": {"Acme/App": {"type": "linear", "url": "https://linear.app/acme"}}
+	}`), 0o644))
+
+	cfg, err := LoadConfig(path, "acme", "app")
+	require.NoError(t, err)
+	assert.Equal(t, &Config{Type: TypeLinear, URL: "https://linear.app/acme"}, cfg)
+
+	cfg, err = LoadConfig(path, "acme", "other")
+	require.NoError(t, err)
+	assert.Equal(t, &Config{Type: TypeJira, URL: "https://acme.atlassian.net", Projects: []string{"PAY"}}, cfg)
+
+	_, err = LoadConfig(filepath.Join(t.TempDir(), "missing.json"), "acme", "app")
+	assert.Error(t, err)
+}
+
+func TestNew(t *testing.T) {
+	pr := &github.PullRequest{
+		Title: github.String("Round totals"),
+		Head:  &github.PullRequestBranch{Ref: github.String("feature/PAY-1-round")},
+	}
+
+	testCases := []struct {
+		name      string
+		cfg       Config
+		label     string
+		url       string
+		fetcher   bool
+		expectErr bool
+	}{
+		{name: "Jira", cfg: Config{Type: TypeJira, URL: "https://jira.example.com"}, label: "JIRA ticket", url: "https://jira.example.com/browse/PAY-1"},
+		{name: "Jira with token", cfg: Config{Type: TypeJira, URL: "https://jira.example.com", Token: "pat"}, label: "JIRA ticket", url: "https://jira.example.com/browse/PAY-1", fetcher: true},
+		{name: "Linear", cfg: Config{Type: TypeLinear, URL: "https://linear.app/acme/", Token: "key"}, label: "Linear issue", url: "https://linear.app/acme/issue/PAY-1", fetcher: true},
+		{name: "YouTrack", cfg: Config{Type: TypeYouTrack, URL: "https://acme.youtrack.cloud"}, label: "YouTrack issue", url: "https://acme.youtrack.cloud/issue/PAY-1"},
+		{name: "Missing URL", cfg: Config{Type: TypeLinear}, expectErr: true},
+		{name: "Unknown", cfg: Config{Type: "redmine"}, expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tr, err := New(tc.cfg, nil, "acme", "app")
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.label, tr.Name())
+			assert.Equal(t, []string{"PAY-1"}, tr.Keys(pr, nil))
+			assert.Equal(t, tc.url, tr.URL("PAY-1"))
+			_, ok := tr.(Fetcher)
+			assert.Equal(t, tc.fetcher, ok)
+		})
+	}
+}
+
+func TestGitHubTracker(t *testing.T) {
+	getter := &MockIssueGetter{webURL: "https://github.example.com/"}
+	tr, err := New(Config{Type: TypeGitHub, URL: "https://ignored.example.com"}, getter, "acme", "app")
+	require.NoError(t, err)
+
+	pr := &github.PullRequest{
+		Title: github.String("Fix crash (#99)"),
+		Head:  &github.PullRequestBranch{Ref: github.String("12-fix-crash")},
+		Body:  github.String("Fixes #12, closes acme/lib#3 and Resolves: Acme/App#14. See #15.\n<!-- gpt:start -->\nFixes #1\n<!-- gpt:end -->"),
+	}
+	commits := []*github.RepositoryCommit{
+		{Commit: &github.Commit{Message: github.String("Handle nil config\n\nfixed #16")}},
+	}
+
+	assert.Equal(t, []string{"#12", "acme/lib#3", "#14", "#16"}, tr.Keys(pr, commits))
+	assert.Equal(t, "https://github.example.com/acme/app/issues/12", tr.URL("#12"))
+	assert.Equal(t, "https://github.example.com/acme/lib/issues/3", tr.URL("acme/lib#3"))
+
+	getter.On("GetIssue", mock.Anything, "acme", "lib", 3).Return(&github.Issue{
+		Title: github.String("Crash on nil config"),
+		Body:  github.String("## Acceptance criteria\n- No crash\n- Error is logged"),
+	}, nil)
+	issues := Fetch(context.Background(), tr, []string{"acme/lib#3"})
+	require.Len(t, issues, 1)
+	assert.Equal(t, "Crash on nil config", issues[0].Title)
+	assert.Equal(t, []string{"No crash", "Error is logged"}, issues[0].AcceptanceCriteria)
+}
+
+func TestGitHubBranchKeys(t *testing.T) {
+	tr, err := New(Config{Type: TypeGitHub}, &MockIssueGetter{webURL: "https://github.com"}, "acme", "app")
+	require.NoError(t, err)
+
+	testCases := map[string][]string{
+		"12-fix-crash":          {"#12"},
+		"feature/issue-7":       {"#7"},
+		"gh-8-retry":            {"#8"},
+		"feature/GH-9/cache":    {"#9"},
+		"feature/v2-rewrite":    nil,
+		"release/2024-01":       nil,
+		"hotfix/1-2":            nil,
+		"2024-01":               nil,
+		"feature/12-fix":        nil,
+		"feature/issue-tracker": nil,
+	}
+	for branch, expected := range testCases {
+		t.Run(branch, func(t *testing.T) {
+			pr := &github.PullRequest{Head: &github.PullRequestBranch{Ref: github.String(branch)}}
+			assert.Equal(t, expected, tr.Keys(pr, nil))
+		})
+	}
+}
+
+func TestGitHubRequiresGitHubHost(t *testing.T) {
+	_, err := New(Config{Type: TypeGitHub}, nil, "acme", "app")
+	assert.Error(t, err)
+}
+
+func TestGitHubEnterpriseLinks(t *testing.T) {
+	client, err := ghClient.NewClientWithConfig(context.Background(), ghClient.Config{Token: "token", BaseURL: "https://github.example.com/api/v3/"}, "acme", "app")
+	require.NoError(t, err)
+
+	tracker, err := New(Config{Type: TypeGitHub}, client, "acme", "app")
+	require.NoError(t, err)
+	assert.Equal(t, "https://github.example.com/acme/app/issues/1", tracker.URL("#1"))
+	assert.Equal(t, "https://github.example.com/acme/lib/issues/2", tracker.URL("acme/lib#2"))
+}
+
+func TestLinearIssue(t *testing.T) {
+	apiURL := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "lin_api_key", r.Header.Get("Authorization"))
+		var req struct {
+			Variables map[string]string `json:"variables"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if req.Variables["id"] != "ENG-1" {
+			_, _ = w.Write([]byte(`{"data": {"issue": null}, "errors": [{"message": "Entity not found"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data": {"issue": {"identifier": "ENG-1", "title": "Retry", "description": "### Acceptance criteria\n* Retried 3 times"}}}`))
+	})
+	tr, err := New(Config{Type: TypeLinear, URL: "https://linear.app/acme", Token: "lin_api_key", APIURL: apiURL}, nil, "acme", "app")
+	require.NoError(t, err)
+
+	issue, err := tr.(Fetcher).Issue(context.Background(), "ENG-1")
+	require.NoError(t, err)
+	assert.Equal(t, &Issue{Key: "ENG-1", Title: "Retry", Description: "### Acceptance criteria\n* Retried 3 times", AcceptanceCriteria: []string{"Retried 3 times"}}, issue)
+
+	_, err = tr.(Fetcher).Issue(context.Background(), "ENG-2")
+	assert.ErrorContains(t, err, "Entity not found")
+}
+
+func TestYouTrackIssue(t *testing.T) {
+	baseURL := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer perm:token", r.Header.Get("Authorization"))
+		assert.Equal(t, "/api/issues/APP-3", r.URL.Path)
+		assert.Equal(t, "idReadable,summary,description", r.URL.Query().Get("fields"))
+		_, _ = w.Write([]byte(`{"idReadable": "APP-3", "summary": "Export CSV", "description": "Users can export orders."}`))
+	})
+	tr, err := New(Config{Type: TypeYouTrack, URL: baseURL, Token: "perm:token"}, nil, "acme", "app")
+	require.NoError(t, err)
+
+	issues := Fetch(context.Background(), tr, []string{"APP-3"})
+	require.Len(t, issues, 1)
+	assert.Equal(t, &Issue{Key: "APP-3", Title: "Export CSV", Description: "Users can export orders."}, issues[0])
+}
+
+func TestFetchWithoutToken(t *testing.T) {
+	tr, err := New(Config{Type: TypeJira, URL: "https://jira.example.com"}, nil, "acme", "app")
+	require.NoError(t, err)
+
+	assert.Empty(t, Fetch(context.Background(), tr, []string{"PAY-1"}))
+}
+
+func TestHTTPClient(t *testing.T) {
+	client, err := NewHTTPClient(Config{Type: TypeYouTrack})
+	require.NoError(t, err)
+	assert.Equal(t, requestTimeout, client.Timeout)
+
+	_, err = NewHTTPClient(Config{Type: TypeYouTrack, CABundle: filepath.Join(t.TempDir(), "missing.pem")})
+	assert.ErrorContains(t, err, "error reading ca bundle")
+
+	// a configured client sends the requests, e.g. one going through the proxy
+	proxied := false
+	baseURL := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"idReadable": "APP-3", "summary": "Export CSV"}`))
+	})
+	httpClient := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		proxied = true
+		return http.DefaultTransport.RoundTrip(req)
+	})}
+	tr, err := New(Config{Type: TypeYouTrack, URL: baseURL, Token: "perm:token", HTTPClient: httpClient}, nil, "acme", "app")
+	require.NoError(t, err)
+
+	_, err = tr.(Fetcher).Issue(context.Background(), "APP-3")
+	require.NoError(t, err)
+	assert.True(t, proxied)
+}
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
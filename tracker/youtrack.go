@@ -0,0 +1,79 @@
+package tracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/go-github/v51/github"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/criteria"
+	"github.com/ravilushqa/gpt-pullrequest-updater/jira"
+)
+
+// youTrackTracker links YouTrack issues, whose IDs look like Jira keys, e.g. PROJ-123.
+type youTrackTracker struct {
+	url       string
+	extractor *jira.Extractor
+}
+
+type youTrackFetcher struct {
+	*youTrackTracker
+	httpClient *http.Client
+	apiURL     string
+	token      string
+}
+
+func newYouTrack(cfg Config) (Tracker, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("youtrack url is required")
+	}
+	t := &youTrackTracker{url: strings.TrimSuffix(cfg.URL, "/"), extractor: jira.NewExtractor(cfg.Projects)}
+	if cfg.Token == "" {
+		return t, nil
+	}
+
+	apiURL := cfg.APIURL
+	if apiURL == "" {
+		apiURL = t.url + "/api"
+	}
+	return &youTrackFetcher{youTrackTracker: t, httpClient: cfg.HTTPClient, apiURL: strings.TrimSuffix(apiURL, "/"), token: cfg.Token}, nil
+}
+
+func (t *youTrackTracker) Name() string {
+	return "YouTrack issue"
+}
+
+func (t *youTrackTracker) Keys(pr *github.PullRequest, commits []*github.RepositoryCommit) []string {
+	return t.extractor.FromPullRequest(pr, commits)
+}
+
+func (t *youTrackTracker) URL(key string) string {
+	return fmt.Sprintf("%s/issue/%s", t.url, key)
+}
+
+type youTrackIssue struct {
+	IDReadable  string `json:"idReadable"`
+	Summary     string `json:"summary"`
+	Description string `json:"description"`
+}
+
+func (t *youTrackFetcher) Issue(ctx context.Context, key string) (*Issue, error) {
+	query := url.Values{"fields": {"idReadable,summary,description"}}
+	header := http.Header{"Authorization": {"Bearer " + t.token}}
+
+	var resp youTrackIssue
+	if err := doJSON(ctx, t.httpClient, http.MethodGet, t.apiURL+"/issues/"+url.PathEscape(key)+"?"+query.Encode(), header, nil, &resp); err != nil {
+		return nil, fmt.Errorf("error getting issue %s: %w", key, err)
+	}
+
+	return &Issue{
+		Key:                resp.IDReadable,
+		Title:              resp.Summary,
+		Description:        resp.Description,
+		AcceptanceCriteria: criteria.FromDescription(resp.Description),
+	}, nil
+}
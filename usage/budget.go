@@ -0,0 +1,144 @@
+package usage
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/sashabaranov/go-openai"
+
+	"github.com/ravilushqa/gpt-pullrequest-updater/logs"
+)
+
+// ErrBudgetExceeded is returned instead of calling the API once the run budget is used up.
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+const (
+	// EstimatedCompletionTokens is the completion size assumed when estimating the cost of a call.
+	EstimatedCompletionTokens = 300
+	// nearlyExhaustedRatio is the share of the budget after which low-priority work is skipped.
+	nearlyExhaustedRatio = 0.8
+)
+
+// Budget caps the tokens and cost of a run. A zero cap means no limit. A nil Budget allows everything.
+type Budget struct {
+	tracker   *Tracker
+	prices    Prices
+	maxTokens int
+	maxCost   float64
+
+	mu        sync.Mutex
+	exhausted bool
+}
+
+func NewBudget(tracker *Tracker, prices Prices, maxTokens int, maxCost float64) *Budget {
+	return &Budget{
+		tracker:   tracker,
+		prices:    prices,
+		maxTokens: maxTokens,
+		maxCost:   maxCost,
+	}
+}
+
+// BudgetOf returns the budget of a completion client, or nil when it has none.
+func BudgetOf(c interface{}) *Budget {
+	if b, ok := c.(interface{ Budget() *Budget }); ok {
+		return b.Budget()
+	}
+	return nil
+}
+
+// EstimateTokens roughly estimates the prompt tokens of messages at four characters per token.
+func EstimateTokens(messages []openai.ChatCompletionMessage) int {
+	tokens := 0
+	for _, m := range messages {
+		tokens += len(m.Content)/4 + 4
+	}
+	return tokens
+}
+
+// Check returns ErrBudgetExceeded if a call to model with promptTokens would exceed the budget.
+// Once a call is refused, every following call is refused too.
+func (b *Budget) Check(model string, promptTokens int) error {
+	if b == nil || (b.maxTokens == 0 && b.maxCost == 0) {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.exhausted {
+		return ErrBudgetExceeded
+	}
+
+	report := b.tracker.Report(b.prices)
+	price, _ := b.prices.Lookup(model)
+	tokens := report.TotalTokens + promptTokens + EstimatedCompletionTokens
+	cost := report.Cost + price.Cost(promptTokens, EstimatedCompletionTokens)
+
+	if b.maxTokens > 0 && tokens > b.maxTokens {
+		b.exhausted = true
+		return fmt.Errorf("%w: %d estimated tokens over the limit of %d", ErrBudgetExceeded, tokens, b.maxTokens)
+	}
+	if b.maxCost > 0 && cost > b.maxCost {
+		b.exhausted = true
+		return fmt.Errorf("%w: estimated cost $%.4f over the limit of $%.4f", ErrBudgetExceeded, cost, b.maxCost)
+	}
+
+	return nil
+}
+
+// NearlyExhausted reports whether most of the budget is used, so low-priority work should be skipped.
+func (b *Budget) NearlyExhausted() bool {
+	if b == nil || (b.maxTokens == 0 && b.maxCost == 0) {
+		return false
+	}
+
+	b.mu.Lock()
+	exhausted := b.exhausted
+	b.mu.Unlock()
+	if exhausted {
+		return true
+	}
+
+	report := b.tracker.Report(b.prices)
+	if b.maxTokens > 0 && float64(report.TotalTokens) >= float64(b.maxTokens)*nearlyExhaustedRatio {
+		return true
+	}
+	return b.maxCost > 0 && report.Cost >= b.maxCost*nearlyExhaustedRatio
+}
+
+// Skip records work that was not done because of the budget.
+func (b *Budget) Skip(item, reason string) {
+	if b == nil {
+		return
+	}
+	logs.Printf("Skipping %s: %s\n", item, reason)
+	b.tracker.Skip(item, reason)
+}
+
+// IsLowPriority reports whether a file is unlikely to matter for a review or description,
+// such as tests, docs, lock files, vendored or generated code.
+func IsLowPriority(filename string) bool {
+	base := path.Base(filename)
+	switch base {
+	case "go.sum", "package-lock.json", "yarn.lock", "pnpm-lock.yaml", "Cargo.lock", "poetry.lock", "Gemfile.lock", "composer.lock":
+		return true
+	}
+
+	for _, dir := range []string{"vendor/", "testdata/", "docs/", "node_modules/"} {
+		if strings.HasPrefix(filename, dir) || strings.Contains(filename, "/"+dir) {
+			return true
+		}
+	}
+
+	for _, suffix := range []string{"_test.go", ".md", ".txt", ".pb.go", "_gen.go", ".gen.go", ".min.js", ".svg", ".snap"} {
+		if strings.HasSuffix(base, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
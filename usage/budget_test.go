@@ -0,0 +1,115 @@
+package usage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBudgetCheck(t *testing.T) {
+	testCases := []struct {
+		name         string
+		maxTokens    int
+		maxCost      float64
+		usedTokens   int
+		promptTokens int
+		expectError  bool
+	}{
+		{
+			name:         "No limits",
+			usedTokens:   1_000_000,
+			promptTokens: 1000,
+		},
+		{
+			name:         "Within token limit",
+			maxTokens:    2000,
+			usedTokens:   500,
+			promptTokens: 500,
+		},
+		{
+			name:         "Over token limit",
+			maxTokens:    1000,
+			usedTokens:   500,
+			promptTokens: 500,
+			expectError:  true,
+		},
+		{
+			name:         "Over cost limit",
+			maxCost:      0.01,
+			usedTokens:   300,
+			promptTokens: 100,
+			expectError:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tracker := NewTracker()
+			tracker.Add("gpt-4", tc.usedTokens, 0)
+			budget := NewBudget(tracker, DefaultPrices, tc.maxTokens, tc.maxCost)
+
+			err := budget.Check("gpt-4", tc.promptTokens)
+			if tc.expectError {
+				assert.True(t, errors.Is(err, ErrBudgetExceeded))
+				assert.ErrorIs(t, budget.Check("gpt-4", 0), ErrBudgetExceeded, "budget must stay exhausted")
+				assert.True(t, budget.NearlyExhausted())
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestBudgetNearlyExhausted(t *testing.T) {
+	tracker := NewTracker()
+	budget := NewBudget(tracker, DefaultPrices, 1000, 0)
+	assert.False(t, budget.NearlyExhausted())
+
+	tracker.Add("gpt-4", 800, 0)
+	assert.True(t, budget.NearlyExhausted())
+
+	var nilBudget *Budget
+	assert.False(t, nilBudget.NearlyExhausted())
+	assert.NoError(t, nilBudget.Check("gpt-4", 1000))
+}
+
+func TestBudgetSkip(t *testing.T) {
+	tracker := NewTracker()
+	budget := NewBudget(tracker, DefaultPrices, 1000, 0)
+
+	budget.Skip("main.go", "budget exceeded")
+
+	assert.Equal(t, []Skipped{{Item: "main.go", Reason: "budget exceeded"}}, tracker.Report(DefaultPrices).Skipped)
+}
+
+func TestEstimateTokens(t *testing.T) {
+	tokens := EstimateTokens([]openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: "12345678"},
+		{Role: openai.ChatMessageRoleUser, Content: "1234"},
+	})
+	assert.Equal(t, 11, tokens)
+}
+
+func TestIsLowPriority(t *testing.T) {
+	testCases := []struct {
+		filename string
+		expected bool
+	}{
+		{filename: "main.go", expected: false},
+		{filename: "review/review.go", expected: false},
+		{filename: "review/review_test.go", expected: true},
+		{filename: "go.sum", expected: true},
+		{filename: "README.md", expected: true},
+		{filename: "vendor/github.com/pkg/errors/errors.go", expected: true},
+		{filename: "api/service.pb.go", expected: true},
+		{filename: "web/node_modules/lib/index.js", expected: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.filename, func(t *testing.T) {
+			assert.Equal(t, tc.expected, IsLowPriority(tc.filename))
+		})
+	}
+}
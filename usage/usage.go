@@ -0,0 +1,229 @@
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Price is the cost of a model in USD per million tokens.
+type Price struct {
+	Prompt     float64 `json:"prompt"`
+	Completion float64 `json:"completion"`
+}
+
+// Prices maps model names to their prices.
+type Prices map[string]Price
+
+// DefaultPrices is the built-in price table. Models are matched by the longest prefix, so dated snapshots
+// such as gpt-4-0613 use the price of their base model.
+var DefaultPrices = Prices{
+	"gpt-3.5-turbo":     {Prompt: 0.5, Completion: 1.5},
+	"gpt-4":             {Prompt: 30, Completion: 60},
+	"gpt-4-32k":         {Prompt: 60, Completion: 120},
+	"gpt-4-turbo":       {Prompt: 10, Completion: 30},
+	"gpt-4o":            {Prompt: 2.5, Completion: 10},
+	"gpt-4o-mini":       {Prompt: 0.15, Completion: 0.6},
+	"claude-3-5-sonnet": {Prompt: 3, Completion: 15},
+	"claude-3-5-haiku":  {Prompt: 0.8, Completion: 4},
+	"claude-3-opus":     {Prompt: 15, Completion: 75},
+	"claude-3-haiku":    {Prompt: 0.25, Completion: 1.25},
+}
+
+// LoadPrices returns DefaultPrices overridden by the JSON price table in path.
+func LoadPrices(path string) (Prices, error) {
+	prices := make(Prices, len(DefaultPrices))
+	for model, price := range DefaultPrices {
+		prices[model] = price
+	}
+	if path == "" {
+		return prices, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading price file: %w", err)
+	}
+	var overrides Prices
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("error parsing price file: %w", err)
+	}
+	for model, price := range overrides {
+		prices[model] = price
+	}
+
+	return prices, nil
+}
+
+// Lookup returns the price of model using the longest matching prefix.
+func (p Prices) Lookup(model string) (Price, bool) {
+	if price, ok := p[model]; ok {
+		return price, true
+	}
+
+	best := ""
+	for name := range p {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return Price{}, false
+	}
+	return p[best], true
+}
+
+// Cost returns the cost in USD of the given tokens.
+func (p Price) Cost(promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*p.Prompt + float64(completionTokens)*p.Completion) / 1_000_000
+}
+
+// Tracker collects the token usage of every completion call. It is safe for concurrent use.
+type Tracker struct {
+	mu      sync.Mutex
+	models  map[string]*ModelUsage
+	order   []string
+	skipped []Skipped
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{models: make(map[string]*ModelUsage)}
+}
+
+// Add records a single completion call.
+func (t *Tracker) Add(model string, promptTokens, completionTokens int) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	m, ok := t.models[model]
+	if !ok {
+		m = &ModelUsage{Model: model}
+		t.models[model] = m
+		t.order = append(t.order, model)
+	}
+	m.Calls++
+	m.PromptTokens += promptTokens
+	m.CompletionTokens += completionTokens
+}
+
+// Skip records work that was not done, for example because of the budget.
+func (t *Tracker) Skip(item, reason string) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.skipped = append(t.skipped, Skipped{Item: item, Reason: reason})
+}
+
+// Skipped is a piece of work that was not done.
+type Skipped struct {
+	Item   string `json:"item"`
+	Reason string `json:"reason"`
+}
+
+// ModelUsage is the usage of a single model.
+type ModelUsage struct {
+	Model            string  `json:"model"`
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Cost             float64 `json:"estimated_cost_usd"`
+	UnknownPrice     bool    `json:"unknown_price,omitempty"`
+}
+
+// Report is the usage summary of a run.
+type Report struct {
+	Calls            int          `json:"calls"`
+	PromptTokens     int          `json:"prompt_tokens"`
+	CompletionTokens int          `json:"completion_tokens"`
+	TotalTokens      int          `json:"total_tokens"`
+	Cost             float64      `json:"estimated_cost_usd"`
+	Models           []ModelUsage `json:"models"`
+	Skipped          []Skipped    `json:"skipped,omitempty"`
+}
+
+// Report summarizes the recorded usage using prices.
+func (t *Tracker) Report(prices Prices) Report {
+	if t == nil {
+		return Report{}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	r := Report{Models: make([]ModelUsage, 0, len(t.order))}
+	for _, model := range t.order {
+		m := *t.models[model]
+		price, ok := prices.Lookup(model)
+		m.UnknownPrice = !ok
+		m.Cost = price.Cost(m.PromptTokens, m.CompletionTokens)
+
+		r.Calls += m.Calls
+		r.PromptTokens += m.PromptTokens
+		r.CompletionTokens += m.CompletionTokens
+		r.Cost += m.Cost
+		r.Models = append(r.Models, m)
+	}
+	r.TotalTokens = r.PromptTokens + r.CompletionTokens
+	r.Skipped = append(r.Skipped, t.skipped...)
+	sort.SliceStable(r.Models, func(i, j int) bool { return r.Models[i].Cost > r.Models[j].Cost })
+
+	return r
+}
+
+func (r Report) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Usage: %d calls, %d prompt tokens, %d completion tokens, %d total tokens, estimated cost $%.4f\n",
+		r.Calls, r.PromptTokens, r.CompletionTokens, r.TotalTokens, r.Cost)
+	for _, m := range r.Models {
+		fmt.Fprintf(&sb, "  %s: %d calls, %d prompt tokens, %d completion tokens, estimated cost $%.4f",
+			m.Model, m.Calls, m.PromptTokens, m.CompletionTokens, m.Cost)
+		if m.UnknownPrice {
+			sb.WriteString(" (unknown price)")
+		}
+		sb.WriteString("\n")
+	}
+	if len(r.Skipped) > 0 {
+		fmt.Fprintf(&sb, "Skipped %d items:\n", len(r.Skipped))
+		for _, s := range r.Skipped {
+			fmt.Fprintf(&sb, "  %s: %s\n", s.Item, s.Reason)
+		}
+	}
+	return sb.String()
+}
+
+// Footer renders the report as a markdown footer for pull request bodies and comments.
+func (r Report) Footer() string {
+	models := make([]string, 0, len(r.Models))
+	for _, m := range r.Models {
+		models = append(models, m.Model)
+	}
+	skipped := ""
+	if len(r.Skipped) > 0 {
+		skipped = fmt.Sprintf(", %d items skipped", len(r.Skipped))
+	}
+	return fmt.Sprintf("\n\n---\n<sub>Generated with %s: %d calls, %d tokens, estimated cost $%.4f%s</sub>\n",
+		strings.Join(models, ", "), r.Calls, r.TotalTokens, r.Cost, skipped)
+}
+
+// WriteJSON writes the report as JSON to path.
+func (r Report) WriteJSON(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding usage report: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("error writing usage report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package usage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPricesLookup(t *testing.T) {
+	testCases := []struct {
+		name          string
+		model         string
+		expected      Price
+		expectedFound bool
+	}{
+		{
+			name:          "Exact match",
+			model:         "gpt-4",
+			expected:      Price{Prompt: 30, Completion: 60},
+			expectedFound: true,
+		},
+		{
+			name:          "Dated snapshot",
+			model:         "gpt-4-0613",
+			expected:      Price{Prompt: 30, Completion: 60},
+			expectedFound: true,
+		},
+		{
+			name:          "Longest prefix wins",
+			model:         "gpt-4o-mini-2024-07-18",
+			expected:      Price{Prompt: 0.15, Completion: 0.6},
+			expectedFound: true,
+		},
+		{
+			name:  "Unknown model",
+			model: "llama3",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			price, found := DefaultPrices.Lookup(tc.model)
+			assert.Equal(t, tc.expectedFound, found)
+			assert.Equal(t, tc.expected, price)
+		})
+	}
+}
+
+func TestTrackerReport(t *testing.T) {
+	tracker := NewTracker()
+	tracker.Add("gpt-4", 1000, 500)
+	tracker.Add("gpt-4", 1000, 500)
+	tracker.Add("llama3", 300, 100)
+
+	report := tracker.Report(DefaultPrices)
+
+	assert.Equal(t, 3, report.Calls)
+	assert.Equal(t, 2300, report.PromptTokens)
+	assert.Equal(t, 1100, report.CompletionTokens)
+	assert.Equal(t, 3400, report.TotalTokens)
+	assert.InDelta(t, 0.12, report.Cost, 1e-9)
+	require.Len(t, report.Models, 2)
+	assert.Equal(t, "gpt-4", report.Models[0].Model)
+	assert.True(t, report.Models[1].UnknownPrice)
+}
+
+func TestNilTracker(t *testing.T) {
+	var tracker *Tracker
+	assert.NotPanics(t, func() { tracker.Add("gpt-4", 1, 1) })
+}
+
+func TestLoadPrices(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"gpt-4": {"prompt": 1, "completion": 2}, "llama3": {"prompt": 0, "completion": 0}}`), 0o600))
+
+	prices, err := LoadPrices(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, Price{Prompt: 1, Completion: 2}, prices["gpt-4"])
+	assert.Equal(t, DefaultPrices["gpt-3.5-turbo"], prices["gpt-3.5-turbo"])
+	_, found := prices.Lookup("llama3")
+	assert.True(t, found)
+	assert.Equal(t, Price{Prompt: 30, Completion: 60}, DefaultPrices["gpt-4"])
+}
+
+func TestReportWriteJSON(t *testing.T) {
+	tracker := NewTracker()
+	tracker.Add("gpt-3.5-turbo", 100, 50)
+	path := filepath.Join(t.TempDir(), "usage.json")
+
+	require.NoError(t, tracker.Report(DefaultPrices).WriteJSON(path))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var report Report
+	require.NoError(t, json.Unmarshal(data, &report))
+	assert.Equal(t, 150, report.TotalTokens)
+}